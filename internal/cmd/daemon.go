@@ -3,16 +3,30 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/aliases"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/jobs"
+	"github.com/ramonvermeulen/whosthere/internal/core/known"
 	"github.com/ramonvermeulen/whosthere/internal/core/logging"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
 	"github.com/ramonvermeulen/whosthere/internal/core/version"
+	"github.com/ramonvermeulen/whosthere/internal/core/webhooks"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/spf13/cobra"
 )
@@ -25,31 +39,70 @@ func NewDaemonCommand() *cobra.Command {
 ` + magenta + `
 Examples:` + reset + `
   whosthere daemon --port=8080
+  curl -N 'http://localhost:8080/events?types=device_discovered,device_offline'
+  websocat ws://localhost:8080/ws
 `,
 		RunE: runDaemon,
 	}
 
-	cmd.Flags().StringP("port", "p", "", "Port for the HTTP API server")
+	cmd.Flags().StringP("port", "p", "", "Port for the HTTP API server (default daemon.port from the config)")
+	cmd.Flags().String("bind", "", "Address for the HTTP API server to listen on (default daemon.bind from the config)")
 	return cmd
 }
 
 func runDaemon(cmd *cobra.Command, _ []string) error {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	defer signal.Stop(reloadSignals)
+
 	logger, err := logging.New(true)
 	if err != nil {
 		return err
 	}
 
-	port, _ := cmd.Flags().GetString("port")
-	if port == "" {
-		port = "8080"
-		logger.Log(ctx, slog.LevelInfo, "no port specified, defaulting to 8080")
+	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	if err != nil {
+		return err
 	}
 
-	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	bind := cfg.Daemon.Bind
+	if cmd.Flags().Changed("bind") {
+		bind, _ = cmd.Flags().GetString("bind")
+	}
+	port := strconv.Itoa(cfg.Daemon.Port)
+	if p, _ := cmd.Flags().GetString("port"); p != "" {
+		port = p
+	}
+
+	auth, err := loadDaemonAuth(cfg.Daemon.Auth)
 	if err != nil {
 		return err
 	}
+	if !auth.enabled() && !isLoopback(bind) {
+		logger.Log(ctx, slog.LevelWarn, "daemon API is reachable from the network without authentication, configure daemon.auth tokens", "bind", bind)
+	}
+
+	var certs *certReloader
+	if cfg.Daemon.TLS.Enabled() {
+		if certs, err = newCertReloader(cfg.Daemon.TLS); err != nil {
+			return err
+		}
+	}
+
+	var socket net.Listener
+	if cfg.Daemon.Socket.Enabled {
+		path, err := daemonSocketPath(cfg.Daemon.Socket)
+		if err != nil {
+			return err
+		}
+		if socket, err = listenDaemonSocket(path, os.FileMode(cfg.Daemon.Socket.Mode)); err != nil {
+			return fmt.Errorf("listen on Unix socket: %w", err)
+		}
+		// closed by the server on shutdown, this covers returning early
+		defer func() { _ = socket.Close() }()
+	}
 
 	appState := state.NewAppState(cfg, version.Version)
 	eng, err := core.BuildEngine(cfg, logger)
@@ -57,54 +110,378 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	http.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
-		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
-		handleDevices(w, r, appState)
+	store, err := loadAliases()
+	if err != nil {
+		return err
+	}
+	knownDevices, err := loadKnownDevices()
+	if err != nil {
+		return err
+	}
+
+	d := &daemon{
+		cfg:         cfg,
+		logger:      logger,
+		state:       appState,
+		broker:      events.NewBroker(),
+		tracker:     tracker.New(3 * cfg.ScanInterval),
+		aliases:     store,
+		known:       knownDevices,
+		engine:      eng,
+		portScanner: core.BuildPortScanner(cfg, eng.Iface),
+		jobs:        jobs.NewManager(cfg.PortScanner.HostConcurrency),
+		metrics:     newDaemonMetrics(),
+		auth:        auth,
+	}
+	if err := d.startWebhooks(cfg.Daemon.Webhooks); err != nil {
+		return err
+	}
+	stopMQTT := d.startMQTT(cfg.Daemon.MQTT)
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := newDaemonServer(requestCtx, net.JoinHostPort(bind, port), mux, certs)
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logger.Log(ctx, slog.LevelInfo, "starting HTTP server", "addr", srv.Addr, "tls", certs != nil, "auth", auth.enabled())
+		serveErr <- listenAndServe(srv)
+	}()
+	if socket != nil {
+		go func() {
+			logger.Log(ctx, slog.LevelInfo, "serving HTTP API on Unix socket", "path", socket.Addr().String())
+			serveErr <- serveDaemonSocket(srv, socket)
+		}()
+	}
+
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		d.forwardEngineEvents(eng.Events)
+	}()
+
+	eng.Start(context.Background())
+
+wait:
+	for {
+		select {
+		case <-reloadSignals:
+			logger.Log(ctx, slog.LevelInfo, "reloading configuration")
+			if err := reloadDaemon(d, eng.Iface, certs); err != nil {
+				logger.Log(ctx, slog.LevelError, "failed to reload configuration, keeping the current one", "error", err)
+			}
+		case err = <-serveErr:
+			if err != nil {
+				logger.Log(ctx, slog.LevelError, "HTTP server failed", "error", err)
+			}
+			break wait
+		case <-ctx.Done():
+			logger.Log(context.Background(), slog.LevelInfo, "shutting down")
+			break wait
+		}
+	}
+
+	shutdownErr := d.shutdown(srv, cancelRequests,
+		func(context.Context) { d.jobs.Close() },
+		func(context.Context) { eng.Stop() },
+		func(context.Context) { <-forwarded },
+		d.stopWebhooks, stopMQTT)
+	if shutdownErr != nil {
+		logger.Log(context.Background(), slog.LevelError, "shutdown incomplete", "error", shutdownErr)
+	}
+	if err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// reloadDaemon reloads the config file and the TLS certificate on SIGHUP.
+func reloadDaemon(d *daemon, iface *discovery.InterfaceInfo, certs *certReloader) error {
+	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	if err != nil {
+		return err
+	}
+	if err := d.reload(cfg, core.BuildPortScanner(cfg, iface)); err != nil {
+		return err
+	}
+	if certs != nil {
+		return certs.load()
+	}
+	return nil
+}
+
+// isLoopback reports whether the bind address only accepts local connections.
+func isLoopback(bind string) bool {
+	if bind == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(bind)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loadAliases loads the device aliases from the state directory, keeping them in memory
+// only when the state directory is not available.
+func loadAliases() (*aliases.Store, error) {
+	path, err := aliases.DefaultPath()
+	if err != nil {
+		path = ""
+	}
+	return aliases.Load(path)
+}
+
+// loadKnownDevices loads the devices known before a restart from the state directory, keeping
+// them in memory only when the state directory is not available.
+func loadKnownDevices() (*known.Store, error) {
+	path, err := known.DefaultPath()
+	if err != nil {
+		path = ""
+	}
+	return known.Load(path)
+}
+
+// daemonEngine is the part of the discovery engine used by the daemon handlers.
+type daemonEngine interface {
+	TriggerScan() bool
+	RecordPortScan(d *discovery.Device, ports map[string][]int) *discovery.PortChanges
+	DroppedEvents() uint64
+	SweepStats() (discovery.SweepStats, bool)
+}
+
+// daemonPortScanner is the part of the port scanner used by the daemon handlers.
+type daemonPortScanner interface {
+	ScanHosts(ctx context.Context, hosts []string, ports []int, timeout time.Duration, callback func(ip string, port int)) error
+}
+
+// daemon holds the state shared by the HTTP and WebSocket handlers of daemon mode.
+type daemon struct {
+	cfg         *config.Config
+	logger      *slog.Logger
+	state       *state.AppState
+	broker      *events.Broker
+	tracker     *tracker.Tracker
+	aliases     *aliases.Store
+	known       *known.Store
+	engine      daemonEngine
+	portScanner daemonPortScanner
+	jobs        *jobs.Manager
+	metrics     *daemonMetrics
+	auth        daemonAuth
+	webhooks    *webhooks.Notifier
+	// cancelWebhooks cancels the event subscription of webhooks
+	cancelWebhooks func()
+
+	// mu guards cfg, portScanner, auth and the webhooks, which are replaced when the config
+	// is reloaded
+	mu sync.RWMutex
+	// ready is set once the first scan completed, see handleReady
+	ready atomic.Bool
+	// engineStopped is set when the discovery engine stopped, see handleLive
+	engineStopped atomic.Bool
+	// stopping is set when the daemon is shutting down
+	stopping atomic.Bool
+}
+
+func (d *daemon) registerRoutes(mux *http.ServeMux) {
+	d.handle(mux, "/devices", scopeRead, d.handleDevices)
+	d.handle(mux, "/devices/", scopeRead, func(w http.ResponseWriter, r *http.Request) {
+		handleDeviceByIP(w, r, d.state)
 	})
-	http.HandleFunc("/devices/", func(w http.ResponseWriter, r *http.Request) {
-		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
-		handleDeviceByIP(w, r, appState)
+	d.handle(mux, "GET /devices/{ip}/portscans", scopeRead, func(w http.ResponseWriter, r *http.Request) {
+		handlePortScanHistory(w, r, d.state)
 	})
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
+	d.handle(mux, "GET /devices/{ip}/ports", scopeRead, d.handleDevicePorts)
+	d.handle(mux, "POST /devices/{ip}/portscan", scopeAdmin, d.handlePortScan)
+	d.handle(mux, "POST /scan", scopeAdmin, d.handleScan)
+	d.handle(mux, "GET /jobs", scopeRead, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, d.jobs.List())
+	})
+	d.handle(mux, "GET /jobs/{id}", scopeRead, d.handleJob)
+	d.handle(mux, "GET /events", scopeRead, func(w http.ResponseWriter, r *http.Request) {
+		handleEvents(w, r, d.broker)
+	})
+	d.handle(mux, "GET /ws", scopeRead, d.handleWebSocket)
+	d.handle(mux, "GET /metrics", scopeRead, d.handleMetrics)
+	// webhook URLs often embed a secret, so the delivery log is for admins only
+	d.handle(mux, "GET /webhooks/deliveries", scopeAdmin, d.handleWebhookDeliveries)
+	// the health checks stay open, so probes do not need a token
+	d.handle(mux, "/health", scopeNone, func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
+	d.handle(mux, "GET /livez", scopeNone, d.handleLive)
+	d.handle(mux, "GET /readyz", scopeNone, d.handleReady)
+}
 
-	go func() {
-		logger.Log(context.Background(), slog.LevelInfo, "starting HTTP server", "port", port)
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			logger.Log(context.Background(), slog.LevelError, "HTTP server failed", "error", err)
+// crossOrigin rejects POST requests sent by other web pages, e.g. a page that makes the
+// browser start a port scan on an unauthenticated daemon listening on localhost.
+var crossOrigin = http.NewCrossOriginProtection()
+
+// handle registers a handler that logs the request, rejects cross-origin requests that
+// change state and requires the given scope.
+func (d *daemon) handle(mux *http.ServeMux, pattern string, s scope, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		d.logger.Log(r.Context(), slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
+		if err := crossOrigin.Check(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
 		}
-	}()
+		d.currentAuth().requireScope(s, h)(w, r)
+	})
+}
 
+// forwardEngineEvents updates the app state from the engine events and publishes them to the
+// broker. Device events go through the tracker, so subscribers see devices being discovered,
+// changed, going offline and coming back online instead of every observation. Aliases replace
+// the discovered device names.
+func (d *daemon) forwardEngineEvents(engineEvents <-chan discovery.Event) {
+	ctx := context.Background()
+	for event := range engineEvents {
+		now := time.Now()
+		d.metrics.observeEvent(event)
+		switch event.Type {
+		case discovery.EventDeviceDiscovered:
+			if event.Device == nil {
+				continue
+			}
+			device := d.aliases.Apply(event.Device)
+			d.state.UpsertDevice(device)
+			if change := d.tracker.Observe(device, now); change != nil {
+				d.broker.Publish(events.FromChange(*change))
+			}
+			continue
+		case discovery.EventScanCompleted:
+			d.ready.Store(true)
+			for _, change := range d.tracker.Sweep(now) {
+				d.broker.Publish(events.FromChange(change))
+			}
+		case discovery.EventEngineStopped:
+			d.ready.Store(false)
+			d.engineStopped.Store(true)
+		case discovery.EventPortsChanged:
+			if event.Device != nil && event.Changes != nil {
+				d.logger.Log(ctx, slog.LevelInfo, "ports changed", "ip", event.Device.IP().String(),
+					"opened", event.Changes.Opened, "closed", event.Changes.Closed)
+			}
+		}
+
+		if msg, ok := events.FromEngineEvent(event, now); ok {
+			d.broker.Publish(msg)
+		}
+	}
+}
+
+// sinkBufferSize is the number of events buffered for webhooks and MQTT, much more than
+// for remote clients so a sweep that takes many devices offline at once fits.
+const sinkBufferSize = 4096
+
+// subscribeSink passes the events of the given types to notify, which must not block. A sink
+// that falls behind anyway is subscribed again right away, the events it missed are dropped
+// and logged with its name. notify is called from a single goroutine. The returned function
+// cancels the subscription.
+func (d *daemon) subscribeSink(types []events.Type, notify func(events.Message), name string) (cancel func()) {
+	var mu sync.Mutex
+	cancelled := false
+	sub := d.broker.Subscribe(sinkBufferSize, types...)
 	go func() {
-		for event := range eng.Events {
-			switch event.Type {
-			case discovery.EventScanStarted:
-			case discovery.EventScanCompleted:
-			case discovery.EventDeviceDiscovered:
-				if event.Device != nil {
-					appState.UpsertDevice(event.Device)
-				}
-			case discovery.EventError:
-			default:
+		for {
+			for msg := range sub.C {
+				notify(msg)
+			}
+
+			mu.Lock()
+			if cancelled || !sub.Slow() {
+				mu.Unlock()
+				return
 			}
+			sub = d.broker.Subscribe(sinkBufferSize, types...)
+			mu.Unlock()
+			d.logger.Log(context.Background(), slog.LevelWarn, "event sink fell behind, events were dropped", "sink", name)
 		}
 	}()
 
-	eng.Start(context.Background())
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		cancelled = true
+		sub.Cancel()
+	}
+}
+
+// setAlias stores the alias of a known device and renames it right away, an empty alias
+// removes it (the discovered name returns with the next scan).
+func (d *daemon) setAlias(ip, alias string) (*discovery.Device, error) {
+	device, ok := d.state.GetDevice(ip)
+	if !ok {
+		return nil, fmt.Errorf("device %s not found", ip)
+	}
+	if err := d.aliases.Set(device.IdentityKey(), alias); err != nil {
+		return nil, err
+	}
 
-	select {}
+	device.SetDisplayName(strings.TrimSpace(alias))
+	if change := d.tracker.Replace(device, time.Now()); change != nil {
+		d.broker.Publish(events.FromChange(*change))
+	}
+	return device, nil
 }
 
-func handleDevices(w http.ResponseWriter, _ *http.Request, appState *state.AppState) {
-	devices := appState.DevicesSnapshot()
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(devices); err != nil {
-		http.Error(w, "Failed to encode devices", http.StatusInternalServerError)
+// sseKeepAlive is the interval of the comments sent to keep idle event streams open.
+var sseKeepAlive = 15 * time.Second
+
+// handleEvents streams events as Server-Sent Events. The types query parameter selects a
+// comma separated list of event types, e.g. /events?types=device_discovered,device_offline.
+// Clients that cannot keep up are disconnected and expected to reconnect.
+func handleEvents(w http.ResponseWriter, r *http.Request, broker *events.Broker) {
+	types, err := events.ParseTypes(strings.Join(r.URL.Query()["types"], ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := broker.Subscribe(events.DefaultBufferSize, types...)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
 }
 
 func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
@@ -129,3 +506,39 @@ func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.Ap
 		return
 	}
 }
+
+// portScanHistory is the response body of the port scan history endpoint.
+type portScanHistory struct {
+	IP      string                     `json:"ip"`
+	History []discovery.PortScanResult `json:"history"`
+	Changes *discovery.PortChanges     `json:"changes"`
+}
+
+// handlePortScanHistory returns the port scan history of a device, oldest first,
+// including the ports opened/closed by the most recent scan (null with fewer than two scans).
+func handlePortScanHistory(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+	ipStr := r.PathValue("ip")
+	if net.ParseIP(ipStr) == nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	device, ok := appState.GetDevice(ipStr)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := portScanHistory{IP: ipStr, History: device.PortScanHistory()}
+	if resp.History == nil {
+		resp.History = []discovery.PortScanResult{}
+	}
+	if n := len(resp.History); n > 1 {
+		resp.Changes = discovery.DiffPorts(resp.History[n-2].OpenPorts, resp.History[n-1].OpenPorts)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Failed to encode port scan history", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+// scope is the access level of a daemon API request.
+type scope int
+
+const (
+	scopeNone scope = iota
+	// scopeRead allows reading devices, jobs, events and metrics.
+	scopeRead
+	// scopeAdmin additionally allows starting scans and changing devices.
+	scopeAdmin
+)
+
+// daemonAuth checks the bearer tokens of daemon API requests. The zero value disables
+// authentication, every request then has the admin scope.
+type daemonAuth struct {
+	token      string
+	adminToken string
+}
+
+// loadDaemonAuth reads the configured tokens, from their files when set.
+func loadDaemonAuth(cfg config.DaemonAuthConfig) (daemonAuth, error) {
+	token, err := readToken(cfg.Token, cfg.TokenFile)
+	if err != nil {
+		return daemonAuth{}, err
+	}
+	adminToken, err := readToken(cfg.AdminToken, cfg.AdminTokenFile)
+	if err != nil {
+		return daemonAuth{}, err
+	}
+	return daemonAuth{token: token, adminToken: adminToken}, nil
+}
+
+func readToken(token, path string) (string, error) {
+	if path == "" {
+		return token, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	token = strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+func (a daemonAuth) enabled() bool {
+	return a.token != "" || a.adminToken != ""
+}
+
+// scope returns the scope granted by the token of r. The token is taken from the
+// Authorization header, or the access_token query parameter for clients that cannot set
+// headers, such as EventSource and browser WebSockets. Requests on the Unix socket need no
+// token, the file mode of the socket controls who can connect.
+func (a daemonAuth) scope(r *http.Request) scope {
+	if !a.enabled() || viaUnixSocket(r) {
+		return scopeAdmin
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	switch {
+	case token == "":
+		return scopeNone
+	case tokenEqual(token, a.adminToken):
+		return scopeAdmin
+	case tokenEqual(token, a.token):
+		return scopeRead
+	}
+	return scopeNone
+}
+
+// sameOrigin reports whether r has no Origin header, as sent by non-browser clients, or an
+// Origin with the host of the request, i.e. a page served by the daemon itself.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		// including the opaque "null" origin of sandboxed pages and local files
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func tokenEqual(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// requireScope wraps next so it only serves requests with at least the given scope.
+func (a daemonAuth) requireScope(s scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch granted := a.scope(r); {
+		case granted >= s:
+			next(w, r)
+		case granted == scopeNone:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="whosthere"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		default:
+			http.Error(w, "Forbidden, requires the admin token", http.StatusForbidden)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestLoadDaemonAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin_token")
+	require.NoError(t, os.WriteFile(path, []byte("admin-secret\n"), 0o600))
+
+	auth, err := loadDaemonAuth(config.DaemonAuthConfig{Token: "read-secret", AdminTokenFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, daemonAuth{token: "read-secret", adminToken: "admin-secret"}, auth)
+	assert.True(t, auth.enabled())
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+	_, err = loadDaemonAuth(config.DaemonAuthConfig{TokenFile: empty})
+	assert.ErrorContains(t, err, "is empty")
+
+	_, err = loadDaemonAuth(config.DaemonAuthConfig{TokenFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+
+	auth, err = loadDaemonAuth(config.DaemonAuthConfig{})
+	require.NoError(t, err)
+	assert.False(t, auth.enabled())
+}
+
+func TestDaemonAuth_Scopes(t *testing.T) {
+	d := newTestDaemon(t)
+	d.auth = daemonAuth{token: "read-secret", adminToken: "admin-secret"}
+
+	request := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return serveTestDaemon(d, req)
+	}
+
+	rec := request(http.MethodGet, "/devices", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="whosthere"`, rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/devices", "wrong").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/devices", "read-secret").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/devices", "admin-secret").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/metrics?access_token=read-secret", "").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/health", "").Code)
+
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/scan", "read-secret").Code)
+	assert.Equal(t, http.StatusAccepted, request(http.MethodPost, "/scan", "admin-secret").Code)
+	assert.Equal(t, 1, d.engine.(*fakeDaemonEngine).triggered)
+}
+
+func TestDaemonAuth_Disabled(t *testing.T) {
+	d := newTestDaemon(t)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/scan", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestWebSocket_ReadTokenCannotRunCommands(t *testing.T) {
+	d := newTestDaemon(t)
+	d.auth = daemonAuth{token: "read-secret", adminToken: "admin-secret"}
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	_, err := websocket.Dial(wsURL, "", srv.URL)
+	require.Error(t, err, "connection without a token should be rejected")
+
+	conn, err := websocket.Dial(wsURL+"?access_token=read-secret", "", srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	resp := wsRoundTrip(t, conn, wsRequest{ID: "1", Type: "devices"})
+	assert.Equal(t, "result", resp.Type)
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "2", Type: "scan"})
+	assert.Equal(t, wsTestResponse{ID: "2", Type: "error", Error: "scan requires the admin token"}, resp)
+	assert.Zero(t, d.engine.(*fakeDaemonEngine).triggered)
+}
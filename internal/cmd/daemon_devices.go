@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// maxDevicesLimit caps the page size of GET /devices.
+const maxDevicesLimit = 1000
+
+// deviceQuery is the parsed query string of GET /devices.
+type deviceQuery struct {
+	filter    *query.Filter
+	seenSince time.Time
+	online    *bool
+	less      query.LessFunc
+	fields    []string
+	limit     int
+	offset    int
+}
+
+// deviceFields returns the JSON keys of a device, which can be selected with the fields parameter.
+var deviceFields = sync.OnceValue(func() []string {
+	data, _ := json.Marshal(discovery.NewDevice(nil))
+	var m map[string]json.RawMessage
+	_ = json.Unmarshal(data, &m)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+})
+
+// parseDeviceQuery parses the filter, sort, field selection and pagination parameters of GET /devices.
+func parseDeviceQuery(values url.Values, now time.Time) (*deviceQuery, error) {
+	q := &deviceQuery{}
+
+	filters := make([]*query.Filter, 0, 5)
+	expr, err := query.ParseFilter(values.Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, expr)
+	for _, p := range []struct{ param, field, op string }{
+		{"vendor", "vendor", "~"},
+		{"name", "name", "~"},
+		{"source", "source", "="},
+		{"port", "port", "="},
+	} {
+		for _, v := range values[p.param] {
+			f, err := query.FieldFilter(p.field, p.op, v)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+	}
+	q.filter = query.And(filters...)
+
+	if v := values.Get("seen_since"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			q.seenSince = t
+		} else if d, err := time.ParseDuration(v); err == nil {
+			q.seenSince = now.Add(-d)
+		} else {
+			return nil, fmt.Errorf("invalid seen_since %q, expected an RFC 3339 time or a duration like 10m", v)
+		}
+	}
+
+	if v := values.Get("online"); v != "" {
+		online, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid online parameter %q", v)
+		}
+		q.online = &online
+	}
+
+	reverse := false
+	if v := values.Get("reverse"); v != "" {
+		if reverse, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid reverse parameter %q", v)
+		}
+	}
+	if q.less, err = query.ParseSort(values.Get("sort"), reverse); err != nil {
+		return nil, err
+	}
+
+	if v := values.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if !slices.Contains(deviceFields(), field) {
+				return nil, fmt.Errorf("invalid field %q, expected one of %s", field, strings.Join(deviceFields(), ", "))
+			}
+			q.fields = append(q.fields, field)
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if q.limit, err = strconv.Atoi(v); err != nil || q.limit < 1 || q.limit > maxDevicesLimit {
+			return nil, fmt.Errorf("invalid limit %q, expected 1 to %d", v, maxDevicesLimit)
+		}
+	}
+	if v := values.Get("cursor"); v != "" {
+		if q.offset, err = decodeCursor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// handleDevices returns the devices matching the query parameters. Paginated responses carry
+// the cursor of the next page in the X-Next-Cursor and Link headers, and every response has
+// an ETag so pollers can use If-None-Match.
+func (d *daemon) handleDevices(w http.ResponseWriter, r *http.Request) {
+	q, err := parseDeviceQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var devices []*discovery.Device
+	for _, device := range d.state.DevicesSnapshot() {
+		if !q.filter.Match(device) {
+			continue
+		}
+		if !q.seenSince.IsZero() && device.LastSeen().Before(q.seenSince) {
+			continue
+		}
+		if q.online != nil && d.tracker.Offline(device.IP()) == *q.online {
+			continue
+		}
+		devices = append(devices, device)
+	}
+	sort.SliceStable(devices, func(i, j int) bool { return q.less(devices[i], devices[j]) })
+
+	total := len(devices)
+	page := devices[min(q.offset, total):]
+	if q.limit > 0 && len(page) > q.limit {
+		page = page[:q.limit]
+		next := encodeCursor(q.offset + q.limit)
+		nextURL := *r.URL
+		values := nextURL.Query()
+		values.Set("cursor", next)
+		nextURL.RawQuery = values.Encode()
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.RequestURI()))
+	}
+
+	body, err := encodeDevices(page, q.fields)
+	if err != nil {
+		http.Error(w, "Failed to encode devices", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// encodeDevices encodes the devices as a JSON array, with only the given fields when set.
+func encodeDevices(devices []*discovery.Device, fields []string) ([]byte, error) {
+	if devices == nil {
+		devices = []*discovery.Device{}
+	}
+	if len(fields) == 0 {
+		data, err := json.Marshal(devices)
+		return append(data, '\n'), err
+	}
+
+	out := make([]map[string]json.RawMessage, 0, len(devices))
+	for _, device := range devices {
+		data, err := json.Marshal(device)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			selected[f] = all[f]
+		}
+		out = append(out, selected)
+	}
+	data, err := json.Marshal(out)
+	return append(data, '\n'), err
+}
+
+// etagMatches reports whether an If-None-Match header matches etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor returns the opaque cursor for a page starting at offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if offset, err := strconv.Atoi(string(bytes.TrimSpace(raw))); err == nil && offset >= 0 {
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDevicesTestDaemon(t *testing.T) *daemon {
+	t.Helper()
+	d := newTestDaemon(t)
+	now := time.Now()
+
+	tv := discovery.NewDevice(net.ParseIP("10.0.0.10"))
+	tv.SetDisplayName("Living Room TV")
+	tv.SetManufacturer("Apple, Inc.")
+	tv.AddSource("mdns")
+	tv.SetOpenPorts(map[string][]int{"tcp": {7000}})
+	tv.SetLastSeen(now)
+
+	printer := discovery.NewDevice(net.ParseIP("10.0.0.2"))
+	printer.SetDisplayName("printer")
+	printer.SetManufacturer("HP")
+	printer.AddSource("ssdp")
+	printer.SetLastSeen(now.Add(-time.Hour))
+
+	nas := discovery.NewDevice(net.ParseIP("10.0.0.3"))
+	nas.SetDisplayName("nas")
+	nas.AddSource("arp")
+	nas.SetOpenPorts(map[string][]int{"tcp": {22, 445}})
+	nas.SetLastSeen(now)
+
+	for _, device := range []*discovery.Device{tv, printer, nas} {
+		d.state.UpsertDevice(device)
+		d.tracker.Observe(device, now)
+	}
+	// mark the printer offline
+	d.tracker.Observe(nas, now.Add(2*time.Hour))
+	d.tracker.Observe(tv, now.Add(2*time.Hour))
+	d.tracker.Sweep(now.Add(2 * time.Hour))
+	return d
+}
+
+func getDeviceIPs(t *testing.T, d *daemon, target string) ([]string, *httptest.ResponseRecorder) {
+	t.Helper()
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var devices []*discovery.Device
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &devices))
+	ips := []string{}
+	for _, device := range devices {
+		ips = append(ips, device.IP().String())
+	}
+	return ips, rec
+}
+
+func TestHandleDevices_Filters(t *testing.T) {
+	d := newDevicesTestDaemon(t)
+
+	tests := map[string][]string{
+		"/devices":                               {"10.0.0.2", "10.0.0.3", "10.0.0.10"},
+		"/devices?vendor=apple":                  {"10.0.0.10"},
+		"/devices?name=living%20room":            {"10.0.0.10"},
+		"/devices?source=arp":                    {"10.0.0.3"},
+		"/devices?port=22":                       {"10.0.0.3"},
+		"/devices?filter=port%3D7000":            {"10.0.0.10"},
+		"/devices?seen_since=30m":                {"10.0.0.3", "10.0.0.10"},
+		"/devices?online=false":                  {"10.0.0.2"},
+		"/devices?online=true&sort=name":         {"10.0.0.10", "10.0.0.3"},
+		"/devices?sort=name&reverse=true":        {"10.0.0.2", "10.0.0.3", "10.0.0.10"},
+		"/devices?source=arp&source=mdns":        {},
+		"/devices?vendor=apple&seen_since=1s":    {"10.0.0.10"},
+		"/devices?name=printer&online=true":      {},
+		"/devices?sort=vendor&fields=ip,sources": {"10.0.0.10", "10.0.0.2", "10.0.0.3"},
+	}
+	for target, want := range tests {
+		t.Run(target, func(t *testing.T) {
+			got, _ := getDeviceIPs(t, d, target)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestHandleDevices_Fields(t *testing.T) {
+	d := newDevicesTestDaemon(t)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/devices?fields=ip,displayName&limit=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"ip":"10.0.0.2","displayName":"printer"}]`, rec.Body.String())
+}
+
+func TestHandleDevices_Pagination(t *testing.T) {
+	d := newDevicesTestDaemon(t)
+
+	got, rec := getDeviceIPs(t, d, "/devices?limit=2")
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, got)
+	assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+	cursor := rec.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, cursor)
+	assert.Contains(t, rec.Header().Get("Link"), `cursor=`+cursor)
+
+	got, rec = getDeviceIPs(t, d, "/devices?limit=2&cursor="+cursor)
+	assert.Equal(t, []string{"10.0.0.10"}, got)
+	assert.Empty(t, rec.Header().Get("X-Next-Cursor"))
+}
+
+func TestHandleDevices_ETag(t *testing.T) {
+	d := newDevicesTestDaemon(t)
+
+	_, rec := getDeviceIPs(t, d, "/devices")
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/devices", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = serveTestDaemon(d, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	device, _ := d.state.GetDevice("10.0.0.2")
+	device.SetDisplayName("office printer")
+	rec = serveTestDaemon(d, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestHandleDevices_InvalidParameters(t *testing.T) {
+	d := newDevicesTestDaemon(t)
+
+	for _, target := range []string{
+		"/devices?filter=color%3Dred",
+		"/devices?name=(",
+		"/devices?seen_since=yesterday",
+		"/devices?online=maybe",
+		"/devices?sort=color",
+		"/devices?fields=ip,password",
+		"/devices?limit=0",
+		"/devices?limit=5000",
+		"/devices?cursor=!!",
+	} {
+		rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/metrics"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// daemonMetrics holds the metrics the daemon accumulates from engine events and port scan
+// jobs. Gauges such as the number of devices are computed from the app state at scrape time.
+type daemonMetrics struct {
+	scanDuration *metrics.HistogramValue
+
+	mu         sync.Mutex
+	scans      float64
+	scanErrors map[string]float64
+	portScans  map[string]float64
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	return &daemonMetrics{
+		scanDuration: metrics.NewHistogram(metrics.DefaultDurationBuckets),
+		scanErrors:   make(map[string]float64),
+		portScans:    map[string]float64{"completed": 0, "failed": 0},
+	}
+}
+
+// observeEvent updates the metrics from an engine event.
+func (m *daemonMetrics) observeEvent(event discovery.Event) {
+	switch event.Type {
+	case discovery.EventScanCompleted:
+		m.mu.Lock()
+		m.scans++
+		m.mu.Unlock()
+		if event.Stats != nil {
+			m.scanDuration.Observe(event.Stats.Duration.Seconds())
+		}
+	case discovery.EventError:
+		scanner := "engine"
+		var scannerErr *discovery.ScannerError
+		if errors.As(event.Error, &scannerErr) {
+			scanner = scannerErr.Scanner
+		}
+		m.mu.Lock()
+		m.scanErrors[scanner]++
+		m.mu.Unlock()
+	}
+}
+
+// observePortScan counts a finished port scan job.
+func (m *daemonMetrics) observePortScan(err error) {
+	result := "completed"
+	if err != nil {
+		result = "failed"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.portScans[result]++
+}
+
+// families returns the accumulated metrics, maps are copied so they can be written after
+// the lock is released.
+func (m *daemonMetrics) families() []metrics.Family {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scanErrors := make(map[string]float64, len(m.scanErrors))
+	for k, v := range m.scanErrors {
+		scanErrors[k] = v
+	}
+	portScans := make(map[string]float64, len(m.portScans))
+	for k, v := range m.portScans {
+		portScans[k] = v
+	}
+
+	return []metrics.Family{
+		metrics.NewFamily("whosthere_scans_total", "Number of completed scans.", metrics.Counter, m.scans),
+		m.scanDuration.Family("whosthere_scan_duration_seconds", "Duration of completed scans in seconds."),
+		metrics.LabeledFamily("whosthere_scan_errors_total", "Number of scan errors per scanner.", metrics.Counter, "scanner", scanErrors),
+		metrics.LabeledFamily("whosthere_port_scans_total", "Number of device port scan jobs per result.", metrics.Counter, "result", portScans),
+	}
+}
+
+// collectMetrics returns all daemon metrics, ordered by name within each group.
+func (d *daemon) collectMetrics() []metrics.Family {
+	devices := d.state.DevicesSnapshot()
+	byVendor := make(map[string]float64)
+	bySource := make(map[string]float64)
+	for _, device := range devices {
+		vendor := device.Manufacturer()
+		if vendor == "" {
+			vendor = "unknown"
+		}
+		byVendor[vendor]++
+		for source := range device.Sources() {
+			bySource[source]++
+		}
+	}
+
+	families := []metrics.Family{
+		metrics.NewFamily("whosthere_devices", "Number of known devices.", metrics.Gauge, float64(len(devices))),
+		metrics.NewFamily("whosthere_devices_online", "Number of devices seen recently.", metrics.Gauge, float64(d.tracker.Online())),
+		metrics.LabeledFamily("whosthere_devices_by_vendor", "Number of known devices per vendor.", metrics.Gauge, "vendor", byVendor),
+		metrics.LabeledFamily("whosthere_devices_by_source", "Number of known devices per discovery source.", metrics.Gauge, "source", bySource),
+	}
+	families = append(families, d.metrics.families()...)
+
+	if stats, ok := d.engine.SweepStats(); ok {
+		lastSweep := 0.0
+		if !stats.LastSweep.IsZero() {
+			lastSweep = float64(stats.LastSweep.Unix())
+		}
+		families = append(families,
+			metrics.NewFamily("whosthere_sweeps_total", "Number of completed subnet sweeps.", metrics.Counter, float64(stats.Sweeps)),
+			metrics.NewFamily("whosthere_sweeps_interrupted_total", "Number of subnet sweeps cancelled before completion.", metrics.Counter, float64(stats.Interrupted)),
+			metrics.NewFamily("whosthere_sweep_targets", "Number of IPs targeted by the last subnet sweep.", metrics.Gauge, float64(stats.LastTargets)),
+			metrics.NewFamily("whosthere_sweep_duration_seconds", "Duration of the last subnet sweep in seconds.", metrics.Gauge, stats.LastDuration.Seconds()),
+			metrics.NewFamily("whosthere_sweep_last_timestamp_seconds", "Unix time of the last subnet sweep.", metrics.Gauge, lastSweep),
+		)
+	}
+
+	return append(families,
+		metrics.NewFamily("whosthere_engine_events_dropped_total", "Number of engine events dropped because the event channel was full.", metrics.Counter, float64(d.engine.DroppedEvents())),
+		metrics.NewFamily("whosthere_event_subscribers", "Number of active event stream subscribers.", metrics.Gauge, float64(d.broker.Subscribers())),
+		metrics.NewFamily("whosthere_event_subscribers_dropped_total", "Number of event stream subscribers disconnected for not keeping up.", metrics.Counter, float64(d.broker.SlowDisconnects())),
+	)
+}
+
+// handleMetrics serves the daemon metrics in the Prometheus text exposition format.
+func (d *daemon) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	_ = metrics.Write(w, d.collectMetrics())
+}
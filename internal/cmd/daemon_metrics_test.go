@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/metrics"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	d := newTestDaemon(t)
+	engine := d.engine.(*fakeDaemonEngine)
+	engine.dropped = 4
+	engine.sweep = &discovery.SweepStats{Sweeps: 2, LastTargets: 254, LastDuration: 1500 * time.Millisecond}
+
+	apple := discovery.NewDevice(net.ParseIP("10.0.0.1"))
+	apple.SetManufacturer("Apple, Inc.")
+	apple.AddSource("arp")
+	apple.AddSource("mdns")
+	unknown := discovery.NewDevice(net.ParseIP("10.0.0.2"))
+	unknown.AddSource("arp")
+
+	engineEvents := make(chan discovery.Event, 8)
+	engineEvents <- discovery.NewDeviceEvent(apple)
+	engineEvents <- discovery.NewDeviceEvent(unknown)
+	engineEvents <- discovery.NewErrorEvent(&discovery.ScannerError{Scanner: "mdns", Err: errors.New("no multicast")})
+	engineEvents <- discovery.NewErrorEvent(errors.New("scan failed"))
+	engineEvents <- discovery.NewScanCompletedEvent(&discovery.ScanStats{Count: 2, Duration: 3 * time.Second})
+	close(engineEvents)
+	d.forwardEngineEvents(engineEvents)
+
+	_, err := d.portScan(t.Context(), "10.0.0.9", nil)
+	d.metrics.observePortScan(err)
+	job := d.submitPortScan("10.0.0.1", []int{22})
+	_, err = d.jobs.Wait(t.Context(), job.ID)
+	require.NoError(t, err)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE whosthere_devices gauge",
+		"whosthere_devices 2",
+		"whosthere_devices_online 2",
+		`whosthere_devices_by_vendor{vendor="Apple, Inc."} 1`,
+		`whosthere_devices_by_vendor{vendor="unknown"} 1`,
+		`whosthere_devices_by_source{source="arp"} 2`,
+		`whosthere_devices_by_source{source="mdns"} 1`,
+		"whosthere_scans_total 1",
+		`whosthere_scan_duration_seconds_bucket{le="2.5"} 0`,
+		`whosthere_scan_duration_seconds_bucket{le="5"} 1`,
+		"whosthere_scan_duration_seconds_sum 3",
+		`whosthere_scan_errors_total{scanner="engine"} 1`,
+		`whosthere_scan_errors_total{scanner="mdns"} 1`,
+		`whosthere_port_scans_total{result="completed"} 1`,
+		`whosthere_port_scans_total{result="failed"} 1`,
+		"whosthere_sweeps_total 2",
+		"whosthere_sweep_targets 254",
+		"whosthere_sweep_duration_seconds 1.5",
+		"whosthere_engine_events_dropped_total 4",
+		"whosthere_event_subscribers_dropped_total 0",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+func TestHandleMetrics_WithoutSweeper(t *testing.T) {
+	d := newTestDaemon(t)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "whosthere_devices 0\n")
+	assert.NotContains(t, rec.Body.String(), "whosthere_sweeps_total")
+}
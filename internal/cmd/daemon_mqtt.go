@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/mqtt"
+)
+
+// startMQTT publishes the devices of the daemon to the MQTT broker, it does nothing when
+// MQTT is disabled. The returned function publishes the queued events and disconnects from
+// the broker, without waiting longer than until ctx is done.
+func (d *daemon) startMQTT(cfg config.DaemonMQTTConfig) func(ctx context.Context) {
+	if !cfg.Enabled {
+		return func(context.Context) {}
+	}
+	publisher := mqtt.NewPublisher(cfg, d.mqttSnapshot, mqtt.WithLogger(d.logger))
+	unsubscribe := d.subscribeSink(publisher.Types(), publisher.Notify, "MQTT publisher")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publisher.Run(ctx)
+	}()
+	d.logger.Log(context.Background(), slog.LevelInfo, "publishing to MQTT broker", "broker", cfg.Broker, "home_assistant", cfg.HomeAssistant)
+
+	return func(ctx context.Context) {
+		unsubscribe()
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// mqttSnapshot returns the known devices and whether they are online, published after
+// every (re)connect to the broker.
+func (d *daemon) mqttSnapshot() []mqtt.DeviceState {
+	devices := d.state.DevicesSnapshot()
+	out := make([]mqtt.DeviceState, 0, len(devices))
+	for _, dev := range devices {
+		out = append(out, mqtt.DeviceState{Device: dev, Online: !d.tracker.Offline(dev.IP())})
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_MQTTSnapshot(t *testing.T) {
+	d := newTestDaemon(t)
+	now := time.Now()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		dev := discovery.NewDevice(net.ParseIP(ip))
+		dev.SetLastSeen(now)
+		d.state.UpsertDevice(dev)
+		d.tracker.Observe(dev, now)
+	}
+	d.tracker.Sweep(now.Add(2 * time.Hour))
+	d.tracker.Observe(discovery.NewDevice(net.ParseIP("10.0.0.2")), now.Add(2*time.Hour))
+
+	online := make(map[string]bool)
+	for _, s := range d.mqttSnapshot() {
+		online[s.Device.IP().String()] = s.Online
+	}
+	assert.Equal(t, map[string]bool{"10.0.0.1": false, "10.0.0.2": true}, online)
+}
+
+func TestDaemon_MQTTDisabled(t *testing.T) {
+	d := newTestDaemon(t)
+	stop := d.startMQTT(config.DaemonMQTTConfig{Broker: "tcp://127.0.0.1:1"})
+	require.NotNil(t, stop)
+	stop(context.Background())
+}
+
+func TestDaemon_MQTTStopsWhileReconnecting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	d := newTestDaemon(t)
+	cfg := config.DefaultConfig().Daemon.MQTT
+	cfg.Enabled = true
+	cfg.Broker = "tcp://" + addr
+	stop := d.startMQTT(cfg)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stop(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("MQTT publisher did not stop")
+	}
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/jobs"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// jobPortScan is the job type of device port scans.
+const jobPortScan = "portscan"
+
+// scanResponse is the response body of POST /scan.
+type scanResponse struct {
+	Status string               `json:"status"`
+	Stats  *discovery.ScanStats `json:"stats,omitempty"`
+}
+
+// portScanRequest is the optional request body of POST /devices/{ip}/portscan.
+type portScanRequest struct {
+	Ports string `json:"ports"`
+}
+
+// devicePorts is the response body of GET /devices/{ip}/ports.
+type devicePorts struct {
+	IP           string           `json:"ip"`
+	OpenPorts    map[string][]int `json:"openPorts"`
+	LastPortScan *time.Time       `json:"lastPortScan"`
+	// Job is the most recent port scan job of the device, if any.
+	Job *jobs.Job `json:"job,omitempty"`
+}
+
+// handleScan starts a scan right away. With ?wait=true the response is sent when the scan
+// completed, including its stats.
+func (d *daemon) handleScan(w http.ResponseWriter, r *http.Request) {
+	wait, err := boolParam(r, "wait")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !wait {
+		if !d.engine.TriggerScan() {
+			http.Error(w, "Engine is not running", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, scanResponse{Status: "started"})
+		return
+	}
+
+	// subscribe before triggering, so the start of the triggered scan cannot be missed
+	sub := d.broker.Subscribe(events.DefaultBufferSize, events.TypeScanStarted, events.TypeScanCompleted)
+	defer sub.Cancel()
+
+	if !d.engine.TriggerScan() {
+		http.Error(w, "Engine is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	// a scan that was already running completes first, wait for the triggered one
+	started := false
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				http.Error(w, "Lost track of the scan", http.StatusInternalServerError)
+				return
+			}
+			switch {
+			case msg.Type == events.TypeScanStarted:
+				started = true
+			case msg.Type == events.TypeScanCompleted && started:
+				writeJSON(w, http.StatusOK, scanResponse{Status: "completed", Stats: msg.Stats})
+				return
+			}
+		}
+	}
+}
+
+// handlePortScan starts a port scan job for a device. The ports are taken from the ports
+// query parameter or the JSON body ({"ports": "22,80,8000-8100"}), the configured ports are
+// scanned when neither is set. With ?wait=true the response is sent when the job is done.
+func (d *daemon) handlePortScan(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	if net.ParseIP(ip) == nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	if _, ok := d.state.GetDevice(ip); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	wait, err := boolParam(r, "wait")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	spec := r.URL.Query().Get("ports")
+	if spec == "" && r.Body != nil {
+		var req portScanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec = req.Ports
+	}
+	var ports []int
+	if spec != "" {
+		if ports, err = discovery.ParsePortSpec(spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	job := d.submitPortScan(ip, ports)
+	if !wait {
+		writeJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	job, err = d.jobs.Wait(r.Context(), job.ID)
+	if err != nil || !job.Done() {
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleDevicePorts returns the open ports of a device and its most recent port scan job.
+func (d *daemon) handleDevicePorts(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	if net.ParseIP(ip) == nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	device, ok := d.state.GetDevice(ip)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := devicePorts{IP: ip, OpenPorts: device.OpenPorts()}
+	if resp.OpenPorts == nil {
+		resp.OpenPorts = map[string][]int{}
+	}
+	if t := device.LastPortScan(); !t.IsZero() {
+		resp.LastPortScan = &t
+	}
+	if job, ok := d.jobs.Latest(jobPortScan, ip); ok {
+		resp.Job = &job
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (d *daemon) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, err := d.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// submitPortScan queues a port scan of a known device, or returns the pending one of the
+// same ports.
+func (d *daemon) submitPortScan(ip string, ports []int) jobs.Job {
+	return d.jobs.Submit(jobPortScan, ip, formatPortSpec(ports), func(ctx context.Context) (any, error) {
+		result, err := d.portScan(ctx, ip, ports)
+		d.metrics.observePortScan(err)
+		return result, err
+	})
+}
+
+// portScan scans the given TCP ports (the configured ones when empty) of a known device and
+// records the result, which emits a ports changed event when the open ports differ from the
+// previous scan.
+func (d *daemon) portScan(ctx context.Context, ip string, ports []int) (hostPorts, error) {
+	device, ok := d.state.GetDevice(ip)
+	if !ok {
+		return hostPorts{}, fmt.Errorf("device %s not found", ip)
+	}
+	cfg := d.config()
+	if len(ports) == 0 {
+		ports = cfg.PortScanner.TCP
+	}
+
+	var mu sync.Mutex
+	open := []int{}
+	err := d.currentPortScanner().ScanHosts(ctx, []string{ip}, ports, cfg.PortScanner.Timeout, func(_ string, port int) {
+		mu.Lock()
+		defer mu.Unlock()
+		open = append(open, port)
+	})
+	if err != nil {
+		return hostPorts{}, err
+	}
+
+	sort.Ints(open)
+	d.engine.RecordPortScan(device, map[string][]int{"tcp": open})
+	return hostPorts{IP: ip, TCP: open}, nil
+}
+
+// formatPortSpec returns the sorted, unique ports as a port spec with ranges, e.g.
+// "22,80,8000-8100", empty for the configured ports.
+func formatPortSpec(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// boolParam parses an optional boolean query parameter.
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter %q", name, v)
+	}
+	return b, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/jobs"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveTestDaemon(d *daemon, req *http.Request) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleScan(t *testing.T) {
+	d := newTestDaemon(t)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/scan", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"status":"started"}`, rec.Body.String())
+	assert.Equal(t, 1, d.engine.(*fakeDaemonEngine).triggered)
+
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/scan?wait=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/scan", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandleScan_RejectsCrossSite(t *testing.T) {
+	d := newTestDaemon(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/scan", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	assert.Equal(t, http.StatusForbidden, serveTestDaemon(d, req).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/devices/192.168.1.10/portscan?ports=1-65535", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, serveTestDaemon(d, req).Code)
+	assert.Equal(t, 0, d.engine.(*fakeDaemonEngine).triggered)
+	assert.Empty(t, d.jobs.List())
+
+	req = httptest.NewRequest(http.MethodPost, "/scan", nil)
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	assert.Equal(t, http.StatusAccepted, serveTestDaemon(d, req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/devices", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	assert.Equal(t, http.StatusOK, serveTestDaemon(d, req).Code, "reads are protected by the same-origin policy")
+}
+
+func TestFormatPortSpec(t *testing.T) {
+	assert.Equal(t, "", formatPortSpec(nil))
+	assert.Equal(t, "22", formatPortSpec([]int{22}))
+	assert.Equal(t, "20-25,443,8000-8001", formatPortSpec([]int{20, 21, 22, 23, 24, 25, 443, 8000, 8001}))
+}
+
+func TestHandleScan_Wait(t *testing.T) {
+	d := newTestDaemon(t)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/scan?wait=true", nil))
+	}()
+
+	require.Eventually(t, func() bool { return d.broker.Subscribers() == 1 }, time.Second, time.Millisecond)
+	// completion of a scan that was already running is not the triggered scan
+	d.broker.Publish(events.Message{Type: events.TypeScanCompleted, Stats: &discovery.ScanStats{Count: 1}})
+	d.broker.Publish(events.Message{Type: events.TypeScanStarted})
+	d.broker.Publish(events.Message{Type: events.TypeScanCompleted, Stats: &discovery.ScanStats{Count: 2, Duration: time.Second}})
+
+	rec := <-done
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"completed","stats":{"count":2,"duration":"1.0s"}}`, rec.Body.String())
+}
+
+func TestHandlePortScan(t *testing.T) {
+	d := newTestDaemon(t)
+	d.state.UpsertDevice(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/devices/10.0.0.1/portscan?wait=true", strings.NewReader(`{"ports":"20-25,443"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, jobs.StatusCompleted, job.Status)
+	assert.Equal(t, "10.0.0.1", job.Target)
+	assert.Equal(t, "20-25,443", job.Params)
+	assert.Equal(t, map[string][]int{"tcp": {22, 443}}, d.engine.(*fakeDaemonEngine).recorded)
+
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/devices/10.0.0.1/ports", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var ports devicePorts
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ports))
+	assert.Equal(t, []int{22, 443}, ports.OpenPorts["tcp"])
+	assert.NotNil(t, ports.LastPortScan)
+	require.NotNil(t, ports.Job)
+	assert.Equal(t, job.ID, ports.Job.ID)
+
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	var list []jobs.Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list, 1)
+
+	rec = serveTestDaemon(d, httptest.NewRequest(http.MethodPost, "/devices/10.0.0.1/portscan?ports=22", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestHandlePortScan_Errors(t *testing.T) {
+	d := newTestDaemon(t)
+	d.state.UpsertDevice(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+
+	tests := map[string]struct {
+		req  *http.Request
+		code int
+	}{
+		"invalid ip":     {httptest.NewRequest(http.MethodPost, "/devices/nope/portscan", nil), http.StatusBadRequest},
+		"unknown device": {httptest.NewRequest(http.MethodPost, "/devices/10.0.0.2/portscan", nil), http.StatusNotFound},
+		"invalid ports":  {httptest.NewRequest(http.MethodPost, "/devices/10.0.0.1/portscan?ports=99999", nil), http.StatusBadRequest},
+		"invalid body":   {httptest.NewRequest(http.MethodPost, "/devices/10.0.0.1/portscan", strings.NewReader("{")), http.StatusBadRequest},
+		"unknown ports":  {httptest.NewRequest(http.MethodGet, "/devices/10.0.0.2/ports", nil), http.StatusNotFound},
+		"unknown job":    {httptest.NewRequest(http.MethodGet, "/jobs/42", nil), http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.code, serveTestDaemon(d, tt.req).Code)
+		})
+	}
+	assert.Empty(t, d.jobs.List())
+}
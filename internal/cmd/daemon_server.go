@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+// daemonShutdownTimeout bounds the time the daemon waits for requests, port scan jobs and
+// the running scan to finish when it is stopped.
+var daemonShutdownTimeout = 10 * time.Second
+
+// certReloader serves the TLS certificate of the HTTP API, so a renewed certificate is
+// picked up on reload without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(cfg config.DaemonTLSConfig) (*certReloader, error) {
+	c := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads the certificate and key files, keeping the current certificate on failure.
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	return nil
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// newDaemonServer returns the HTTP server of the API. Request contexts are cancelled when
+// baseCtx is, so long-lived requests such as event streams end when shutting down. The
+// same server also serves the Unix socket when enabled.
+func newDaemonServer(baseCtx context.Context, addr string, handler http.Handler, certs *certReloader) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return baseCtx },
+		ConnContext:       markUnixSocket,
+	}
+	if certs != nil {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certs.getCertificate}
+	}
+	return srv
+}
+
+// listenAndServe serves the API until the server is shut down, over TLS when configured.
+func listenAndServe(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// reload applies a reloaded config. The tokens, the port scanner settings and the webhooks
+// take effect right away, the listen address, TLS files, scan and MQTT settings after a
+// restart. Nothing is applied when the config is invalid.
+func (d *daemon) reload(cfg *config.Config, portScanner daemonPortScanner) error {
+	auth, err := loadDaemonAuth(cfg.Daemon.Auth)
+	if err != nil {
+		return err
+	}
+	notifier, err := newWebhookNotifier(cfg.Daemon.Webhooks)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.cfg = cfg
+	d.portScanner = portScanner
+	d.auth = auth
+	d.mu.Unlock()
+	d.setWebhooks(notifier)
+	return nil
+}
+
+// config returns the current config, it is replaced on reload.
+func (d *daemon) config() *config.Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.cfg
+}
+
+func (d *daemon) currentPortScanner() daemonPortScanner {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.portScanner
+}
+
+func (d *daemon) currentAuth() daemonAuth {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.auth
+}
+
+// shutdown stops accepting requests, marks the daemon as not ready and runs the stop
+// functions in order, giving up when they take longer than the shutdown timeout. The stop
+// functions get a context with that deadline, e.g. to finish sending pending events.
+func (d *daemon) shutdown(srv *http.Server, cancelRequests context.CancelFunc, stops ...func(context.Context)) error {
+	d.stopping.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), daemonShutdownTimeout)
+	defer cancel()
+
+	cancelRequests()
+	err := srv.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, stop := range stops {
+			stop(ctx)
+		}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.New("timed out waiting for the scan, port scan jobs and event deliveries to stop")
+	}
+	return err
+}
+
+// handleLive reports whether the daemon is alive, i.e. its discovery engine did not stop
+// unexpectedly. Unlike readiness it stays OK while starting and shutting down.
+func (d *daemon) handleLive(w http.ResponseWriter, _ *http.Request) {
+	if d.engineStopped.Load() && !d.stopping.Load() {
+		http.Error(w, "discovery engine stopped", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+// handleReady reports whether the daemon has device data to serve: it becomes ready when
+// the first scan completed and stops being ready when shutting down.
+func (d *daemon) handleReady(w http.ResponseWriter, _ *http.Request) {
+	switch {
+	case d.stopping.Load():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case !d.ready.Load():
+		http.Error(w, "waiting for the first scan to complete", http.StatusServiceUnavailable)
+	default:
+		_, _ = w.Write([]byte("OK"))
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_LivenessAndReadiness(t *testing.T) {
+	d := newTestDaemon(t)
+	status := func(path string) int {
+		return serveTestDaemon(d, httptest.NewRequest(http.MethodGet, path, nil)).Code
+	}
+
+	assert.Equal(t, http.StatusOK, status("/livez"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+
+	engineEvents := make(chan discovery.Event, 1)
+	engineEvents <- discovery.NewScanCompletedEvent(&discovery.ScanStats{})
+	close(engineEvents)
+	d.forwardEngineEvents(engineEvents)
+	assert.Equal(t, http.StatusOK, status("/readyz"))
+
+	engineEvents = make(chan discovery.Event, 1)
+	engineEvents <- discovery.NewEngineStoppedEvent()
+	close(engineEvents)
+	d.forwardEngineEvents(engineEvents)
+	assert.Equal(t, http.StatusServiceUnavailable, status("/livez"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+
+	d.stopping.Store(true)
+	assert.Equal(t, http.StatusOK, status("/livez"), "a stopped engine is expected when shutting down")
+}
+
+func TestDaemon_Reload(t *testing.T) {
+	d := newTestDaemon(t)
+	d.auth = daemonAuth{token: "old"}
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Auth.Token = "new"
+	cfg.PortScanner.TCP = []int{443}
+	scanner := &fakePortScanner{open: []int{443}}
+	require.NoError(t, d.reload(cfg, scanner))
+
+	req := httptest.NewRequest(http.MethodGet, "/devices", nil)
+	req.Header.Set("Authorization", "Bearer old")
+	assert.Equal(t, http.StatusUnauthorized, serveTestDaemon(d, req).Code)
+	req.Header.Set("Authorization", "Bearer new")
+	assert.Equal(t, http.StatusOK, serveTestDaemon(d, req).Code)
+	assert.Same(t, cfg, d.config())
+	assert.Same(t, scanner, d.currentPortScanner())
+
+	cfg = config.DefaultConfig()
+	cfg.Daemon.Auth.TokenFile = filepath.Join(t.TempDir(), "missing")
+	require.Error(t, d.reload(cfg, scanner))
+	assert.Equal(t, "new", d.currentAuth().token, "a failed reload keeps the current config")
+}
+
+func TestDaemon_ShutdownEndsEventStreams(t *testing.T) {
+	d := newTestDaemon(t)
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := newDaemonServer(requestCtx, "", mux, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/events")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Eventually(t, func() bool { return d.broker.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	var stopped []string
+	start := time.Now()
+	err = d.shutdown(srv, cancelRequests,
+		func(context.Context) { stopped = append(stopped, "jobs") },
+		func(context.Context) { stopped = append(stopped, "engine") },
+	)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), daemonShutdownTimeout)
+	assert.Equal(t, []string{"jobs", "engine"}, stopped)
+	assert.Equal(t, 0, d.broker.Subscribers())
+	assert.True(t, d.stopping.Load())
+}
+
+func TestDaemon_ShutdownTimeout(t *testing.T) {
+	old := daemonShutdownTimeout
+	daemonShutdownTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { daemonShutdownTimeout = old })
+
+	d := newTestDaemon(t)
+	srv := newDaemonServer(context.Background(), "", http.NewServeMux(), nil)
+	block := make(chan struct{})
+	defer close(block)
+
+	err := d.shutdown(srv, func() {}, func(context.Context) { <-block })
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	_, err := newCertReloader(config.DaemonTLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.Error(t, err)
+
+	writeTestCertificate(t, certFile, keyFile, "first")
+	certs, err := newCertReloader(config.DaemonTLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	first, err := certs.getCertificate(nil)
+	require.NoError(t, err)
+
+	writeTestCertificate(t, certFile, keyFile, "second")
+	require.NoError(t, certs.load())
+	second, err := certs.getCertificate(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Certificate[0], second.Certificate[0])
+
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	require.Error(t, certs.load())
+	current, _ := certs.getCertificate(nil)
+	assert.Same(t, second, current, "a failed reload keeps the current certificate")
+}
+
+// writeTestCertificate writes a self-signed certificate and its key as PEM files.
+func writeTestCertificate(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+}
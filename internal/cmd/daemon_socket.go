@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/paths"
+)
+
+// daemonSocketName is the file name of the socket in the runtime directory.
+const daemonSocketName = "daemon.sock"
+
+// unixSocketKey marks the context of connections accepted on the Unix socket.
+type unixSocketKey struct{}
+
+// daemonSocketPath returns the configured socket path, daemon.sock in the runtime directory
+// when not set.
+func daemonSocketPath(cfg config.DaemonSocketConfig) (string, error) {
+	if cfg.Path != "" {
+		return cfg.Path, nil
+	}
+	dir, err := paths.RuntimeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve runtime directory: %w", err)
+	}
+	return filepath.Join(dir, daemonSocketName), nil
+}
+
+// listenDaemonSocket listens on the Unix socket at path with the given file mode, replacing
+// a socket left behind by a daemon that did not shut down cleanly. The socket file is
+// removed when the listener is closed.
+func listenDaemonSocket(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := listenUnix(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	// the umask only removes permissions, this also applies the ones it would have masked
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("set socket mode: %w", err)
+	}
+	return ln, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use, is another daemon running?", path)
+	}
+	return os.Remove(path)
+}
+
+// serveDaemonSocket serves the API on ln until the server is shut down. The socket is never
+// served over TLS, its file mode already restricts who can connect.
+func serveDaemonSocket(srv *http.Server, ln net.Listener) error {
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// markUnixSocket is the ConnContext of the daemon server, it marks connections accepted on
+// the Unix socket.
+func markUnixSocket(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, unixSocketKey{}, true)
+	}
+	return ctx
+}
+
+// viaUnixSocket reports whether r was received on the Unix socket.
+func viaUnixSocket(r *http.Request) bool {
+	v, _ := r.Context().Value(unixSocketKey{}).(bool)
+	return v
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix listens on the Unix socket at path, the file mode is applied afterwards.
+func listenUnix(path string, _ os.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
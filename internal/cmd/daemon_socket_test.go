@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unixSocketClient returns an HTTP client that connects to the socket at path.
+func unixSocketClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestDaemonSocketPath(t *testing.T) {
+	path, err := daemonSocketPath(config.DaemonSocketConfig{Path: "/run/whosthere.sock"})
+	require.NoError(t, err)
+	assert.Equal(t, "/run/whosthere.sock", path)
+
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	path, err = daemonSocketPath(config.DaemonSocketConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(runtimeDir, "whosthere", daemonSocketName), path)
+}
+
+func TestListenDaemonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), daemonSocketName)
+
+	ln, err := listenDaemonSocket(path, 0o660)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+	}
+
+	_, err = listenDaemonSocket(path, 0o600)
+	assert.ErrorContains(t, err, "is in use")
+
+	require.NoError(t, ln.Close())
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "closing the listener removes the socket")
+
+	notSocket := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notSocket, nil, 0o600))
+	_, err = listenDaemonSocket(notSocket, 0o600)
+	assert.ErrorContains(t, err, "is not a socket")
+}
+
+func TestListenDaemonSocket_ReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), daemonSocketName)
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	// leave the socket file behind, as after a crash
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	ln, err = listenDaemonSocket(path, 0o600)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
+
+func TestDaemon_UnixSocketSkipsTokens(t *testing.T) {
+	d := newTestDaemon(t)
+	d.auth = daemonAuth{token: "read-secret", adminToken: "admin-secret"}
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+	srv := newDaemonServer(context.Background(), "", mux, nil)
+	defer func() { _ = srv.Close() }()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(tcp) }()
+
+	path := filepath.Join(t.TempDir(), daemonSocketName)
+	socket, err := listenDaemonSocket(path, 0o600)
+	require.NoError(t, err)
+	go func() { _ = serveDaemonSocket(srv, socket) }()
+
+	resp, err := http.Get("http://" + tcp.Addr().String() + "/webhooks/deliveries")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = unixSocketClient(path).Get("http://whosthere/webhooks/deliveries")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "admin endpoints are served on the socket without a token")
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package cmd
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix listens on the Unix socket at path with the umask set so the socket is created
+// with at most the permissions of mode, instead of being accessible by others until its mode
+// is set. The umask is process wide, the socket is created on startup before the daemon
+// creates other files.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	old := syscall.Umask(int(^mode.Perm() & os.ModePerm))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
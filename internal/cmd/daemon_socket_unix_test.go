@@ -0,0 +1,28 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnix_CreatesSocketWithMode(t *testing.T) {
+	old := syscall.Umask(0)
+	defer syscall.Umask(old)
+
+	path := filepath.Join(t.TempDir(), daemonSocketName)
+	ln, err := listenUnix(path, 0o600)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "created without group and other permissions")
+	assert.Equal(t, 0, syscall.Umask(0), "umask is restored")
+}
@@ -1,10 +1,30 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/aliases"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/jobs"
+	"github.com/ramonvermeulen/whosthere/internal/core/known"
+	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewDaemonCommand(t *testing.T) {
@@ -31,3 +51,215 @@ func TestNewDaemonCommand_HasAllInheritedPersistentFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestHandlePortScanHistory(t *testing.T) {
+	appState := state.NewAppState(config.DefaultConfig(), "test")
+	appState.UpsertDevice(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	device, _ := appState.GetDevice("10.0.0.1")
+	device.RecordPortScan(time.Unix(100, 0), map[string][]int{"tcp": {22, 80}})
+	device.RecordPortScan(time.Unix(200, 0), map[string][]int{"tcp": {22, 23}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices/{ip}/portscans", func(w http.ResponseWriter, r *http.Request) {
+		handlePortScanHistory(w, r, appState)
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/devices/10.0.0.1/portscans", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		History []discovery.PortScanResult `json:"history"`
+		Changes discovery.PortChanges      `json:"changes"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.History, 2)
+	assert.Equal(t, []int{23}, body.Changes.Opened["tcp"])
+	assert.Equal(t, []int{80}, body.Changes.Closed["tcp"])
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/devices/10.0.0.2/portscans", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/devices/nope/portscans", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// fakeDaemonEngine records the calls of the daemon handlers to the engine.
+type fakeDaemonEngine struct {
+	mu        sync.Mutex
+	triggered int
+	recorded  map[string][]int
+	dropped   uint64
+	sweep     *discovery.SweepStats
+}
+
+func (e *fakeDaemonEngine) TriggerScan() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.triggered++
+	return true
+}
+
+func (e *fakeDaemonEngine) RecordPortScan(d *discovery.Device, ports map[string][]int) *discovery.PortChanges {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.recorded = ports
+	return d.RecordPortScan(time.Now(), ports)
+}
+
+func (e *fakeDaemonEngine) DroppedEvents() uint64 {
+	return e.dropped
+}
+
+func (e *fakeDaemonEngine) SweepStats() (discovery.SweepStats, bool) {
+	if e.sweep == nil {
+		return discovery.SweepStats{}, false
+	}
+	return *e.sweep, true
+}
+
+// fakePortScanner reports the ports in open as open on every host.
+type fakePortScanner struct {
+	open []int
+}
+
+func (s *fakePortScanner) ScanHosts(ctx context.Context, hosts []string, ports []int, _ time.Duration, callback func(ip string, port int)) error {
+	for _, host := range hosts {
+		for _, p := range ports {
+			if slices.Contains(s.open, p) {
+				callback(host, p)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func newTestDaemon(t *testing.T) *daemon {
+	t.Helper()
+	store, err := aliases.Load(filepath.Join(t.TempDir(), aliases.FileName))
+	require.NoError(t, err)
+	knownDevices, err := known.Load(filepath.Join(t.TempDir(), known.FileName))
+	require.NoError(t, err)
+	cfg := config.DefaultConfig()
+	manager := jobs.NewManager(2)
+	t.Cleanup(manager.Close)
+	return &daemon{
+		cfg:         cfg,
+		logger:      slog.New(slog.DiscardHandler),
+		state:       state.NewAppState(cfg, "test"),
+		broker:      events.NewBroker(),
+		tracker:     tracker.New(time.Hour),
+		aliases:     store,
+		known:       knownDevices,
+		engine:      &fakeDaemonEngine{},
+		portScanner: &fakePortScanner{open: []int{22, 443}},
+		jobs:        manager,
+		metrics:     newDaemonMetrics(),
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	d := newTestDaemon(t)
+	broker := d.broker
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events?types=device_offline&types=scan_completed")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return broker.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+	broker.Publish(events.Message{Type: events.TypeScanStarted})
+	broker.Publish(events.Message{Type: events.TypeScanCompleted, Stats: &discovery.ScanStats{Count: 1}})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "retry:") {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: scan_completed", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "data: "))
+
+	var msg events.Message
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &msg))
+	assert.Equal(t, events.TypeScanCompleted, msg.Type)
+	assert.Equal(t, 1, msg.Stats.Count)
+}
+
+func TestHandleEvents_InvalidType(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleEvents(rec, httptest.NewRequest(http.MethodGet, "/events?types=bogus", nil), events.NewBroker())
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSubscribeSink_ResubscribesWhenSlow(t *testing.T) {
+	d := newTestDaemon(t)
+
+	release := make(chan struct{})
+	received := make(chan uint64, 2*sinkBufferSize)
+	cancel := d.subscribeSink(nil, func(msg events.Message) {
+		<-release
+		received <- msg.ID
+	}, "test")
+
+	// more device_offline events than the buffer holds, while the sink is busy
+	for range sinkBufferSize + 2 {
+		d.broker.Publish(events.Message{Type: events.TypeDeviceOffline})
+	}
+	close(release)
+	require.Eventually(t, func() bool { return d.broker.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	d.broker.Publish(events.Message{Type: events.TypeDeviceOffline})
+	last := uint64(sinkBufferSize + 3)
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case id := <-received:
+				if id == last {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second, 10*time.Millisecond, "expected the sink to receive events after falling behind")
+
+	cancel()
+	require.Eventually(t, func() bool { return d.broker.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestForwardEngineEvents(t *testing.T) {
+	d := newTestDaemon(t)
+	sub := d.broker.Subscribe(16)
+
+	engineEvents := make(chan discovery.Event, 8)
+	engineEvents <- discovery.NewScanStartedEvent()
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	engineEvents <- discovery.NewScanCompletedEvent(&discovery.ScanStats{Count: 1})
+	engineEvents <- discovery.NewEngineStoppedEvent()
+	close(engineEvents)
+
+	d.forwardEngineEvents(engineEvents)
+
+	_, ok := d.state.GetDevice("10.0.0.1")
+	assert.True(t, ok)
+
+	sub.Cancel()
+	var types []events.Type
+	for msg := range sub.C {
+		types = append(types, msg.Type)
+	}
+	assert.Equal(t, []events.Type{events.TypeScanStarted, events.TypeDeviceDiscovered, events.TypeScanCompleted}, types)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/webhooks"
+)
+
+// startWebhooks subscribes the configured webhooks to the events of the daemon, it does
+// nothing when no webhooks are configured. See stopWebhooks.
+func (d *daemon) startWebhooks(cfgs []config.WebhookConfig) error {
+	notifier, err := newWebhookNotifier(cfgs)
+	if err != nil {
+		return err
+	}
+	d.setWebhooks(notifier)
+	if notifier != nil {
+		d.logger.Log(context.Background(), slog.LevelInfo, "sending webhooks", "count", len(cfgs))
+	}
+	return nil
+}
+
+// newWebhookNotifier returns the notifier of the configured webhooks, nil when none are configured.
+func newWebhookNotifier(cfgs []config.WebhookConfig) (*webhooks.Notifier, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	return webhooks.New(cfgs)
+}
+
+// setWebhooks subscribes notifier (nil for none) to the events of the daemon, replacing the
+// current webhooks, which send their queued events in the background.
+func (d *daemon) setWebhooks(notifier *webhooks.Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.webhooks != nil {
+		prev := d.webhooks
+		d.cancelWebhooks()
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), daemonShutdownTimeout)
+			defer cancel()
+			prev.Shutdown(ctx)
+		}()
+	}
+
+	d.webhooks, d.cancelWebhooks = notifier, nil
+	if notifier != nil {
+		types := append(notifier.Types(), events.TypeScanCompleted)
+		d.cancelWebhooks = d.subscribeSink(types, d.webhookSink(notifier), "webhook notifier")
+	}
+}
+
+// stopWebhooks stops the webhook deliveries after sending the queued events, or when ctx is done.
+func (d *daemon) stopWebhooks(ctx context.Context) {
+	d.mu.Lock()
+	notifier, cancel := d.webhooks, d.cancelWebhooks
+	d.webhooks, d.cancelWebhooks = nil, nil
+	d.mu.Unlock()
+
+	if notifier != nil {
+		cancel()
+		notifier.Shutdown(ctx)
+	}
+}
+
+// webhookSink returns the function passing the events of the daemon to notifier.
+// device_discovered is only sent for devices that were not known before, which are
+// remembered across restarts. When no devices are known yet, e.g. on the first start, the
+// devices of the first scan are not new to the network and are recorded without sending them.
+func (d *daemon) webhookSink(notifier *webhooks.Notifier) func(events.Message) {
+	sendScanCompleted := slices.Contains(notifier.Types(), events.TypeScanCompleted)
+	initialScan := d.known.Len() == 0
+	return func(msg events.Message) {
+		switch {
+		case msg.Type == events.TypeScanCompleted:
+			initialScan = false
+			if err := d.known.Save(); err != nil {
+				d.logger.Log(context.Background(), slog.LevelWarn, "failed to save the known devices", "error", err)
+			}
+			if !sendScanCompleted {
+				return
+			}
+		case msg.Type == events.TypeDeviceDiscovered && msg.Device != nil:
+			if !d.known.Add(msg.Device.IdentityKey()) || initialScan {
+				return
+			}
+		}
+		notifier.Notify(msg)
+	}
+}
+
+// currentWebhooks returns the webhook notifier, nil when no webhooks are configured.
+func (d *daemon) currentWebhooks() *webhooks.Notifier {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.webhooks
+}
+
+// handleWebhookDeliveries returns the most recent webhook deliveries, newest first.
+func (d *daemon) handleWebhookDeliveries(w http.ResponseWriter, _ *http.Request) {
+	notifier := d.currentWebhooks()
+	if notifier == nil {
+		writeJSON(w, http.StatusOK, []webhooks.Delivery{})
+		return
+	}
+	writeJSON(w, http.StatusOK, notifier.Deliveries())
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/webhooks"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_Webhooks(t *testing.T) {
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	d := newTestDaemon(t)
+	d.auth = daemonAuth{token: "read-secret", adminToken: "admin-secret"}
+	require.NoError(t, d.startWebhooks([]config.WebhookConfig{{
+		URL:      srv.URL,
+		Template: `{"text": "New device {{.Device.IP}}"}`,
+		Timeout:  time.Second,
+	}}))
+	defer d.stopWebhooks(context.Background())
+
+	engineEvents := make(chan discovery.Event, 8)
+	engineEvents <- discovery.NewScanStartedEvent()
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	engineEvents <- discovery.NewScanCompletedEvent(&discovery.ScanStats{Count: 1})
+	engineEvents <- discovery.NewScanStartedEvent()
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.2")))
+	close(engineEvents)
+	d.forwardEngineEvents(engineEvents)
+
+	select {
+	case body := <-bodies:
+		assert.JSONEq(t, `{"text": "New device 10.0.0.2"}`, body, "devices of the first scan are not sent")
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	require.Eventually(t, func() bool { return len(d.currentWebhooks().Deliveries()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, bodies, "an already known device must not be sent again")
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer read-secret")
+	assert.Equal(t, http.StatusForbidden, serveTestDaemon(d, req).Code)
+
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := serveTestDaemon(d, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var deliveries []webhooks.Delivery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].OK())
+	assert.Equal(t, "device_discovered", string(deliveries[0].Event))
+}
+
+func TestDaemon_WebhooksAfterRestart(t *testing.T) {
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	// 10.0.0.1 was seen before the restart
+	d := newTestDaemon(t)
+	d.known.Add(discovery.NewDevice(net.ParseIP("10.0.0.1")).IdentityKey())
+	require.NoError(t, d.startWebhooks([]config.WebhookConfig{{
+		URL:      srv.URL,
+		Template: `{"text": "New device {{.Device.IP}}"}`,
+		Timeout:  time.Second,
+	}}))
+	defer d.stopWebhooks(context.Background())
+
+	engineEvents := make(chan discovery.Event, 8)
+	engineEvents <- discovery.NewScanStartedEvent()
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1")))
+	engineEvents <- discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.2")))
+	engineEvents <- discovery.NewScanCompletedEvent(&discovery.ScanStats{Count: 2})
+	close(engineEvents)
+	d.forwardEngineEvents(engineEvents)
+
+	select {
+	case body := <-bodies:
+		assert.JSONEq(t, `{"text": "New device 10.0.0.2"}`, body, "a device joining during the first scan is sent")
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	require.Eventually(t, func() bool { return len(d.currentWebhooks().Deliveries()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, bodies, "a device known before the restart must not be sent")
+	assert.Equal(t, 2, d.known.Len())
+}
+
+func TestDaemon_WebhooksReload(t *testing.T) {
+	d := newTestDaemon(t)
+	defer d.stopWebhooks(context.Background())
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Webhooks = []config.WebhookConfig{{URL: "http://localhost"}}
+	require.NoError(t, d.reload(cfg, d.portScanner))
+	notifier := d.currentWebhooks()
+	require.NotNil(t, notifier, "webhooks are started on reload")
+	assert.Equal(t, 1, d.broker.Subscribers())
+
+	invalid := config.DefaultConfig()
+	invalid.Daemon.Webhooks = []config.WebhookConfig{{URL: "http://localhost", Events: []string{"bogus"}}}
+	require.Error(t, d.reload(invalid, d.portScanner))
+	assert.Same(t, notifier, d.currentWebhooks(), "a failed reload keeps the current webhooks")
+	assert.Same(t, cfg, d.config())
+
+	require.NoError(t, d.reload(config.DefaultConfig(), d.portScanner))
+	assert.Nil(t, d.currentWebhooks(), "webhooks removed from the config are stopped")
+	assert.Equal(t, 0, d.broker.Subscribers())
+}
+
+func TestDaemon_WebhooksInvalid(t *testing.T) {
+	d := newTestDaemon(t)
+	err := d.startWebhooks([]config.WebhookConfig{{URL: "http://localhost", Events: []string{"bogus"}}})
+	assert.Error(t, err)
+
+	rec := serveTestDaemon(d, httptest.NewRequest(http.MethodGet, "/webhooks/deliveries", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
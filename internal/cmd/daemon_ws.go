@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/jobs"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocket message types, see the "Daemon mode HTTP API" section of the README for the protocol.
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsDevices     = "devices"
+	wsScan        = "scan"
+	wsPortScan    = "portscan"
+	wsSetAlias    = "set_alias"
+
+	wsEvent  = "event"
+	wsResult = "result"
+	wsError  = "error"
+)
+
+// wsRequest is a message sent by a WebSocket client. ID is optional and echoed in the reply.
+type wsRequest struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Events and Filter select the events of a subscribe request, both are optional.
+	Events []string `json:"events,omitempty"`
+	Filter string   `json:"filter,omitempty"`
+	// IP is the device of portscan and set_alias requests.
+	IP string `json:"ip,omitempty"`
+	// Ports is the port spec of a portscan request, e.g. "22,80,8000-8100".
+	Ports string `json:"ports,omitempty"`
+	// Alias is the new name of a set_alias request, empty to remove the alias.
+	Alias string `json:"alias,omitempty"`
+}
+
+// wsResponse is a message sent to a WebSocket client: an event of its subscription, or the
+// result of (or error for) one of its requests.
+type wsResponse struct {
+	ID    string          `json:"id,omitempty"`
+	Type  string          `json:"type"`
+	Event *events.Message `json:"event,omitempty"`
+	Data  any             `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// wsClient is a single WebSocket connection.
+type wsClient struct {
+	d    *daemon
+	conn *websocket.Conn
+	ctx  context.Context
+	// scope is granted by the token of the upgrade request
+	scope scope
+
+	writeMu sync.Mutex
+
+	mu  sync.Mutex
+	sub *events.Subscription
+}
+
+// handleWebSocket serves the /ws endpoint. Non-browser clients do not need to send an Origin
+// header, browsers always send one and are only accepted from the daemon's own origin, so
+// other web pages cannot use the connection.
+func (d *daemon) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handshake: checkWebSocketOrigin, Handler: func(conn *websocket.Conn) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		c := &wsClient{d: d, conn: conn, ctx: ctx, scope: d.currentAuth().scope(r)}
+		defer c.unsubscribe()
+		c.serve()
+	}}.ServeHTTP(w, r)
+}
+
+func checkWebSocketOrigin(_ *websocket.Config, r *http.Request) error {
+	if !sameOrigin(r) {
+		return fmt.Errorf("cross-origin WebSocket from %s rejected", r.Header.Get("Origin"))
+	}
+	return nil
+}
+
+// serve reads requests until the connection is closed.
+func (c *wsClient) serve() {
+	for {
+		var req wsRequest
+		if err := websocket.JSON.Receive(c.conn, &req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.send(wsResponse{Type: wsError, Error: "invalid message: " + err.Error()})
+				continue
+			}
+			return
+		}
+		c.handle(req)
+	}
+}
+
+func (c *wsClient) handle(req wsRequest) {
+	c.d.logger.Log(c.ctx, slog.LevelDebug, "received websocket request", "type", req.Type, "id", req.ID)
+
+	switch req.Type {
+	case wsScan, wsPortScan, wsSetAlias:
+		if c.scope < scopeAdmin {
+			c.replyError(req, fmt.Errorf("%s requires the admin token", req.Type))
+			return
+		}
+	}
+
+	switch req.Type {
+	case wsSubscribe:
+		types, err := events.ParseTypes(strings.Join(req.Events, ","))
+		if err != nil {
+			c.replyError(req, err)
+			return
+		}
+		filter, err := query.ParseFilter(req.Filter)
+		if err != nil {
+			c.replyError(req, err)
+			return
+		}
+		c.subscribe(types, filter)
+		c.reply(req, nil)
+	case wsUnsubscribe:
+		c.unsubscribe()
+		c.reply(req, nil)
+	case wsDevices:
+		c.reply(req, c.d.state.DevicesSnapshot())
+	case wsScan:
+		if !c.d.engine.TriggerScan() {
+			c.replyError(req, errors.New("engine is not running"))
+			return
+		}
+		c.reply(req, nil)
+	case wsPortScan:
+		if net.ParseIP(req.IP) == nil {
+			c.replyError(req, fmt.Errorf("invalid IP address %q", req.IP))
+			return
+		}
+		if _, ok := c.d.state.GetDevice(req.IP); !ok {
+			c.replyError(req, fmt.Errorf("device %s not found", req.IP))
+			return
+		}
+		var ports []int
+		if req.Ports != "" {
+			var err error
+			if ports, err = discovery.ParsePortSpec(req.Ports); err != nil {
+				c.replyError(req, err)
+				return
+			}
+		}
+		// port scans take a while, the result is sent when done and other requests are served meanwhile
+		job := c.d.submitPortScan(req.IP, ports)
+		go func() {
+			job, err := c.d.jobs.Wait(c.ctx, job.ID)
+			switch {
+			case err != nil || !job.Done():
+				return
+			case job.Status == jobs.StatusFailed:
+				c.replyError(req, errors.New(job.Error))
+			default:
+				c.reply(req, job.Result)
+			}
+		}()
+	case wsSetAlias:
+		if net.ParseIP(req.IP) == nil {
+			c.replyError(req, fmt.Errorf("invalid IP address %q", req.IP))
+			return
+		}
+		device, err := c.d.setAlias(req.IP, req.Alias)
+		if err != nil {
+			c.replyError(req, err)
+			return
+		}
+		c.reply(req, device)
+	default:
+		c.replyError(req, fmt.Errorf("unknown message type %q", req.Type))
+	}
+}
+
+// subscribe replaces the subscription of the client.
+func (c *wsClient) subscribe(types []events.Type, filter *query.Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sub != nil {
+		c.sub.Cancel()
+	}
+	sub := c.d.broker.Subscribe(events.DefaultBufferSize, types...)
+	c.sub = sub
+
+	go func() {
+		for msg := range sub.C {
+			if msg.Device != nil && !filter.Match(msg.Device) {
+				continue
+			}
+			c.send(wsResponse{Type: wsEvent, Event: &msg})
+		}
+		if sub.Slow() {
+			c.send(wsResponse{Type: wsError, Error: "client too slow, closing connection"})
+			_ = c.conn.Close()
+		}
+	}()
+}
+
+func (c *wsClient) unsubscribe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sub != nil {
+		c.sub.Cancel()
+		c.sub = nil
+	}
+}
+
+func (c *wsClient) reply(req wsRequest, data any) {
+	c.send(wsResponse{ID: req.ID, Type: wsResult, Data: data})
+}
+
+func (c *wsClient) replyError(req wsRequest, err error) {
+	c.send(wsResponse{ID: req.ID, Type: wsError, Error: err.Error()})
+}
+
+func (c *wsClient) send(resp wsResponse) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := websocket.JSON.Send(c.conn, resp); err != nil {
+		c.d.logger.Log(c.ctx, slog.LevelDebug, "failed to send websocket message", "error", err)
+	}
+}
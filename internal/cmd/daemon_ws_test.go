@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func dialTestWebSocket(t *testing.T, d *daemon) *websocket.Conn {
+	t.Helper()
+	mux := http.NewServeMux()
+	d.registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "", srv.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+// wsTestResponse mirrors wsResponse with the data kept raw.
+type wsTestResponse struct {
+	ID    string          `json:"id"`
+	Type  string          `json:"type"`
+	Event *events.Message `json:"event"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+func wsRoundTrip(t *testing.T, conn *websocket.Conn, req wsRequest) wsTestResponse {
+	t.Helper()
+	require.NoError(t, websocket.JSON.Send(conn, req))
+	var resp wsTestResponse
+	require.NoError(t, websocket.JSON.Receive(conn, &resp))
+	return resp
+}
+
+func TestWebSocket_SubscribeWithFilter(t *testing.T) {
+	d := newTestDaemon(t)
+	conn := dialTestWebSocket(t, d)
+
+	resp := wsRoundTrip(t, conn, wsRequest{ID: "1", Type: "subscribe", Events: []string{"device_discovered"}, Filter: "ip=10.0.0.2"})
+	require.Equal(t, wsTestResponse{ID: "1", Type: "result"}, resp)
+
+	d.broker.Publish(events.Message{Type: events.TypeScanStarted})
+	d.broker.Publish(events.Message{Type: events.TypeDeviceDiscovered, Device: discovery.NewDevice(net.ParseIP("10.0.0.1"))})
+	d.broker.Publish(events.Message{Type: events.TypeDeviceDiscovered, Device: discovery.NewDevice(net.ParseIP("10.0.0.2"))})
+
+	var event wsTestResponse
+	require.NoError(t, websocket.JSON.Receive(conn, &event))
+	assert.Equal(t, "event", event.Type)
+	require.NotNil(t, event.Event)
+	assert.Equal(t, events.TypeDeviceDiscovered, event.Event.Type)
+	assert.Equal(t, "10.0.0.2", event.Event.Device.IP().String())
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "2", Type: "subscribe", Events: []string{"bogus"}})
+	assert.Equal(t, "error", resp.Type)
+	assert.Equal(t, "2", resp.ID)
+	assert.Contains(t, resp.Error, "invalid event type")
+}
+
+func TestWebSocket_Commands(t *testing.T) {
+	d := newTestDaemon(t)
+	device := discovery.NewDevice(net.ParseIP("10.0.0.1"))
+	device.SetMAC("00:1b:63:00:00:01")
+	device.SetDisplayName("synology")
+	d.state.UpsertDevice(device)
+	d.tracker.Observe(device, time.Now())
+	conn := dialTestWebSocket(t, d)
+
+	resp := wsRoundTrip(t, conn, wsRequest{ID: "scan", Type: "scan"})
+	assert.Equal(t, wsTestResponse{ID: "scan", Type: "result"}, resp)
+	assert.Equal(t, 1, d.engine.(*fakeDaemonEngine).triggered)
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "ps", Type: "portscan", IP: "10.0.0.1", Ports: "22,80"})
+	require.Equal(t, "result", resp.Type, resp.Error)
+	assert.JSONEq(t, `{"ip":"10.0.0.1","tcp":[22]}`, string(resp.Data))
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "alias", Type: "set_alias", IP: "10.0.0.1", Alias: "nas"})
+	require.Equal(t, "result", resp.Type, resp.Error)
+	var renamed discovery.Device
+	require.NoError(t, json.Unmarshal(resp.Data, &renamed))
+	assert.Equal(t, "nas", renamed.DisplayName())
+	stored, _ := d.state.GetDevice("10.0.0.1")
+	assert.Equal(t, "nas", stored.DisplayName())
+	assert.Equal(t, map[string]string{"00:1b:63:00:00:01": "nas"}, d.aliases.All())
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "devices", Type: "devices"})
+	require.Equal(t, "result", resp.Type)
+	var devices []*discovery.Device
+	require.NoError(t, json.Unmarshal(resp.Data, &devices))
+	require.Len(t, devices, 1)
+
+	for _, req := range []wsRequest{
+		{ID: "e1", Type: "portscan", IP: "10.0.0.9"},
+		{ID: "e2", Type: "portscan", IP: "nope"},
+		{ID: "e3", Type: "set_alias", IP: "10.0.0.9", Alias: "x"},
+		{ID: "e4", Type: "reboot"},
+	} {
+		resp := wsRoundTrip(t, conn, req)
+		assert.Equal(t, "error", resp.Type, "request %s", req.ID)
+		assert.Equal(t, req.ID, resp.ID)
+		assert.NotEmpty(t, resp.Error)
+	}
+}
+
+func TestWebSocket_InvalidMessage(t *testing.T) {
+	conn := dialTestWebSocket(t, newTestDaemon(t))
+
+	_, err := conn.Write([]byte("{not json"))
+	require.NoError(t, err)
+	var resp wsTestResponse
+	require.NoError(t, websocket.JSON.Receive(conn, &resp))
+	assert.Equal(t, "error", resp.Type)
+
+	resp = wsRoundTrip(t, conn, wsRequest{ID: "1", Type: "unsubscribe"})
+	assert.Equal(t, "result", resp.Type)
+}
+
+func TestWebSocket_RejectsForeignOrigin(t *testing.T) {
+	mux := http.NewServeMux()
+	newTestDaemon(t).registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	for _, origin := range []string{"https://evil.example.com", "http://localhost:1"} {
+		_, err := websocket.Dial(wsURL, "", origin)
+		assert.ErrorContains(t, err, "bad status", "origin %s", origin)
+	}
+	conn, err := websocket.Dial(wsURL, "", srv.URL)
+	require.NoError(t, err)
+	_ = conn.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Host = "127.0.0.1:8080"
+	assert.True(t, sameOrigin(req), "no Origin header")
+	req.Header.Set("Origin", "http://127.0.0.1:8080")
+	assert.True(t, sameOrigin(req))
+	req.Header.Set("Origin", "http://attacker.example:8080")
+	assert.False(t, sameOrigin(req))
+	req.Header.Set("Origin", "null")
+	assert.False(t, sameOrigin(req))
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/diff"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Exit codes of the diff command, 0 means no differences.
+const (
+	diffExitDifferences = 1
+	diffExitError       = 2
+)
+
+func NewDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> [new.json]",
+		Short: "Compare two saved scans, or a saved scan with a live scan",
+		Long: `Compare two scans saved with 'whosthere scan --json' (or --format=ndjson) and report
+the devices that were added, removed or changed (name, IP, MAC, vendor and open ports).
+When only one file is given, it is compared with a live scan.
+
+Devices are matched by MAC address, falling back to the IP address for devices without a
+MAC, with a randomized MAC or whose MAC is not in the other scan. The exit code is 0 when
+the scans are the same, 1 when there are differences and 2 on errors.` + magenta + `
+
+Examples:` + reset + `
+  whosthere scan --json > baseline.json
+  whosthere diff baseline.json
+  whosthere diff monday.json tuesday.json
+  whosthere diff baseline.json --json --pretty
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.RangeArgs(1, 2)(cmd, args); err != nil {
+				return &ExitError{Code: diffExitError, Err: err}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runDiff(cmd, args)
+			var exitErr *ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return &ExitError{Code: diffExitError, Err: err}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Output differences in JSON format")
+	cmd.Flags().Bool("pretty", false, "Pretty print output")
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &ExitError{Code: diffExitError, Err: err}
+	})
+
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	oldDevices, err := diff.LoadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var newDevices []*discovery.Device
+	if len(args) == 2 {
+		if newDevices, err = diff.LoadFile(args[1]); err != nil {
+			return err
+		}
+	} else if newDevices, err = liveScan(); err != nil {
+		return err
+	}
+
+	res := diff.Compare(oldDevices, newDevices)
+
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	prettyFlag, _ := cmd.Flags().GetBool("pretty")
+	if jsonFlag {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		if prettyFlag {
+			encoder.SetIndent("", "  ")
+		}
+		err = encoder.Encode(res)
+	} else {
+		err = printDiffTable(cmd.OutOrStdout(), res)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !res.Empty() {
+		return &ExitError{Code: diffExitDifferences}
+	}
+	return nil
+}
+
+// liveScan runs a single discovery scan to compare a saved scan with.
+func liveScan() ([]*discovery.Device, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The spinner goes to stderr, stdout is reserved for the differences.
+	var spinner *output.Spinner
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		spinner = output.NewSpinner(os.Stderr, "Scanning network...", cfg.ScanTimeout)
+		spinner.Start()
+	}
+
+	results, err := eng.Scan(ctx)
+
+	if spinner != nil {
+		spinner.Stop()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return results.Devices, nil
+}
+
+func printDiffTable(w io.Writer, res *diff.Result) error {
+	if res.Empty() {
+		_, err := fmt.Fprintln(w, "No differences")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "CHANGE\tIP\tNAME\tMAC\tVENDOR\tDETAILS")
+	_, _ = fmt.Fprintln(tw, "──────\t──\t────\t───\t──────\t───────")
+
+	for _, d := range res.Added {
+		_, _ = fmt.Fprintf(tw, "added\t%s\t%s\t%s\t%s\t%s\n", ipOrDash(d), orDash(d.DisplayName()), orDash(d.MAC()), orDash(d.Manufacturer()), "-")
+	}
+	for _, d := range res.Removed {
+		_, _ = fmt.Fprintf(tw, "removed\t%s\t%s\t%s\t%s\t%s\n", ipOrDash(d), orDash(d.DisplayName()), orDash(d.MAC()), orDash(d.Manufacturer()), "-")
+	}
+	for _, c := range res.Changed {
+		d := c.New
+		_, _ = fmt.Fprintf(tw, "changed\t%s\t%s\t%s\t%s\t%s\n", ipOrDash(d), orDash(d.DisplayName()), orDash(d.MAC()), orDash(d.Manufacturer()), describeDeviceChange(c))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d added, %d removed, %d changed\n", len(res.Added), len(res.Removed), len(res.Changed))
+	return err
+}
+
+// describeDeviceChange formats the changed fields and ports of a device, e.g.
+// `name: "nas" -> "nas.lan", opened tcp/443, closed tcp/80`.
+func describeDeviceChange(c diff.DeviceChange) string {
+	parts := make([]string, 0, len(c.Fields)+2)
+	for _, f := range c.Fields {
+		parts = append(parts, fmt.Sprintf("%s: %q -> %q", f.Field, f.Old, f.New))
+	}
+	if c.Ports != nil {
+		if opened := formatPortList(c.Ports.Opened); opened != "" {
+			parts = append(parts, "opened "+opened)
+		}
+		if closed := formatPortList(c.Ports.Closed); closed != "" {
+			parts = append(parts, "closed "+closed)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPortList formats ports per protocol as "tcp/22 tcp/80".
+func formatPortList(ports map[string][]int) string {
+	protocols := make([]string, 0, len(ports))
+	for protocol := range ports {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	var out []string
+	for _, protocol := range protocols {
+		for _, p := range ports[protocol] {
+			out = append(out, protocol+"/"+strconv.Itoa(p))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func ipOrDash(d *discovery.Device) string {
+	if d.IP() == nil {
+		return "-"
+	}
+	return d.IP().String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDiffCommand(t *testing.T) {
+	cmd := NewDiffCommand()
+
+	assert.Equal(t, "diff", cmd.Name())
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+
+	for _, name := range []string{"json", "pretty"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should exist", name)
+	}
+}
+
+func writeScan(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scan.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func runDiffCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewDiffCommand()
+	cmd.SilenceUsage = true
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestDiffCommand_Table(t *testing.T) {
+	oldScan := writeScan(t, `{"devices":[
+		{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01","displayName":"nas","openPorts":{"tcp":[22,80]},"lastPortScan":"2026-01-01T00:00:00Z"},
+		{"ip":"10.0.0.2","mac":"00:1a:2b:3c:4d:02","displayName":"tv"}
+	],"stats":{"count":2,"duration":"1s"}}`)
+	newScan := writeScan(t, `{"devices":[
+		{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01","displayName":"nas.lan","openPorts":{"tcp":[22,443]},"lastPortScan":"2026-01-02T00:00:00Z"},
+		{"ip":"10.0.0.3","mac":"00:1a:2b:3c:4d:03","displayName":"phone","manufacturer":"Apple, Inc."}
+	],"stats":{"count":2,"duration":"1s"}}`)
+
+	out, err := runDiffCommand(t, oldScan, newScan)
+
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr), "expected an exit error, got %v", err)
+	assert.Equal(t, 1, exitErr.Code)
+	assert.Contains(t, out, "added")
+	assert.Contains(t, out, "phone")
+	assert.Contains(t, out, "Apple, Inc.")
+	assert.Contains(t, out, "removed")
+	assert.Contains(t, out, `name: "nas" -> "nas.lan", opened tcp/443, closed tcp/80`)
+	assert.Contains(t, out, "1 added, 1 removed, 1 changed")
+}
+
+func TestDiffCommand_JSON(t *testing.T) {
+	oldScan := writeScan(t, `[{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01"}]`)
+	newScan := writeScan(t, `[{"ip":"10.0.0.9","mac":"00:1a:2b:3c:4d:01"}]`)
+
+	out, err := runDiffCommand(t, oldScan, newScan, "--json")
+	require.Error(t, err)
+
+	var res struct {
+		Added   []json.RawMessage `json:"added"`
+		Removed []json.RawMessage `json:"removed"`
+		Changed []struct {
+			Changes []map[string]string `json:"changes"`
+		} `json:"changed"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	assert.Empty(t, res.Added)
+	assert.Empty(t, res.Removed)
+	require.Len(t, res.Changed, 1)
+	assert.Equal(t, []map[string]string{{"field": "ip", "old": "10.0.0.1", "new": "10.0.0.9"}}, res.Changed[0].Changes)
+}
+
+func TestDiffCommand_NoDifferences(t *testing.T) {
+	scan := writeScan(t, `{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01"}`+"\n")
+
+	out, err := runDiffCommand(t, scan, scan)
+	require.NoError(t, err)
+	assert.Equal(t, "No differences\n", out)
+}
+
+func TestDiffCommand_InvalidFile(t *testing.T) {
+	_, err := runDiffCommand(t, filepath.Join(t.TempDir(), "missing.json"), filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 2, exitErr.Code)
+	assert.Contains(t, err.Error(), "missing.json")
+
+	for _, args := range [][]string{{}, {"a.json", "--bogus"}} {
+		_, err = runDiffCommand(t, args...)
+		require.True(t, errors.As(err, &exitErr), "args %v", args)
+		assert.Equal(t, 2, exitErr.Code, "args %v", args)
+	}
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
+	"github.com/spf13/cobra"
+)
+
+func NewOUICommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "oui",
+		Short: "Manage the OUI database used to resolve manufacturers",
+		Long: `Manage the IEEE OUI database used to resolve manufacturers from MAC addresses.
+
+The database is embedded in the binary and refreshed in the background according to
+the oui.auto_refresh and oui.refresh_interval settings. On offline networks, download
+the registry CSV files elsewhere and import them with --from-file.` + magenta + `
+
+Examples:` + reset + `
+  whosthere oui lookup 28:6f:b9:12:34:56
+  whosthere oui status
+  whosthere oui update
+  whosthere oui update --from-file=oui.csv
+`,
+	}
+
+	cmd.AddCommand(newOUILookupCommand(), newOUIUpdateCommand(), newOUIStatusCommand())
+	return cmd
+}
+
+func newOUILookupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lookup [mac...]",
+		Short: "Resolve the vendor of MAC addresses or prefixes",
+		Long: `Resolve the vendor of one or more MAC addresses or prefixes (at least 3 octets),
+using the same OUI database and overrides as scanning. When no arguments are given,
+whitespace separated queries are read from stdin.` + magenta + `
+
+Examples:` + reset + `
+  whosthere oui lookup 28:6f:b9:12:34:56 70-B3-D5-F2-A0-00
+  whosthere oui lookup 286FB9 --json
+  arp -an | awk '{print $4}' | whosthere oui lookup
+`,
+		RunE: runOUILookup,
+	}
+
+	cmd.Flags().Bool("json", false, "Output results in JSON format")
+	cmd.Flags().Bool("pretty", false, "Pretty print output")
+	return cmd
+}
+
+func newOUIUpdateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Download the latest OUI registries or import a registry CSV file",
+		Long: `Download the latest IEEE registries (from oui.mirror_url when set), or import a
+registry CSV file (oui.csv, mam.csv, oui36.csv, iab.csv or cid.csv) with --from-file.
+The result is cached and used by all other commands.`,
+		Args: cobra.NoArgs,
+		RunE: runOUIUpdate,
+	}
+
+	cmd.Flags().String("from-file", "", "Import a registry CSV file instead of downloading")
+	return cmd
+}
+
+func newOUIStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the entry count, source and age of the OUI registries",
+		Args:  cobra.NoArgs,
+		RunE:  runOUIStatus,
+	}
+
+	cmd.Flags().Bool("json", false, "Output status in JSON format")
+	return cmd
+}
+
+// ouiStatus is the JSON output of the oui status command.
+type ouiStatus struct {
+	CacheDir string           `json:"cacheDir"`
+	Files    []oui.FileStatus `json:"files"`
+}
+
+// ouiLookupResult is the vendor lookup result of a single query.
+type ouiLookupResult struct {
+	Query    string `json:"query"`
+	Found    bool   `json:"found"`
+	Prefix   string `json:"prefix,omitempty"`
+	Bits     int    `json:"bits,omitempty"`
+	Registry string `json:"registry,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+}
+
+// loadOUIRegistry builds the OUI registry without background refresh, so the commands
+// control when the registries are downloaded.
+func loadOUIRegistry() (*oui.Registry, error) {
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return nil, err
+	}
+	reg := core.BuildOUIRegistry(cfg, discovery.NoOpLogger{}, oui.WithAutoRefresh(false))
+	if reg == nil {
+		return nil, errors.New("failed to initialize the OUI database")
+	}
+	return reg, nil
+}
+
+func runOUILookup(cmd *cobra.Command, args []string) error {
+	queries := args
+	if len(queries) == 0 {
+		var err error
+		if queries, err = readOUIQueries(cmd.InOrStdin()); err != nil {
+			return err
+		}
+		if len(queries) == 0 {
+			return errors.New("no MAC addresses given, pass them as arguments or on stdin")
+		}
+	}
+
+	reg, err := loadOUIRegistry()
+	if err != nil {
+		return err
+	}
+	results := lookupOUI(reg, queries)
+
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	prettyFlag, _ := cmd.Flags().GetBool("pretty")
+	if jsonFlag {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		if prettyFlag {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(results)
+	}
+	return printOUILookupTable(cmd.OutOrStdout(), results)
+}
+
+// readOUIQueries reads whitespace separated queries from r.
+func readOUIQueries(r io.Reader) ([]string, error) {
+	var queries []string
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		queries = append(queries, scanner.Text())
+	}
+	return queries, scanner.Err()
+}
+
+func lookupOUI(reg *oui.Registry, queries []string) []ouiLookupResult {
+	results := make([]ouiLookupResult, 0, len(queries))
+	for _, q := range queries {
+		res := ouiLookupResult{Query: q}
+		if entry, ok := reg.LookupEntry(strings.TrimSpace(q)); ok {
+			res.Found = true
+			res.Prefix = entry.Prefix
+			res.Bits = entry.Bits
+			res.Registry = entry.Registry
+			res.Vendor = entry.Org
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func printOUILookupTable(w io.Writer, results []ouiLookupResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "QUERY\tPREFIX\tREGISTRY\tVENDOR")
+	_, _ = fmt.Fprintln(tw, "─────\t──────\t────────\t──────")
+
+	for _, r := range results {
+		if !r.Found {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t-\t-\n", r.Query)
+			continue
+		}
+		prefix := fmt.Sprintf("%s/%d", r.Prefix, r.Bits)
+		if r.Registry == oui.RegistryRandomized {
+			// not assigned by the IEEE, so there is no prefix
+			prefix = "randomized/locally administered"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Query, prefix, r.Registry, r.Vendor)
+	}
+
+	return tw.Flush()
+}
+
+func runOUIUpdate(cmd *cobra.Command, _ []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reg, err := loadOUIRegistry()
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if path, _ := cmd.Flags().GetString("from-file"); path != "" {
+		file, err := reg.ImportFile(path)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "Imported %s as %s\n\n", path, file)
+		return printOUIStatus(w, reg, time.Now())
+	}
+
+	refreshErr := reg.Refresh(ctx)
+	if err := printOUIStatus(w, reg, time.Now()); err != nil {
+		return err
+	}
+	return refreshErr
+}
+
+func runOUIStatus(cmd *cobra.Command, _ []string) error {
+	reg, err := loadOUIRegistry()
+	if err != nil {
+		return err
+	}
+
+	if jsonFlag, _ := cmd.Flags().GetBool("json"); jsonFlag {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ouiStatus{CacheDir: reg.CacheDir(), Files: reg.Status()})
+	}
+	return printOUIStatus(cmd.OutOrStdout(), reg, time.Now())
+}
+
+func printOUIStatus(w io.Writer, reg *oui.Registry, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "FILE\tREGISTRY\tENTRIES\tSOURCE\tAGE")
+	_, _ = fmt.Fprintln(tw, "────\t────────\t───────\t──────\t───")
+
+	for _, s := range reg.Status() {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", s.File, s.Registry, s.Entries, s.Origin, formatAge(now, s.UpdatedAt))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if dir := reg.CacheDir(); dir != "" {
+		_, _ = fmt.Fprintf(w, "\nCache directory: %s\n", dir)
+	}
+	return nil
+}
+
+// formatAge formats the time since t in the largest sensible unit, e.g. "3d" or "5h".
+func formatAge(now, t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOUICommand(t *testing.T) {
+	cmd := NewOUICommand()
+
+	assert.Equal(t, "oui", cmd.Name())
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+
+	for _, name := range []string{"lookup", "update", "status"} {
+		sub, _, err := cmd.Find([]string{name})
+		assert.NoError(t, err)
+		assert.Equal(t, name, sub.Name())
+		assert.NotNil(t, sub.RunE)
+	}
+}
+
+func TestNewOUICommand_HasFlags(t *testing.T) {
+	cmd := NewOUICommand()
+
+	lookup, _, _ := cmd.Find([]string{"lookup"})
+	assert.NotNil(t, lookup.Flags().Lookup("json"))
+	assert.NotNil(t, lookup.Flags().Lookup("pretty"))
+
+	update, _, _ := cmd.Find([]string{"update"})
+	assert.NotNil(t, update.Flags().Lookup("from-file"))
+
+	status, _, _ := cmd.Find([]string{"status"})
+	assert.NotNil(t, status.Flags().Lookup("json"))
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Time{}, "-"},
+		{now.Add(-10 * time.Second), "<1m"},
+		{now.Add(-5 * time.Minute), "5m"},
+		{now.Add(-3 * time.Hour), "3h"},
+		{now.Add(-50 * time.Hour), "2d"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatAge(now, tt.t))
+	}
+}
+
+func TestReadOUIQueries(t *testing.T) {
+	queries, err := readOUIQueries(strings.NewReader("28:6f:b9:00:11:22\n\n  52:54:00:12:34:56 286FB9\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"28:6f:b9:00:11:22", "52:54:00:12:34:56", "286FB9"}, queries)
+}
+
+func TestLookupOUI(t *testing.T) {
+	reg, err := oui.New(context.Background(), oui.WithCacheDir(t.TempDir()), oui.WithAutoRefresh(false))
+	require.NoError(t, err)
+
+	results := lookupOUI(reg, []string{"52:54:00:12:34:56", "not-a-mac", "da:a1:19:00:00:01"})
+	require.Len(t, results, 3)
+
+	assert.True(t, results[0].Found)
+	assert.Equal(t, "525400", results[0].Prefix)
+	assert.Equal(t, 24, results[0].Bits)
+	assert.Equal(t, oui.RegistryVirtual, results[0].Registry)
+	assert.NotEmpty(t, results[0].Vendor)
+
+	assert.False(t, results[1].Found)
+	assert.Equal(t, "not-a-mac", results[1].Query)
+
+	assert.True(t, results[2].Found)
+	assert.Equal(t, oui.RegistryRandomized, results[2].Registry)
+
+	var buf bytes.Buffer
+	require.NoError(t, printOUILookupTable(&buf, results))
+	assert.Contains(t, buf.String(), "525400/24")
+	assert.Contains(t, buf.String(), "not-a-mac")
+	assert.Contains(t, buf.String(), "randomized/locally administered")
+	assert.NotContains(t, buf.String(), "/0")
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewPortScanCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "portscan [ip...]",
+		Short: "Port scan one or more hosts and output the open ports to the console",
+		Long: `Port scan one or more hosts using a shared worker pool.
+
+When no IPs are given, a discovery scan runs first and all discovered devices
+(optionally narrowed down with --filter) are port scanned.
+Only scan hosts that you have permission to scan!` + magenta + `
+
+Examples:` + reset + `
+  whosthere portscan 192.168.1.10 192.168.1.20
+  whosthere portscan --filter=apple
+  whosthere portscan --filter='source=mdns ip=192.168.1.0/24'
+  whosthere portscan --ports=22,80,8000-8100 --json --pretty
+`,
+		RunE: runPortScan,
+	}
+
+	cmd.Flags().String("ports", "", "TCP ports to scan, overrides port_scanner.tcp (e.g. --ports=22,80,8000-8100)")
+	cmd.Flags().String("filter", "", "Filter expression to select discovered devices to scan, e.g. 'vendor~apple' or 'ip=192.168.1.0/24'")
+	cmd.Flags().Bool("json", false, "Output results in JSON format")
+	cmd.Flags().Bool("pretty", false, "Pretty print output")
+
+	return cmd
+}
+
+// hostPorts is the port scan result of a single host.
+type hostPorts struct {
+	IP  string `json:"ip"`
+	TCP []int  `json:"tcp"`
+}
+
+type portScanResults struct {
+	Hosts []hostPorts          `json:"hosts"`
+	Stats *discovery.ScanStats `json:"stats"`
+}
+
+func runPortScan(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return err
+	}
+
+	ports := cfg.PortScanner.TCP
+	if spec, _ := cmd.Flags().GetString("ports"); spec != "" {
+		if ports, err = discovery.ParsePortSpec(spec); err != nil {
+			return err
+		}
+	}
+
+	for _, ip := range args {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address %q", ip)
+		}
+	}
+
+	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+	if err != nil {
+		return err
+	}
+
+	interactive := term.IsTerminal(int(os.Stdout.Fd()))
+
+	// the scanner skips duplicate hosts, so do the results
+	targets := uniqueStrings(args)
+	if len(targets) == 0 {
+		pattern, _ := cmd.Flags().GetString("filter")
+		if targets, err = discoverTargets(ctx, eng, cfg, pattern, interactive); err != nil {
+			return err
+		}
+	}
+
+	var spinner *output.Spinner
+	if interactive {
+		total := cfg.PortScanner.Timeout * time.Duration(len(ports)*len(targets)/max(cfg.PortScanner.Workers, 1)+1)
+		spinner = output.NewSpinner(os.Stdout, fmt.Sprintf("Port scanning %d host(s)...", len(targets)), total)
+		spinner.Start()
+	}
+
+	start := time.Now()
+	open := make(map[string][]int, len(targets))
+	var mu sync.Mutex
+	scanErr := core.BuildPortScanner(cfg, eng.Iface).ScanHosts(ctx, targets, ports, cfg.PortScanner.Timeout, func(ip string, port int) {
+		mu.Lock()
+		defer mu.Unlock()
+		open[ip] = append(open[ip], port)
+	})
+
+	if spinner != nil {
+		spinner.Stop()
+	}
+
+	if scanErr != nil {
+		return scanErr
+	}
+
+	results := &portScanResults{
+		Hosts: make([]hostPorts, 0, len(targets)),
+		Stats: &discovery.ScanStats{Count: len(targets), Duration: time.Since(start)},
+	}
+	for _, ip := range targets {
+		tcp := open[ip]
+		sort.Ints(tcp)
+		if tcp == nil {
+			tcp = []int{}
+		}
+		results.Hosts = append(results.Hosts, hostPorts{IP: ip, TCP: tcp})
+	}
+	sort.Slice(results.Hosts, func(i, j int) bool {
+		return discovery.CompareIPs(net.ParseIP(results.Hosts[i].IP), net.ParseIP(results.Hosts[j].IP))
+	})
+
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	prettyFlag, _ := cmd.Flags().GetBool("pretty")
+	if jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		if prettyFlag {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(results)
+	}
+	return printPortScanTable(os.Stdout, results)
+}
+
+// discoverTargets runs a single discovery scan and returns the IPs of all devices matching the filter expression.
+func discoverTargets(ctx context.Context, eng *discovery.Engine, cfg *config.Config, expr string, interactive bool) ([]string, error) {
+	filter, err := query.ParseFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var spinner *output.Spinner
+	if interactive {
+		spinner = output.NewSpinner(os.Stdout, "Scanning network...", cfg.ScanTimeout)
+		spinner.Start()
+	}
+
+	results, err := eng.Scan(ctx)
+
+	if spinner != nil {
+		spinner.Stop()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	devices := filter.Apply(results.Devices)
+	targets := make([]string, 0, len(devices))
+	for _, d := range devices {
+		targets = append(targets, d.IP().String())
+	}
+	return targets, nil
+}
+
+// uniqueStrings returns in without duplicates, keeping the first occurrence.
+func uniqueStrings(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
+
+func printPortScanTable(w io.Writer, results *portScanResults) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "IP\tOPEN TCP PORTS")
+	_, _ = fmt.Fprintln(tw, "──\t──────────────")
+
+	for _, h := range results.Hosts {
+		ports := "-"
+		if len(h.TCP) > 0 {
+			parts := make([]string, len(h.TCP))
+			for i, p := range h.TCP {
+				parts[i] = fmt.Sprintf("%d", p)
+			}
+			ports = strings.Join(parts, ", ")
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", h.IP, ports)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nPort scan completed: %d host(s) scanned in %.1fs\n", results.Stats.Count, results.Stats.Duration.Seconds())
+	return err
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPortScanCommand(t *testing.T) {
+	cmd := NewPortScanCommand()
+
+	assert.Equal(t, "portscan", cmd.Name())
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+}
+
+func TestNewPortScanCommand_HasFlags(t *testing.T) {
+	cmd := NewPortScanCommand()
+
+	for _, name := range []string{"ports", "filter", "json", "pretty"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should exist", name)
+	}
+}
+
+func TestNewPortScanCommand_HasAllInheritedPersistentFlags(t *testing.T) {
+	rootCmd := NewRootCommand()
+	AddCommands(rootCmd)
+	portScanCmd, _, err := rootCmd.Find([]string{"portscan"})
+	assert.NoError(t, err)
+
+	settings := config.GlobalSettings()
+	for _, s := range settings {
+		if s.Sources[config.SourceFlag] {
+			t.Run(s.FlagName, func(t *testing.T) {
+				flag := portScanCmd.InheritedFlags().Lookup(s.FlagName)
+				assert.NotNil(t, flag, "persistent flag %s should be inherited by portscan command", s.FlagName)
+			})
+		}
+	}
+}
+
+func TestUniqueStrings(t *testing.T) {
+	got := uniqueStrings([]string{"10.0.0.2", "10.0.0.1", "10.0.0.2", "10.0.0.1"})
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.1"}, got)
+}
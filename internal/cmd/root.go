@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -61,18 +63,50 @@ func Execute() {
 	setCobraUsageTemplate()
 	AddCommands(rootCmd)
 
+	rootCmd.SilenceErrors = true
+
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) {
+			rootCmd.PrintErrln(rootCmd.ErrPrefix(), err.Error())
+			os.Exit(1)
+		}
+		if exitErr.Err != nil {
+			rootCmd.PrintErrln(rootCmd.ErrPrefix(), exitErr.Err.Error())
+		}
+		os.Exit(exitErr.Code)
 	}
 
 	os.Exit(0)
 }
 
+// ExitError makes the process exit with Code, for commands whose exit code carries a result,
+// like diff. Err is printed when set.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 func AddCommands(root *cobra.Command) {
 	root.AddCommand(
 		NewVersionCommand(),
 		NewDaemonCommand(),
 		NewScanCommand(),
+		NewPortScanCommand(),
+		NewOUICommand(),
+		NewWatchCommand(),
+		NewDiffCommand(),
 	)
 }
 
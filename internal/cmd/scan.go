@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/output"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/internal/core/version"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -26,10 +30,23 @@ Examples:` + reset + `
   whosthere scan --sweeper=false
   whosthere scan --mdns=false --ssdp=false
   whosthere scan --timeout=5s --json --pretty
+  whosthere scan --format=csv > devices.csv
+  whosthere scan --format=html > inventory.html
+  whosthere scan --format=nmap > scan.xml
+  whosthere scan --filter='vendor~apple source=mdns' --sort=name
+  whosthere scan --filter=port=22 --sort=last_seen --reverse
+  whosthere scan --columns=ip,name,vendor,sources,last_seen,ports
+  whosthere scan --template='{{.IP}}\t{{.DisplayName}}\t{{ports .}}'
 `,
 		RunE: runScan,
 	}
 
+	cmd.Flags().String("format", output.FormatTable.String(), "Output format: "+strings.Join(output.FormatNames(), ", "))
+	cmd.Flags().String("filter", "", "Only output devices matching the filter expression, e.g. 'vendor~apple source=mdns port=22'")
+	cmd.Flags().String("sort", "ip", "Sort devices by: "+strings.Join(query.SortKeys, ", "))
+	cmd.Flags().Bool("reverse", false, "Reverse the sort order")
+	cmd.Flags().String("columns", "", "Comma separated columns of the table and CSV output: "+strings.Join(output.ColumnNames(), ", "))
+	cmd.Flags().String("template", "", "Format each device with a Go template, e.g. '{{.IP}}\\t{{.DisplayName}}'")
 	cmd.Flags().Bool("json", false, "Output results in JSON format")
 	cmd.Flags().Bool("pretty", false, "Pretty print output")
 
@@ -44,6 +61,11 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	format, opts, err := parseScanSpecificFlags(cmd)
+	if err != nil {
+		return err
+	}
+
 	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
 	if err != nil {
 		return err
@@ -67,7 +89,7 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	format, opts := parseScanSpecificFlags(cmd)
+	opts = append(opts, output.WithVersion(version.Version), output.WithCommandLine(os.Args))
 	out, err := output.NewOutput(format, opts...)
 	if err != nil {
 		return err
@@ -76,12 +98,20 @@ func runScan(cmd *cobra.Command, _ []string) error {
 	return out.PrintDevices(os.Stdout, results)
 }
 
-func parseScanSpecificFlags(cmd *cobra.Command) (output.Format, []output.Option) {
+// parseScanSpecificFlags returns the output format and options. --json is an alias for --format=json.
+func parseScanSpecificFlags(cmd *cobra.Command) (output.Format, []output.Option, error) {
 	var opts []output.Option
 
-	jsonFlag, _ := cmd.Flags().GetBool("json")
-	format := output.FormatTable
-	if jsonFlag {
+	formatFlag, _ := cmd.Flags().GetString("format")
+	format, err := output.ParseFormat(formatFlag)
+	if err != nil {
+		return format, nil, err
+	}
+
+	if jsonFlag, _ := cmd.Flags().GetBool("json"); jsonFlag {
+		if cmd.Flags().Changed("format") && format != output.FormatJSON {
+			return format, nil, fmt.Errorf("--json conflicts with --format=%s", format)
+		}
 		format = output.FormatJSON
 	}
 
@@ -90,5 +120,44 @@ func parseScanSpecificFlags(cmd *cobra.Command) (output.Format, []output.Option)
 		opts = append(opts, output.WithPretty())
 	}
 
-	return format, opts
+	sortKey, _ := cmd.Flags().GetString("sort")
+	reverse, _ := cmd.Flags().GetBool("reverse")
+	less, err := query.ParseSort(sortKey, reverse)
+	if err != nil {
+		return format, nil, err
+	}
+	opts = append(opts, output.WithSort(less))
+
+	expr, _ := cmd.Flags().GetString("filter")
+	filter, err := query.ParseFilter(expr)
+	if err != nil {
+		return format, nil, err
+	}
+	if filter != nil {
+		opts = append(opts, output.WithFilter(filter.Match))
+	}
+
+	if spec, _ := cmd.Flags().GetString("columns"); spec != "" {
+		if format != output.FormatTable && format != output.FormatCSV {
+			return format, nil, fmt.Errorf("--columns is only supported for the table and csv formats")
+		}
+		columns, err := output.ParseColumns(spec)
+		if err != nil {
+			return format, nil, err
+		}
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = c.Name
+		}
+		opts = append(opts, output.WithColumns(names...))
+	}
+
+	if tmpl, _ := cmd.Flags().GetString("template"); tmpl != "" {
+		if cmd.Flags().Changed("format") || cmd.Flags().Changed("json") || cmd.Flags().Changed("columns") {
+			return format, nil, fmt.Errorf("--template cannot be combined with --format, --json or --columns")
+		}
+		opts = append(opts, output.WithTemplate(tmpl))
+	}
+
+	return format, opts, nil
 }
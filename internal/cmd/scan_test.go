@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,6 +35,73 @@ func TestNewScanCommand_HasPrettyFlag(t *testing.T) {
 	assert.Equal(t, "Pretty print output", flag.Usage)
 }
 
+func TestNewScanCommand_HasFormatFlag(t *testing.T) {
+	cmd := NewScanCommand()
+
+	flag := cmd.Flags().Lookup("format")
+	assert.NotNil(t, flag)
+	assert.Equal(t, "table", flag.DefValue)
+}
+
+func TestParseScanSpecificFlags_Format(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    output.Format
+		wantErr bool
+	}{
+		{nil, output.FormatTable, false},
+		{[]string{"--format=csv"}, output.FormatCSV, false},
+		{[]string{"--format=ndjson"}, output.FormatNDJSON, false},
+		{[]string{"--json"}, output.FormatJSON, false},
+		{[]string{"--json", "--format=json"}, output.FormatJSON, false},
+		{[]string{"--json", "--format=yaml"}, 0, true},
+		{[]string{"--format=xml"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		cmd := NewScanCommand()
+		assert.NoError(t, cmd.ParseFlags(tt.args))
+
+		format, _, err := parseScanSpecificFlags(cmd)
+		if tt.wantErr {
+			assert.Error(t, err, "args %v", tt.args)
+			continue
+		}
+		assert.NoError(t, err, "args %v", tt.args)
+		assert.Equal(t, tt.want, format, "args %v", tt.args)
+	}
+}
+
+func TestParseScanSpecificFlags_Validation(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"--columns=ip,name,ports"}, false},
+		{[]string{"--columns=ip,ports", "--format=csv"}, false},
+		{[]string{"--columns=ip", "--json"}, true},
+		{[]string{"--columns=ip,bogus"}, true},
+		{[]string{"--template={{.IP}}"}, false},
+		{[]string{"--template={{.IP}}", "--format=csv"}, true},
+		{[]string{"--template={{.IP}}", "--columns=ip"}, true},
+		{[]string{"--sort=name", "--reverse", "--filter=vendor~apple port=22"}, false},
+		{[]string{"--sort=color"}, true},
+		{[]string{"--filter=color=red"}, true},
+	}
+
+	for _, tt := range tests {
+		cmd := NewScanCommand()
+		assert.NoError(t, cmd.ParseFlags(tt.args))
+
+		_, _, err := parseScanSpecificFlags(cmd)
+		if tt.wantErr {
+			assert.Error(t, err, "args %v", tt.args)
+		} else {
+			assert.NoError(t, err, "args %v", tt.args)
+		}
+	}
+}
+
 func TestNewScanCommand_HasAllInheritedPersistentFlags(t *testing.T) {
 	rootCmd := NewRootCommand()
 	scanCmd, _, err := rootCmd.Find([]string{"scan"})
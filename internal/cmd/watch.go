@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+)
+
+func NewWatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Continuously scan and print devices as they appear, change or go offline",
+		Long: `Continuously scan the network and print a line for each new device, each change
+to a known device and each device going offline (or coming back online).
+
+Devices are considered offline when they were not seen for --offline-after,
+which defaults to three scan intervals. Stop with Ctrl+C.` + magenta + `
+
+Examples:` + reset + `
+  whosthere watch
+  whosthere watch --interval=30s --offline-after=5m
+  whosthere watch --json | jq 'select(.event == "new")'
+  whosthere watch --filter='vendor~apple'
+`,
+		RunE: runWatch,
+	}
+
+	cmd.Flags().Bool("json", false, "Output one JSON record per line (NDJSON)")
+	cmd.Flags().Duration("offline-after", 0, "Report devices as offline when not seen for this long (default 3x the scan interval)")
+	cmd.Flags().String("filter", "", "Only report devices matching the filter expression, e.g. 'vendor~apple source=mdns'")
+
+	return cmd
+}
+
+func runWatch(cmd *cobra.Command, _ []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return err
+	}
+
+	offlineAfter, _ := cmd.Flags().GetDuration("offline-after")
+	if offlineAfter <= 0 {
+		offlineAfter = 3 * cfg.ScanInterval
+	}
+
+	expr, _ := cmd.Flags().GetString("filter")
+	filter, err := query.ParseFilter(expr)
+	if err != nil {
+		return err
+	}
+
+	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+	if err != nil {
+		return err
+	}
+
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	w := &watcher{
+		tracker: tracker.New(offlineAfter),
+		filter:  filter,
+		out:     cmd.OutOrStdout(),
+		errOut:  cmd.ErrOrStderr(),
+		json:    jsonFlag,
+		now:     time.Now,
+	}
+
+	events := eng.Start(ctx)
+	defer eng.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := w.handle(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watcher turns engine events into change records for the watch command.
+type watcher struct {
+	tracker *tracker.Tracker
+	filter  *query.Filter
+	out     io.Writer
+	errOut  io.Writer
+	json    bool
+	now     func() time.Time
+}
+
+func (w *watcher) handle(event discovery.Event) error {
+	switch event.Type {
+	case discovery.EventDeviceDiscovered:
+		if event.Device == nil || !w.filter.Match(event.Device) {
+			return nil
+		}
+		if change := w.tracker.Observe(event.Device, w.now()); change != nil {
+			return w.print(*change)
+		}
+	case discovery.EventScanCompleted:
+		for _, change := range w.tracker.Sweep(w.now()) {
+			if err := w.print(change); err != nil {
+				return err
+			}
+		}
+	case discovery.EventError:
+		if event.Error != nil {
+			_, _ = fmt.Fprintf(w.errOut, "error: %v\n", event.Error)
+		}
+	}
+	return nil
+}
+
+func (w *watcher) print(change tracker.Change) error {
+	if w.json {
+		return json.NewEncoder(w.out).Encode(change)
+	}
+
+	d := change.Device
+	line := fmt.Sprintf("%s  %-7s  %-15s", change.Time.Format(time.RFC3339), strings.ToUpper(string(change.Type)), d.IP().String())
+	switch change.Type {
+	case tracker.ChangeNew:
+		line += "  " + describeDevice(d)
+	case tracker.ChangeUpdated, tracker.ChangeOnline:
+		parts := make([]string, 0, len(change.Fields))
+		for _, f := range change.Fields {
+			parts = append(parts, fmt.Sprintf("%s: %q -> %q", f.Field, f.Old, f.New))
+		}
+		if len(parts) == 0 {
+			parts = append(parts, describeDevice(d))
+		}
+		line += "  " + strings.Join(parts, ", ")
+	case tracker.ChangeOffline:
+		line += "  " + describeDevice(d)
+	}
+
+	_, err := fmt.Fprintln(w.out, strings.TrimRight(line, " "))
+	return err
+}
+
+// describeDevice returns the name, MAC and vendor of a device, skipping empty values.
+func describeDevice(d *discovery.Device) string {
+	var parts []string
+	for _, v := range []string{d.DisplayName(), d.MAC(), d.Manufacturer()} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWatchCommand(t *testing.T) {
+	cmd := NewWatchCommand()
+
+	assert.Equal(t, "watch", cmd.Name())
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+
+	for _, name := range []string{"json", "offline-after", "filter"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should exist", name)
+	}
+}
+
+func newTestWatcher(buf *bytes.Buffer, jsonOutput bool, filter *query.Filter, now *time.Time) *watcher {
+	return &watcher{
+		tracker: tracker.New(time.Minute),
+		filter:  filter,
+		out:     buf,
+		errOut:  &bytes.Buffer{},
+		json:    jsonOutput,
+		now:     func() time.Time { return *now },
+	}
+}
+
+func TestWatcher_Text(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	w := newTestWatcher(&buf, false, nil, &now)
+
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetDisplayName("nas")
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	require.NoError(t, w.handle(discovery.NewDeviceEvent(d)))
+	require.NoError(t, w.handle(discovery.NewDeviceEvent(d)))
+
+	updated := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	updated.SetManufacturer("Acme")
+	require.NoError(t, w.handle(discovery.NewDeviceEvent(updated)))
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, w.handle(discovery.NewScanCompletedEvent(&discovery.ScanStats{})))
+	require.NoError(t, w.handle(discovery.NewErrorEvent(errors.New("ignored"))))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "2025-01-01T10:00:00Z  NEW      192.168.1.10     nas  aa:bb:cc:dd:ee:ff", lines[0])
+	assert.Contains(t, lines[1], `CHANGED  192.168.1.10     vendor: "" -> "Acme"`)
+	assert.Contains(t, lines[2], "2025-01-01T10:02:00Z  OFFLINE  192.168.1.10")
+}
+
+func TestWatcher_JSONWithFilter(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	filter, err := query.ParseFilter("vendor~acme")
+	require.NoError(t, err)
+	w := newTestWatcher(&buf, true, filter, &now)
+
+	acme := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	acme.SetManufacturer("Acme")
+	require.NoError(t, w.handle(discovery.NewDeviceEvent(acme)))
+	require.NoError(t, w.handle(discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("192.168.1.11")))))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var record struct {
+		Time   time.Time `json:"time"`
+		Event  string    `json:"event"`
+		Device struct {
+			IP           string `json:"ip"`
+			Manufacturer string `json:"manufacturer"`
+		} `json:"device"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "new", record.Event)
+	assert.Equal(t, "192.168.1.10", record.Device.IP)
+	assert.True(t, record.Time.Equal(now))
+}
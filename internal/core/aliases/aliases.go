@@ -0,0 +1,168 @@
+// Package aliases stores user defined device names, keyed by MAC address or, for devices
+// without a stable MAC, by IP address.
+package aliases
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/internal/core/paths"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// FileName is the name of the aliases file in the state directory.
+const FileName = "aliases.yaml"
+
+// Store holds the aliases and persists them to a YAML file. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	aliases map[string]string
+}
+
+// DefaultPath returns the aliases file in the state directory.
+func DefaultPath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Load reads the aliases from path. A missing file results in an empty store, which creates
+// the file on the first Set. An empty path keeps the aliases in memory only.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, aliases: make(map[string]string)}
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases map[string]string
+	if err := yaml.Unmarshal(raw, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases %s: %w", path, err)
+	}
+	for key, name := range aliases {
+		if key = normalizeKey(key); key != "" && name != "" {
+			s.aliases[key] = name
+		}
+	}
+	return s, nil
+}
+
+// Lookup returns the alias of d, looking it up by its identity key (see
+// discovery.Device.IdentityKey) first and IP address second.
+func (s *Store) Lookup(d *discovery.Device) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if key := d.IdentityKey(); key != "" {
+		if name, ok := s.aliases[key]; ok {
+			return name, true
+		}
+	}
+	if d.IP() != nil {
+		if name, ok := s.aliases[d.IP().String()]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Apply returns a copy of d with its display name replaced by the alias, or d itself
+// when it has no alias.
+func (s *Store) Apply(d *discovery.Device) *discovery.Device {
+	name, ok := s.Lookup(d)
+	if !ok || name == d.DisplayName() {
+		return d
+	}
+	d = d.Copy()
+	d.SetDisplayName(name)
+	return d
+}
+
+// Set stores the alias of the device identified by key (a MAC or IP address) and saves the
+// file. An empty name removes the alias.
+func (s *Store) Set(key, name string) error {
+	key = normalizeKey(key)
+	if key == "" {
+		return errors.New("alias key must be a MAC or IP address")
+	}
+	name = strings.TrimSpace(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, had := s.aliases[key]
+	if name == "" {
+		delete(s.aliases, key)
+	} else {
+		s.aliases[key] = name
+	}
+	if err := s.save(); err != nil {
+		if had {
+			s.aliases[key] = prev
+		} else {
+			delete(s.aliases, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// All returns a copy of all aliases by key.
+func (s *Store) All() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]string, len(s.aliases))
+	for k, v := range s.aliases {
+		out[k] = v
+	}
+	return out
+}
+
+// save writes the aliases to the file, s.mu must be held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := yaml.Marshal(s.aliases)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// normalizeKey lowercases MAC addresses and canonicalizes IP addresses, other keys are
+// rejected by returning an empty string.
+func normalizeKey(key string) string {
+	key = strings.TrimSpace(key)
+	if ip := net.ParseIP(key); ip != nil {
+		return ip.String()
+	}
+	if mac, err := net.ParseMAC(key); err == nil {
+		return mac.String()
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package aliases
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", FileName)
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	nas := discovery.NewDevice(net.ParseIP("10.0.0.2"))
+	nas.SetMAC("00:1B:63:00:00:02")
+	nas.SetDisplayName("synology")
+	printer := discovery.NewDevice(net.ParseIP("10.0.0.3"))
+
+	if err := s.Set(nas.IdentityKey(), "nas"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Set(printer.IdentityKey(), "printer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Set("not-an-address", "x"); err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+
+	moved := nas.Copy()
+	moved.SetIP(net.ParseIP("10.0.0.20"))
+	if got := s.Apply(moved); got.DisplayName() != "nas" || moved.DisplayName() != "synology" {
+		t.Fatalf("expected alias by MAC on a copy, got %q (original %q)", got.DisplayName(), moved.DisplayName())
+	}
+	if got := s.Apply(printer); got.DisplayName() != "printer" {
+		t.Fatalf("expected alias by IP, got %q", got.DisplayName())
+	}
+	other := discovery.NewDevice(net.ParseIP("10.0.0.4"))
+	if got := s.Apply(other); got != other {
+		t.Fatal("expected device without alias to be returned as is")
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"00:1b:63:00:00:02": "nas", "10.0.0.3": "printer"}
+	if got := reloaded.All(); len(got) != len(want) || got["00:1b:63:00:00:02"] != "nas" || got["10.0.0.3"] != "printer" {
+		t.Fatalf("expected %v after reload, got %v", want, got)
+	}
+
+	if err := reloaded.Set("10.0.0.3", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := reloaded.Lookup(printer); ok {
+		t.Fatal("expected alias to be removed")
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("- not\n- a map\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for invalid file")
+	}
+}
@@ -2,20 +2,42 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
 )
 
 const (
 	DefaultSplashEnabled  = true
 	DefaultThemeEnabled   = true
 	DefaultSweeperEnabled = true
+	DefaultOUIAutoRefresh = true
 	DefaultSplashDelay    = 1 * time.Second
 
-	DefaultPortScanTimeout = 5 * time.Second
+	DefaultPortScanTimeout         = 5 * time.Second
+	DefaultPortScanWorkers         = 100
+	DefaultPortScanHostConcurrency = 20
+	DefaultPortScanRateLimit       = 0
+
+	DefaultDaemonBind = "127.0.0.1"
+	DefaultDaemonPort = 8080
+	// DefaultDaemonSocketMode only allows the user running the daemon to connect to the socket.
+	DefaultDaemonSocketMode FileMode = 0o600
+
+	DefaultMQTTBroker          = "tcp://localhost:1883"
+	DefaultMQTTTopicPrefix     = "whosthere"
+	DefaultMQTTHomeAssistant   = true
+	DefaultMQTTDiscoveryPrefix = "homeassistant"
+
+	DefaultWebhookRetries = 3
+	DefaultWebhookTimeout = 10 * time.Second
 
 	DefaultThemeName = "default"
 	CustomThemeName  = "custom"
@@ -35,6 +57,8 @@ type Config struct {
 	Scanners     ScannerConfig     `yaml:"scanners"`
 	Sweeper      SweeperConfig     `yaml:"sweeper"`
 	PortScanner  PortScannerConfig `yaml:"port_scanner"`
+	OUI          OUIConfig         `yaml:"oui"`
+	Daemon       DaemonConfig      `yaml:"daemon"`
 	Splash       SplashConfig      `yaml:"splash"`
 	Theme        ThemeConfig       `yaml:"theme"`
 }
@@ -58,12 +82,154 @@ type SweeperConfig struct {
 	Timeout  time.Duration `yaml:"timeout"`
 }
 
-// PortScannerConfig defines TCP ports to scan.
+// PortScannerConfig defines TCP ports to scan and how aggressively to scan them.
 type PortScannerConfig struct {
-	TCP     []int         `yaml:"tcp"`
+	TCP             []int         `yaml:"tcp"`
+	Timeout         time.Duration `yaml:"timeout"`
+	Workers         int           `yaml:"workers"`
+	HostConcurrency int           `yaml:"host_concurrency"`
+	RateLimit       int           `yaml:"rate_limit"`
+}
+
+// OUIConfig controls the OUI registry used to resolve manufacturers from MAC addresses.
+type OUIConfig struct {
+	// AutoRefresh downloads the IEEE registries in the background when they are older than RefreshInterval.
+	AutoRefresh bool `yaml:"auto_refresh"`
+	// RefreshInterval is the maximum age of the OUI data before it is refreshed.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// MirrorURL replaces the IEEE download location, the registry file names (e.g. oui.csv) are appended to it.
+	MirrorURL string `yaml:"mirror_url"`
+	// OverridesFile is a YAML or CSV file with custom prefix mappings and vendor renames.
+	// When empty, oui_overrides.yaml (or oui_overrides.csv) in the config directory is used.
+	OverridesFile string `yaml:"overrides_file"`
+}
+
+// DaemonConfig controls the HTTP API of daemon mode.
+type DaemonConfig struct {
+	// Bind is the address the HTTP API listens on, empty listens on all interfaces.
+	Bind string           `yaml:"bind"`
+	Port int              `yaml:"port"`
+	Auth DaemonAuthConfig `yaml:"auth"`
+	TLS  DaemonTLSConfig  `yaml:"tls"`
+	// Socket additionally serves the HTTP API on a Unix domain socket.
+	Socket DaemonSocketConfig `yaml:"socket"`
+	MQTT   DaemonMQTTConfig   `yaml:"mqtt"`
+	// Webhooks are notified of device events, they can only be configured in the config file.
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// DaemonSocketConfig controls the Unix domain socket of the HTTP API. Access to the socket
+// is controlled by its file permissions instead of the bearer tokens.
+type DaemonSocketConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path of the socket, daemon.sock in the runtime directory when empty.
+	Path string `yaml:"path"`
+	// Mode is the file mode of the socket, e.g. 0660 to allow a group to connect.
+	Mode FileMode `yaml:"mode"`
+}
+
+// FileMode is a file mode written in octal, e.g. 0660 or "0660".
+type FileMode os.FileMode
+
+// ParseFileMode parses an octal file mode, with or without the leading 0 or 0o.
+func ParseFileMode(s string) (FileMode, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0o")
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q, expected an octal mode such as 0660", s)
+	}
+	return FileMode(mode), nil
+}
+
+// UnmarshalYAML parses the mode as octal, YAML would read an unquoted 660 as decimal.
+func (m *FileMode) UnmarshalYAML(data []byte) error {
+	mode, err := ParseFileMode(strings.Trim(strings.TrimSpace(string(data)), `"'`))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+func (m FileMode) String() string {
+	return fmt.Sprintf("%04o", uint32(m))
+}
+
+// DaemonMQTTConfig controls publishing device state to an MQTT broker.
+type DaemonMQTTConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or ssl://broker:8883.
+	Broker   string `yaml:"broker"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// ClientID identifies the connection to the broker, defaults to DefaultMQTTClientID().
+	ClientID string `yaml:"client_id"`
+	// TopicPrefix is the first level of the device topics, e.g. whosthere/devices/<id>/state.
+	TopicPrefix string `yaml:"topic_prefix"`
+	// HomeAssistant publishes Home Assistant MQTT discovery messages under DiscoveryPrefix.
+	HomeAssistant   bool   `yaml:"home_assistant"`
+	DiscoveryPrefix string `yaml:"discovery_prefix"`
+}
+
+// DaemonAuthConfig configures the bearer tokens of the HTTP API. Each token is either set
+// directly or read from a file, authentication is disabled when neither token is set.
+type DaemonAuthConfig struct {
+	// Token grants read-only access.
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	// AdminToken grants access to all endpoints, including the ones that start scans or change devices.
+	AdminToken     string `yaml:"admin_token"`
+	AdminTokenFile string `yaml:"admin_token_file"`
+}
+
+// DaemonTLSConfig enables HTTPS when both the certificate and the key are set.
+type DaemonTLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// WebhookConfig configures a URL that receives a POST request for every selected event.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Events are the event types to send, device_discovered when empty.
+	Events []string `yaml:"events"`
+	// Filter only sends events about devices matching it, using the scan --filter syntax.
+	Filter string `yaml:"filter"`
+	// Template is a Go text/template rendering the request body from the event, the event
+	// is sent as JSON when empty.
+	Template string `yaml:"template"`
+	// Secret signs the body with HMAC-SHA256 in the X-Whosthere-Signature header.
+	Secret  string            `yaml:"secret"`
+	Headers map[string]string `yaml:"headers"`
+	// Retries is the number of retries of a failed delivery, with exponential backoff.
+	// Defaults to DefaultWebhookRetries when not set, 0 disables retries.
+	Retries *int          `yaml:"retries"`
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// DefaultMQTTClientID returns whosthere followed by the host name, e.g. whosthere-nas, so
+// daemons on different hosts do not take over each other's broker connection. Characters
+// other than letters, digits, - and _ are left out, and so is the domain.
+func DefaultMQTTClientID() string {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	host = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, host)
+	if host == "" {
+		return "whosthere"
+	}
+	return "whosthere-" + host
+}
+
+// Enabled reports whether the HTTP API is served over TLS.
+func (t DaemonTLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
 // SplashConfig controls the splash screen visibility and timing.
 type SplashConfig struct {
 	Enabled bool          `yaml:"enabled"`
@@ -106,8 +272,28 @@ func DefaultConfig() *Config {
 			Timeout:  discovery.DefaultSweepTimeout,
 		},
 		PortScanner: PortScannerConfig{
-			TCP:     DefaultTCPPorts,
-			Timeout: DefaultPortScanTimeout,
+			TCP:             DefaultTCPPorts,
+			Timeout:         DefaultPortScanTimeout,
+			Workers:         DefaultPortScanWorkers,
+			HostConcurrency: DefaultPortScanHostConcurrency,
+			RateLimit:       DefaultPortScanRateLimit,
+		},
+		OUI: OUIConfig{
+			AutoRefresh:     DefaultOUIAutoRefresh,
+			RefreshInterval: oui.DefaultMaxAge,
+		},
+		Daemon: DaemonConfig{
+			Bind: DefaultDaemonBind,
+			Port: DefaultDaemonPort,
+			Socket: DaemonSocketConfig{
+				Mode: DefaultDaemonSocketMode,
+			},
+			MQTT: DaemonMQTTConfig{
+				Broker:          DefaultMQTTBroker,
+				TopicPrefix:     DefaultMQTTTopicPrefix,
+				HomeAssistant:   DefaultMQTTHomeAssistant,
+				DiscoveryPrefix: DefaultMQTTDiscoveryPrefix,
+			},
 		},
 		Splash: SplashConfig{
 			Enabled: DefaultSplashEnabled,
@@ -163,6 +349,23 @@ func (c *Config) normalizeBasics() error {
 		c.PortScanner.Timeout = DefaultPortScanTimeout
 	}
 
+	if c.PortScanner.Workers <= 0 {
+		c.PortScanner.Workers = DefaultPortScanWorkers
+	}
+
+	if c.PortScanner.HostConcurrency <= 0 {
+		c.PortScanner.HostConcurrency = DefaultPortScanHostConcurrency
+	}
+
+	if c.PortScanner.RateLimit < 0 {
+		errs = append(errs, "port_scanner.rate_limit must be >= 0")
+		c.PortScanner.RateLimit = DefaultPortScanRateLimit
+	}
+
+	if c.OUI.RefreshInterval <= 0 {
+		c.OUI.RefreshInterval = oui.DefaultMaxAge
+	}
+
 	if c.Sweeper.Interval <= 0 {
 		c.Sweeper.Interval = discovery.DefaultSweepInterval
 	}
@@ -171,6 +374,69 @@ func (c *Config) normalizeBasics() error {
 		c.Sweeper.Timeout = discovery.DefaultSweepTimeout
 	}
 
+	if c.Daemon.Port == 0 {
+		c.Daemon.Port = DefaultDaemonPort
+	}
+
+	if c.Daemon.Port < 0 || c.Daemon.Port > 65535 {
+		errs = append(errs, "daemon.port must be between 1 and 65535")
+		c.Daemon.Port = DefaultDaemonPort
+	}
+
+	if c.Daemon.Auth.Token != "" && c.Daemon.Auth.TokenFile != "" {
+		errs = append(errs, "daemon.auth.token and daemon.auth.token_file are mutually exclusive")
+	}
+
+	if c.Daemon.Auth.AdminToken != "" && c.Daemon.Auth.AdminTokenFile != "" {
+		errs = append(errs, "daemon.auth.admin_token and daemon.auth.admin_token_file are mutually exclusive")
+	}
+
+	if (c.Daemon.TLS.CertFile == "") != (c.Daemon.TLS.KeyFile == "") {
+		errs = append(errs, "daemon.tls.cert_file and daemon.tls.key_file must be set together")
+	}
+
+	if c.Daemon.Socket.Mode == 0 {
+		c.Daemon.Socket.Mode = DefaultDaemonSocketMode
+	}
+	if c.Daemon.Socket.Mode > 0o777 {
+		errs = append(errs, "daemon.socket.mode must be an octal file mode such as 0660")
+		c.Daemon.Socket.Mode = DefaultDaemonSocketMode
+	}
+
+	if c.Daemon.MQTT.Enabled && strings.TrimSpace(c.Daemon.MQTT.Broker) == "" {
+		errs = append(errs, "daemon.mqtt.broker must be set when MQTT is enabled")
+		c.Daemon.MQTT.Enabled = false
+	}
+
+	if strings.TrimSpace(c.Daemon.MQTT.ClientID) == "" {
+		c.Daemon.MQTT.ClientID = DefaultMQTTClientID()
+	}
+
+	if strings.Trim(c.Daemon.MQTT.TopicPrefix, "/ ") == "" {
+		c.Daemon.MQTT.TopicPrefix = DefaultMQTTTopicPrefix
+	}
+
+	if strings.Trim(c.Daemon.MQTT.DiscoveryPrefix, "/ ") == "" {
+		c.Daemon.MQTT.DiscoveryPrefix = DefaultMQTTDiscoveryPrefix
+	}
+
+	for i := range c.Daemon.Webhooks {
+		hook := &c.Daemon.Webhooks[i]
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("daemon.webhooks[%d].url must be an http(s) URL", i))
+		}
+		switch {
+		case hook.Retries == nil:
+			retries := DefaultWebhookRetries
+			hook.Retries = &retries
+		case *hook.Retries < 0:
+			errs = append(errs, fmt.Sprintf("daemon.webhooks[%d].retries must not be negative", i))
+		}
+		if hook.Timeout <= 0 {
+			hook.Timeout = DefaultWebhookTimeout
+		}
+	}
+
 	if strings.TrimSpace(c.Theme.Name) == "" {
 		c.Theme.Name = DefaultThemeName
 	}
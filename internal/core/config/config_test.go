@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -174,3 +175,123 @@ splash:
 		t.Errorf("expected default splash delay %v, got %v", DefaultSplashDelay, cfg.Splash.Delay)
 	}
 }
+
+func TestValidateAndNormalizeDaemon(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Daemon.Port = 70000
+	cfg.Daemon.Auth = DaemonAuthConfig{Token: "a", TokenFile: "/tmp/a", AdminToken: "b", AdminTokenFile: "/tmp/b"}
+	cfg.Daemon.TLS = DaemonTLSConfig{CertFile: "/tmp/tls.crt"}
+	cfg.Daemon.Socket.Mode = 0o1777
+
+	err := cfg.validateAndNormalize()
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, expected := range []string{
+		"daemon.port must be between 1 and 65535",
+		"daemon.auth.token and daemon.auth.token_file are mutually exclusive",
+		"daemon.auth.admin_token and daemon.auth.admin_token_file are mutually exclusive",
+		"daemon.tls.cert_file and daemon.tls.key_file must be set together",
+		"daemon.socket.mode must be an octal file mode such as 0660",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q in %q", expected, err.Error())
+		}
+	}
+	if cfg.Daemon.Port != DefaultDaemonPort {
+		t.Errorf("expected default daemon port %d, got %d", DefaultDaemonPort, cfg.Daemon.Port)
+	}
+	if cfg.Daemon.TLS.Enabled() {
+		t.Errorf("expected TLS to be disabled without a key")
+	}
+	if cfg.Daemon.Socket.Mode != DefaultDaemonSocketMode {
+		t.Errorf("expected default socket mode %s, got %s", DefaultDaemonSocketMode, cfg.Daemon.Socket.Mode)
+	}
+	if cfg.Daemon.MQTT.ClientID != DefaultMQTTClientID() {
+		t.Errorf("expected default MQTT client ID %s, got %s", DefaultMQTTClientID(), cfg.Daemon.MQTT.ClientID)
+	}
+}
+
+func TestDefaultMQTTClientID(t *testing.T) {
+	id := DefaultMQTTClientID()
+	if !strings.HasPrefix(id, "whosthere") || strings.ContainsAny(id, ". ") {
+		t.Errorf("unexpected client ID %q", id)
+	}
+	if host, err := os.Hostname(); err == nil && host != "" && id == "whosthere" {
+		t.Errorf("expected the host name %q in the client ID", host)
+	}
+}
+
+func TestYAMLUnmarshalFileMode(t *testing.T) {
+	for raw, expected := range map[string]FileMode{
+		"mode: 0660":   0o660,
+		"mode: 660":    0o660,
+		`mode: "0640"`: 0o640,
+		"mode: 0o600":  0o600,
+		"mode: '0755'": 0o755,
+	} {
+		var v struct {
+			Mode FileMode `yaml:"mode"`
+		}
+		if err := yaml.Unmarshal([]byte(raw), &v); err != nil {
+			t.Errorf("unmarshal %q: %v", raw, err)
+			continue
+		}
+		if v.Mode != expected {
+			t.Errorf("unmarshal %q: expected %s, got %s", raw, expected, v.Mode)
+		}
+	}
+
+	for _, raw := range []string{"mode: 0999", "mode: 1777", "mode: rw-"} {
+		var v struct {
+			Mode FileMode `yaml:"mode"`
+		}
+		if err := yaml.Unmarshal([]byte(raw), &v); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestYAMLUnmarshalWebhooks(t *testing.T) {
+	raw := `
+daemon:
+  webhooks:
+    - url: https://chat.example.com/hooks/abc
+      events: [device_discovered, device_offline]
+      filter: vendor~apple
+      secret: s3cret
+      headers:
+        X-Team: infra
+      retries: 5
+    - url: ftp://example.com
+    - url: https://example.com/hook
+      retries: 0
+`
+
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		t.Fatalf("unmarshal yaml: %v", err)
+	}
+
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "daemon.webhooks[1].url must be an http(s) URL") {
+		t.Fatalf("expected webhook url error, got %v", err)
+	}
+
+	hooks := cfg.Daemon.Webhooks
+	if len(hooks) != 3 {
+		t.Fatalf("expected 3 webhooks, got %d", len(hooks))
+	}
+	if hooks[0].URL != "https://chat.example.com/hooks/abc" || len(hooks[0].Events) != 2 || hooks[0].Filter != "vendor~apple" {
+		t.Errorf("unexpected webhook: %+v", hooks[0])
+	}
+	if hooks[0].Secret != "s3cret" || hooks[0].Headers["X-Team"] != "infra" || *hooks[0].Retries != 5 {
+		t.Errorf("unexpected webhook: %+v", hooks[0])
+	}
+	if *hooks[1].Retries != DefaultWebhookRetries || hooks[1].Timeout != DefaultWebhookTimeout {
+		t.Errorf("expected webhook defaults, got %+v", hooks[1])
+	}
+	if *hooks[2].Retries != 0 {
+		t.Errorf("expected retries to be disabled, got %d", *hooks[2].Retries)
+	}
+}
@@ -263,6 +263,57 @@ func GlobalSettings() []GlobalSetting {
 			Get: func(c *Config) any { return c.PortScanner.Timeout },
 			Doc: YAMLDoc{},
 		},
+		{
+			YAMLKey: "port_scanner.workers",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				i, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Workers = i
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Workers },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of concurrent connection attempts, shared across all scanned hosts",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.host_concurrency",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				i, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.HostConcurrency = i
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.HostConcurrency },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of concurrent connection attempts against a single host",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.rate_limit",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				i, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.RateLimit = i
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.RateLimit },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of connection attempts per second, 0 disables rate limiting",
+			},
+		},
 		{
 			YAMLKey: "port_scanner.tcp",
 			Type:    FlagTypeString,
@@ -280,6 +331,302 @@ func GlobalSettings() []GlobalSetting {
 				Comment: "List of TCP ports to scan on discovered devices",
 			},
 		},
+		{
+			YAMLKey: "oui.auto_refresh",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.OUI.AutoRefresh = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.OUI.AutoRefresh },
+			Doc: YAMLDoc{
+				Comment: "Download the IEEE OUI registries in the background when they are older than the refresh interval\nDisable on offline networks and use `whosthere oui update --from-file` instead",
+			},
+		},
+		{
+			YAMLKey: "oui.refresh_interval",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				d, err := parseDuration(v)
+				if err != nil {
+					return err
+				}
+				c.OUI.RefreshInterval = d
+				return nil
+			},
+			Get: func(c *Config) any { return c.OUI.RefreshInterval },
+			Doc: YAMLDoc{
+				Comment: "Maximum age of the OUI data before it is refreshed",
+			},
+		},
+		{
+			YAMLKey: "oui.mirror_url",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.OUI.MirrorURL = v; return nil },
+			Get:     func(c *Config) any { return c.OUI.MirrorURL },
+			Doc: YAMLDoc{
+				Comment:      "Download the OUI registries from a mirror instead of the IEEE, the file names (e.g. oui.csv) are appended",
+				ExampleValue: "https://mirror.example.com/ieee/",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "oui.overrides_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.OUI.OverridesFile = v; return nil },
+			Get:     func(c *Config) any { return c.OUI.OverridesFile },
+			Doc: YAMLDoc{
+				Comment:      "YAML or CSV file with custom MAC prefix to vendor mappings and vendor name rewrites\nDefaults to oui_overrides.yaml (or oui_overrides.csv) in the config directory, changes are picked up while running",
+				ExampleValue: "/path/to/oui_overrides.yaml",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.bind",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Bind = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.Bind },
+			Doc: YAMLDoc{
+				Comment: "Address the daemon HTTP API listens on, set to \"0.0.0.0\" (or \"\") to listen on all interfaces",
+			},
+		},
+		{
+			YAMLKey: "daemon.port",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				i, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.Daemon.Port = i
+				return nil
+			},
+			Get: func(c *Config) any { return c.Daemon.Port },
+			Doc: YAMLDoc{
+				Comment: "Port of the daemon HTTP API, the --port flag of the daemon command takes precedence",
+			},
+		},
+		{
+			YAMLKey: "daemon.auth.token",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Auth.Token = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.Auth.Token },
+			Doc: YAMLDoc{
+				Comment:      "Bearer tokens of the daemon HTTP API, authentication is disabled when no token is set\nThe token grants read-only access, the admin token also allows starting scans and setting aliases\nPrefer token files or environment variables (e.g. WHOSTHERE__DAEMON__AUTH__TOKEN) over tokens in this file",
+				ExampleValue: "\"change-me\"",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.auth.token_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Auth.TokenFile = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.Auth.TokenFile },
+			Doc: YAMLDoc{
+				ExampleValue: "/run/secrets/whosthere_token",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.auth.admin_token",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Auth.AdminToken = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.Auth.AdminToken },
+			Doc: YAMLDoc{
+				ExampleValue: "\"change-me-too\"",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.auth.admin_token_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Auth.AdminTokenFile = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.Auth.AdminTokenFile },
+			Doc: YAMLDoc{
+				ExampleValue: "/run/secrets/whosthere_admin_token",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.tls.cert_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.TLS.CertFile = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.TLS.CertFile },
+			Doc: YAMLDoc{
+				Comment:      "Serve the daemon HTTP API over HTTPS with a PEM encoded certificate and key",
+				ExampleValue: "/etc/whosthere/tls.crt",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.tls.key_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.TLS.KeyFile = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.TLS.KeyFile },
+			Doc: YAMLDoc{
+				ExampleValue: "/etc/whosthere/tls.key",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.socket.enabled",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.Daemon.Socket.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.Daemon.Socket.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Also serve the daemon HTTP API on a Unix domain socket, access is controlled by its file mode instead of tokens",
+			},
+		},
+		{
+			YAMLKey: "daemon.socket.path",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.Socket.Path = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.Socket.Path },
+			Doc: YAMLDoc{
+				Comment:      "Defaults to daemon.sock in $XDG_RUNTIME_DIR/whosthere, or run in the state directory",
+				ExampleValue: "/run/whosthere/daemon.sock",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.socket.mode",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				mode, err := ParseFileMode(v)
+				if err != nil {
+					return err
+				}
+				c.Daemon.Socket.Mode = mode
+				return nil
+			},
+			Get: func(c *Config) any { return c.Daemon.Socket.Mode.String() },
+			Doc: YAMLDoc{
+				Comment: "Octal file mode of the socket, e.g. 0660 to allow the group of the socket to connect",
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.enabled",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.Daemon.MQTT.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.Daemon.MQTT.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Publish device state to an MQTT broker in daemon mode",
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.broker",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.Broker = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.Broker },
+			Doc: YAMLDoc{
+				Comment: "Broker URL, use ssl://host:8883 for TLS",
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.username",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.Username = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.Username },
+			Doc: YAMLDoc{
+				ExampleValue: "whosthere",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.password",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.Password = v; return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.Password },
+			Doc: YAMLDoc{
+				Comment:      "Prefer the WHOSTHERE__DAEMON__MQTT__PASSWORD environment variable over a password in this file",
+				ExampleValue: "\"change-me\"",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.client_id",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.ClientID = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.ClientID },
+			Doc: YAMLDoc{
+				Comment:      "Defaults to whosthere-<hostname>, must be unique per broker",
+				ExampleValue: "whosthere-nas",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.topic_prefix",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.TopicPrefix = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.TopicPrefix },
+			Doc: YAMLDoc{
+				Comment: "Device state is published to <topic_prefix>/devices/<id>/state, ip, name, vendor and attributes",
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.home_assistant",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.Daemon.MQTT.HomeAssistant = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.Daemon.MQTT.HomeAssistant },
+			Doc: YAMLDoc{
+				Comment: "Publish Home Assistant MQTT discovery messages, every device becomes a device_tracker entity",
+			},
+		},
+		{
+			YAMLKey: "daemon.mqtt.discovery_prefix",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.Daemon.MQTT.DiscoveryPrefix = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.Daemon.MQTT.DiscoveryPrefix },
+			Doc:     YAMLDoc{},
+		},
 		{
 			YAMLKey: "splash.enabled",
 			Type:    FlagTypeBool,
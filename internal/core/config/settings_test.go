@@ -124,6 +124,36 @@ func getSettingTestCases() []settingTestCase {
 			yamlValue:    "6s",
 			expectedYAML: 6 * time.Second,
 		},
+		{
+			yamlKey:      "port_scanner.workers",
+			envVar:       "WHOSTHERE__PORT_SCANNER__WORKERS",
+			envValue:     "50",
+			expectedEnv:  50,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "25",
+			expectedYAML: 25,
+		},
+		{
+			yamlKey:      "port_scanner.host_concurrency",
+			envVar:       "WHOSTHERE__PORT_SCANNER__HOST_CONCURRENCY",
+			envValue:     "4",
+			expectedEnv:  4,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "8",
+			expectedYAML: 8,
+		},
+		{
+			yamlKey:      "port_scanner.rate_limit",
+			envVar:       "WHOSTHERE__PORT_SCANNER__RATE_LIMIT",
+			envValue:     "200",
+			expectedEnv:  200,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "100",
+			expectedYAML: 100,
+		},
 		{
 			yamlKey:      "port_scanner.tcp",
 			envVar:       "WHOSTHERE__PORT_SCANNER__TCP",
@@ -134,6 +164,236 @@ func getSettingTestCases() []settingTestCase {
 			yamlValue:    "[22, 80]",
 			expectedYAML: []int{22, 80},
 		},
+		{
+			yamlKey:      "oui.auto_refresh",
+			envVar:       "WHOSTHERE__OUI__AUTO_REFRESH",
+			envValue:     "false",
+			expectedEnv:  false,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "false",
+			expectedYAML: false,
+		},
+		{
+			yamlKey:      "oui.refresh_interval",
+			envVar:       "WHOSTHERE__OUI__REFRESH_INTERVAL",
+			envValue:     "24h",
+			expectedEnv:  24 * time.Hour,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "168h",
+			expectedYAML: 168 * time.Hour,
+		},
+		{
+			yamlKey:      "oui.mirror_url",
+			envVar:       "WHOSTHERE__OUI__MIRROR_URL",
+			envValue:     "https://env.example.com/ieee/",
+			expectedEnv:  "https://env.example.com/ieee/",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "https://yaml.example.com/ieee/",
+			expectedYAML: "https://yaml.example.com/ieee/",
+		},
+		{
+			yamlKey:      "oui.overrides_file",
+			envVar:       "WHOSTHERE__OUI__OVERRIDES_FILE",
+			envValue:     "/tmp/env_overrides.yaml",
+			expectedEnv:  "/tmp/env_overrides.yaml",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/tmp/overrides.csv",
+			expectedYAML: "/tmp/overrides.csv",
+		},
+		{
+			yamlKey:      "daemon.bind",
+			envVar:       "WHOSTHERE__DAEMON__BIND",
+			envValue:     "0.0.0.0",
+			expectedEnv:  "0.0.0.0",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "192.168.1.2",
+			expectedYAML: "192.168.1.2",
+		},
+		{
+			yamlKey:      "daemon.port",
+			envVar:       "WHOSTHERE__DAEMON__PORT",
+			envValue:     "9090",
+			expectedEnv:  9090,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "8081",
+			expectedYAML: 8081,
+		},
+		{
+			yamlKey:      "daemon.auth.token",
+			envVar:       "WHOSTHERE__DAEMON__AUTH__TOKEN",
+			envValue:     "env-token",
+			expectedEnv:  "env-token",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "yaml-token",
+			expectedYAML: "yaml-token",
+		},
+		{
+			yamlKey:      "daemon.auth.token_file",
+			envVar:       "WHOSTHERE__DAEMON__AUTH__TOKEN_FILE",
+			envValue:     "/tmp/env_token",
+			expectedEnv:  "/tmp/env_token",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/tmp/token",
+			expectedYAML: "/tmp/token",
+		},
+		{
+			yamlKey:      "daemon.auth.admin_token",
+			envVar:       "WHOSTHERE__DAEMON__AUTH__ADMIN_TOKEN",
+			envValue:     "env-admin",
+			expectedEnv:  "env-admin",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "yaml-admin",
+			expectedYAML: "yaml-admin",
+		},
+		{
+			yamlKey:      "daemon.auth.admin_token_file",
+			envVar:       "WHOSTHERE__DAEMON__AUTH__ADMIN_TOKEN_FILE",
+			envValue:     "/tmp/env_admin_token",
+			expectedEnv:  "/tmp/env_admin_token",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/tmp/admin_token",
+			expectedYAML: "/tmp/admin_token",
+		},
+		{
+			yamlKey:      "daemon.tls.cert_file",
+			envVar:       "WHOSTHERE__DAEMON__TLS__CERT_FILE",
+			envValue:     "/tmp/env.crt",
+			expectedEnv:  "/tmp/env.crt",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/tmp/tls.crt",
+			expectedYAML: "/tmp/tls.crt",
+		},
+		{
+			yamlKey:      "daemon.tls.key_file",
+			envVar:       "WHOSTHERE__DAEMON__TLS__KEY_FILE",
+			envValue:     "/tmp/env.key",
+			expectedEnv:  "/tmp/env.key",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/tmp/tls.key",
+			expectedYAML: "/tmp/tls.key",
+		},
+		{
+			yamlKey:      "daemon.socket.enabled",
+			envVar:       "WHOSTHERE__DAEMON__SOCKET__ENABLED",
+			envValue:     "true",
+			expectedEnv:  true,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "true",
+			expectedYAML: true,
+		},
+		{
+			yamlKey:      "daemon.socket.path",
+			envVar:       "WHOSTHERE__DAEMON__SOCKET__PATH",
+			envValue:     "/tmp/env.sock",
+			expectedEnv:  "/tmp/env.sock",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/run/whosthere/daemon.sock",
+			expectedYAML: "/run/whosthere/daemon.sock",
+		},
+		{
+			yamlKey:      "daemon.socket.mode",
+			envVar:       "WHOSTHERE__DAEMON__SOCKET__MODE",
+			envValue:     "660",
+			expectedEnv:  "0660",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "0640",
+			expectedYAML: "0640",
+		},
+		{
+			yamlKey:      "daemon.mqtt.enabled",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__ENABLED",
+			envValue:     "true",
+			expectedEnv:  true,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "true",
+			expectedYAML: true,
+		},
+		{
+			yamlKey:      "daemon.mqtt.broker",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__BROKER",
+			envValue:     "tcp://env:1883",
+			expectedEnv:  "tcp://env:1883",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "ssl://yaml:8883",
+			expectedYAML: "ssl://yaml:8883",
+		},
+		{
+			yamlKey:      "daemon.mqtt.username",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__USERNAME",
+			envValue:     "env-user",
+			expectedEnv:  "env-user",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "yaml-user",
+			expectedYAML: "yaml-user",
+		},
+		{
+			yamlKey:      "daemon.mqtt.password",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__PASSWORD",
+			envValue:     "env-pass",
+			expectedEnv:  "env-pass",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "yaml-pass",
+			expectedYAML: "yaml-pass",
+		},
+		{
+			yamlKey:      "daemon.mqtt.client_id",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__CLIENT_ID",
+			envValue:     "env-client",
+			expectedEnv:  "env-client",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "yaml-client",
+			expectedYAML: "yaml-client",
+		},
+		{
+			yamlKey:      "daemon.mqtt.topic_prefix",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__TOPIC_PREFIX",
+			envValue:     "env/prefix",
+			expectedEnv:  "env/prefix",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "lan",
+			expectedYAML: "lan",
+		},
+		{
+			yamlKey:      "daemon.mqtt.home_assistant",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__HOME_ASSISTANT",
+			envValue:     "false",
+			expectedEnv:  false,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "false",
+			expectedYAML: false,
+		},
+		{
+			yamlKey:      "daemon.mqtt.discovery_prefix",
+			envVar:       "WHOSTHERE__DAEMON__MQTT__DISCOVERY_PREFIX",
+			envValue:     "ha",
+			expectedEnv:  "ha",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "hass",
+			expectedYAML: "hass",
+		},
 		{
 			yamlKey:      "splash.enabled",
 			envVar:       "WHOSTHERE__SPLASH__ENABLED",
@@ -494,8 +754,40 @@ sweeper:
 
 port_scanner:
   timeout: 7s
+  workers: 64
+  host_concurrency: 6
+  rate_limit: 250
   tcp: [22, 80, 443, 8080]
 
+oui:
+  auto_refresh: false
+  refresh_interval: 48h
+  mirror_url: https://mirror.example.com/ieee/
+  overrides_file: /etc/whosthere/oui_overrides.yaml
+
+daemon:
+  bind: 0.0.0.0
+  port: 9191
+  auth:
+    token_file: /run/secrets/token
+    admin_token: admin-secret
+  tls:
+    cert_file: /etc/whosthere/tls.crt
+    key_file: /etc/whosthere/tls.key
+  socket:
+    enabled: true
+    path: /run/whosthere/daemon.sock
+    mode: 0660
+  mqtt:
+    enabled: true
+    broker: ssl://mqtt.example.com:8883
+    username: whosthere
+    password: mqtt-secret
+    client_id: whosthere-lan
+    topic_prefix: lan
+    home_assistant: false
+    discovery_prefix: hass
+
 splash:
   enabled: false
   delay: 750ms
@@ -541,7 +833,33 @@ theme:
 		{"sweeper.interval", cfg.Sweeper.Interval, 8 * time.Minute},
 		{"sweeper.timeout", cfg.Sweeper.Timeout, 4 * time.Second},
 		{"port_scanner.timeout", cfg.PortScanner.Timeout, 7 * time.Second},
+		{"port_scanner.workers", cfg.PortScanner.Workers, 64},
+		{"port_scanner.host_concurrency", cfg.PortScanner.HostConcurrency, 6},
+		{"port_scanner.rate_limit", cfg.PortScanner.RateLimit, 250},
 		{"port_scanner.tcp", cfg.PortScanner.TCP, []int{22, 80, 443, 8080}},
+		{"oui.auto_refresh", cfg.OUI.AutoRefresh, false},
+		{"oui.refresh_interval", cfg.OUI.RefreshInterval, 48 * time.Hour},
+		{"oui.mirror_url", cfg.OUI.MirrorURL, "https://mirror.example.com/ieee/"},
+		{"oui.overrides_file", cfg.OUI.OverridesFile, "/etc/whosthere/oui_overrides.yaml"},
+		{"daemon.bind", cfg.Daemon.Bind, "0.0.0.0"},
+		{"daemon.port", cfg.Daemon.Port, 9191},
+		{"daemon.auth.token", cfg.Daemon.Auth.Token, ""},
+		{"daemon.auth.token_file", cfg.Daemon.Auth.TokenFile, "/run/secrets/token"},
+		{"daemon.auth.admin_token", cfg.Daemon.Auth.AdminToken, "admin-secret"},
+		{"daemon.auth.admin_token_file", cfg.Daemon.Auth.AdminTokenFile, ""},
+		{"daemon.tls.cert_file", cfg.Daemon.TLS.CertFile, "/etc/whosthere/tls.crt"},
+		{"daemon.tls.key_file", cfg.Daemon.TLS.KeyFile, "/etc/whosthere/tls.key"},
+		{"daemon.socket.enabled", cfg.Daemon.Socket.Enabled, true},
+		{"daemon.socket.path", cfg.Daemon.Socket.Path, "/run/whosthere/daemon.sock"},
+		{"daemon.socket.mode", cfg.Daemon.Socket.Mode, FileMode(0o660)},
+		{"daemon.mqtt.enabled", cfg.Daemon.MQTT.Enabled, true},
+		{"daemon.mqtt.broker", cfg.Daemon.MQTT.Broker, "ssl://mqtt.example.com:8883"},
+		{"daemon.mqtt.username", cfg.Daemon.MQTT.Username, "whosthere"},
+		{"daemon.mqtt.password", cfg.Daemon.MQTT.Password, "mqtt-secret"},
+		{"daemon.mqtt.client_id", cfg.Daemon.MQTT.ClientID, "whosthere-lan"},
+		{"daemon.mqtt.topic_prefix", cfg.Daemon.MQTT.TopicPrefix, "lan"},
+		{"daemon.mqtt.home_assistant", cfg.Daemon.MQTT.HomeAssistant, false},
+		{"daemon.mqtt.discovery_prefix", cfg.Daemon.MQTT.DiscoveryPrefix, "hass"},
 		{"splash.enabled", cfg.Splash.Enabled, false},
 		{"splash.delay", cfg.Splash.Delay, 750 * time.Millisecond},
 		{"theme.enabled", cfg.Theme.Enabled, false},
@@ -0,0 +1,235 @@
+// Package diff compares two scans and reports the devices that were added, removed or changed.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// FieldChange is a single changed field of a device.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// DeviceChange describes a device that is present in both scans but differs.
+type DeviceChange struct {
+	Old    *discovery.Device `json:"old"`
+	New    *discovery.Device `json:"new"`
+	Fields []FieldChange     `json:"changes,omitempty"`
+	// Ports is only set when both scans contain port scan results for the device.
+	Ports *discovery.PortChanges `json:"ports,omitempty"`
+}
+
+// Result is the difference between two scans. Devices are sorted by IP.
+type Result struct {
+	Added   []*discovery.Device `json:"added"`
+	Removed []*discovery.Device `json:"removed"`
+	Changed []DeviceChange      `json:"changed"`
+}
+
+// Empty reports whether both scans contain the same devices.
+func (r *Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// Compare matches the devices of two scans by identity key (see discovery.Device.IdentityKey),
+// i.e. by MAC address unless it is randomized, falling back to the IP address for devices
+// whose key is not in the other scan, and returns the differences.
+func Compare(oldDevices, newDevices []*discovery.Device) *Result {
+	res := &Result{
+		Added:   []*discovery.Device{},
+		Removed: []*discovery.Device{},
+		Changed: []DeviceChange{},
+	}
+
+	matched := make(map[*discovery.Device]*discovery.Device, len(oldDevices))
+	used := make(map[*discovery.Device]bool, len(newDevices))
+
+	// A MAC can answer on several IPs, so devices sharing a key are first matched on IP and
+	// then with any device of that key that is still unused.
+	byKey := make(map[string][]*discovery.Device, len(newDevices))
+	for _, d := range newDevices {
+		if key := d.IdentityKey(); key != "" {
+			byKey[key] = append(byKey[key], d)
+		}
+	}
+	for _, sameIP := range []bool{true, false} {
+		for _, d := range oldDevices {
+			if _, ok := matched[d]; ok {
+				continue
+			}
+			for _, n := range byKey[d.IdentityKey()] {
+				if used[n] || (sameIP && ipString(d) != ipString(n)) {
+					continue
+				}
+				matched[d] = n
+				used[n] = true
+				break
+			}
+		}
+	}
+
+	byIP := make(map[string]*discovery.Device, len(newDevices))
+	for _, d := range newDevices {
+		if !used[d] && d.IP() != nil {
+			byIP[d.IP().String()] = d
+		}
+	}
+	for _, d := range oldDevices {
+		if _, ok := matched[d]; ok || d.IP() == nil {
+			continue
+		}
+		if n, ok := byIP[d.IP().String()]; ok && !used[n] {
+			matched[d] = n
+			used[n] = true
+		}
+	}
+
+	for _, d := range oldDevices {
+		n, ok := matched[d]
+		if !ok {
+			res.Removed = append(res.Removed, d)
+			continue
+		}
+		if change, ok := compareDevice(d, n); ok {
+			res.Changed = append(res.Changed, change)
+		}
+	}
+	for _, d := range newDevices {
+		if !used[d] {
+			res.Added = append(res.Added, d)
+		}
+	}
+
+	sortDevices(res.Added)
+	sortDevices(res.Removed)
+	sort.SliceStable(res.Changed, func(i, j int) bool {
+		return discovery.CompareIPs(res.Changed[i].New.IP(), res.Changed[j].New.IP())
+	})
+	return res
+}
+
+// compareDevice returns the differences between two matched devices, the second return value
+// is false when nothing changed.
+func compareDevice(o, n *discovery.Device) (DeviceChange, bool) {
+	change := DeviceChange{Old: o, New: n}
+
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"name", o.DisplayName(), n.DisplayName()},
+		{"ip", ipString(o), ipString(n)},
+		{"mac", o.MAC(), n.MAC()},
+		{"vendor", o.Manufacturer(), n.Manufacturer()},
+	}
+	for _, f := range fields {
+		if f.name == "mac" && strings.EqualFold(f.old, f.new) {
+			continue
+		}
+		if f.old != f.new {
+			change.Fields = append(change.Fields, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	if portScanned(o) && portScanned(n) {
+		if ports := discovery.DiffPorts(o.OpenPorts(), n.OpenPorts()); !ports.Empty() {
+			change.Ports = ports
+		}
+	}
+
+	return change, len(change.Fields) > 0 || change.Ports != nil
+}
+
+// portScanned reports whether the scan contains port scan results for d.
+func portScanned(d *discovery.Device) bool {
+	return !d.LastPortScan().IsZero() || len(d.OpenPorts()) > 0
+}
+
+func ipString(d *discovery.Device) string {
+	if d.IP() == nil {
+		return ""
+	}
+	return d.IP().String()
+}
+
+func sortDevices(devices []*discovery.Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		return discovery.CompareIPs(devices[i].IP(), devices[j].IP())
+	})
+}
+
+// LoadFile reads the devices of a saved scan, see Load.
+func LoadFile(path string) ([]*discovery.Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	devices, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan %s: %w", path, err)
+	}
+	return devices, nil
+}
+
+// Load reads the devices of a scan saved with `whosthere scan --json` or `--format=ndjson`.
+// A plain JSON array of devices is accepted as well.
+func Load(r io.Reader) ([]*discovery.Device, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty scan")
+	}
+
+	if data[0] == '[' {
+		var devices []*discovery.Device
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return nil, err
+		}
+		return devices, nil
+	}
+
+	var devices []*discovery.Device
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		var results struct {
+			Devices []*discovery.Device `json:"devices"`
+		}
+		if err := json.Unmarshal(raw, &results); err != nil {
+			return nil, err
+		}
+		if results.Devices != nil {
+			devices = append(devices, results.Devices...)
+			continue
+		}
+
+		d := &discovery.Device{}
+		if err := json.Unmarshal(raw, d); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
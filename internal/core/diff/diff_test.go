@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func device(ip, mac, name string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetMAC(mac)
+	d.SetDisplayName(name)
+	return d
+}
+
+func TestCompare(t *testing.T) {
+	oldScan := []*discovery.Device{
+		device("10.0.0.1", "00:1a:2b:3c:4d:01", "router"),
+		device("10.0.0.2", "00:1a:2b:3c:4d:02", "nas"),
+		device("10.0.0.3", "", "printer"),
+		device("10.0.0.4", "00:1a:2b:3c:4d:04", "tv"),
+	}
+	newScan := []*discovery.Device{
+		device("10.0.0.1", "00:1A:2B:3C:4D:01", "router"),
+		device("10.0.0.20", "00:1a:2b:3c:4d:02", "nas"),
+		device("10.0.0.3", "", "printer.lan"),
+		device("10.0.0.5", "00:1a:2b:3c:4d:05", "phone"),
+	}
+
+	res := Compare(oldScan, newScan)
+	if res.Empty() {
+		t.Fatal("expected differences")
+	}
+	if len(res.Added) != 1 || res.Added[0].DisplayName() != "phone" {
+		t.Fatalf("expected phone to be added, got %v", res.Added)
+	}
+	if len(res.Removed) != 1 || res.Removed[0].DisplayName() != "tv" {
+		t.Fatalf("expected tv to be removed, got %v", res.Removed)
+	}
+	if len(res.Changed) != 2 {
+		t.Fatalf("expected 2 changed devices, got %+v", res.Changed)
+	}
+
+	nas := res.Changed[1]
+	if len(nas.Fields) != 1 || nas.Fields[0] != (FieldChange{Field: "ip", Old: "10.0.0.2", New: "10.0.0.20"}) {
+		t.Fatalf("expected nas to be matched by MAC with an IP change, got %+v", nas.Fields)
+	}
+	printer := res.Changed[0]
+	if len(printer.Fields) != 1 || printer.Fields[0] != (FieldChange{Field: "name", Old: "printer", New: "printer.lan"}) {
+		t.Fatalf("expected printer to be matched by IP with a name change, got %+v", printer.Fields)
+	}
+}
+
+func TestCompareRandomizedMAC(t *testing.T) {
+	oldScan := []*discovery.Device{
+		device("10.0.0.6", "da:a1:19:00:00:01", "phone"),
+		device("10.0.0.7", "da:a1:19:00:00:02", "tablet"),
+	}
+	newScan := []*discovery.Device{
+		device("10.0.0.6", "da:a1:19:00:00:03", "phone"),
+		device("10.0.0.8", "da:a1:19:00:00:02", "watch"),
+	}
+
+	res := Compare(oldScan, newScan)
+	if len(res.Changed) != 1 || res.Changed[0].Fields[0] != (FieldChange{Field: "mac", Old: "da:a1:19:00:00:01", New: "da:a1:19:00:00:03"}) {
+		t.Fatalf("expected phone to be matched by IP with a MAC change, got %+v", res.Changed)
+	}
+	if len(res.Removed) != 1 || res.Removed[0].DisplayName() != "tablet" || len(res.Added) != 1 || res.Added[0].DisplayName() != "watch" {
+		t.Fatalf("expected a randomized MAC not to match across IPs, got added %v, removed %v", res.Added, res.Removed)
+	}
+}
+
+func TestCompareMACOnMultipleIPs(t *testing.T) {
+	scan := func() []*discovery.Device {
+		return []*discovery.Device{
+			device("10.0.0.1", "00:11:22:33:44:55", "router"),
+			device("10.0.0.2", "00:11:22:33:44:55", "router"),
+		}
+	}
+	if res := Compare(scan(), scan()); !res.Empty() {
+		t.Fatalf("expected identical scans to have no differences, got added %v, removed %v, changed %+v", res.Added, res.Removed, res.Changed)
+	}
+
+	oldScan := scan()
+	newScan := []*discovery.Device{
+		device("10.0.0.2", "00:11:22:33:44:55", "router"),
+		device("10.0.0.3", "00:11:22:33:44:55", "router"),
+	}
+	res := Compare(oldScan, newScan)
+	if len(res.Added) != 0 || len(res.Removed) != 0 || len(res.Changed) != 1 {
+		t.Fatalf("expected the devices to be matched by MAC, got added %v, removed %v, changed %+v", res.Added, res.Removed, res.Changed)
+	}
+	if f := res.Changed[0].Fields; len(f) != 1 || f[0] != (FieldChange{Field: "ip", Old: "10.0.0.1", New: "10.0.0.3"}) {
+		t.Fatalf("expected 10.0.0.2 to be matched on IP first, got %+v", f)
+	}
+}
+
+func TestComparePorts(t *testing.T) {
+	o := device("10.0.0.1", "00:1a:2b:3c:4d:01", "nas")
+	o.RecordPortScan(time.Unix(100, 0), map[string][]int{"tcp": {22, 80}})
+	n := device("10.0.0.1", "00:1a:2b:3c:4d:01", "nas")
+	n.RecordPortScan(time.Unix(200, 0), map[string][]int{"tcp": {22, 443}})
+
+	res := Compare([]*discovery.Device{o}, []*discovery.Device{n})
+	if len(res.Changed) != 1 || res.Changed[0].Ports == nil {
+		t.Fatalf("expected port changes, got %+v", res.Changed)
+	}
+	ports := res.Changed[0].Ports
+	if len(ports.Opened["tcp"]) != 1 || ports.Opened["tcp"][0] != 443 || len(ports.Closed["tcp"]) != 1 || ports.Closed["tcp"][0] != 80 {
+		t.Fatalf("unexpected port changes %+v", ports)
+	}
+
+	unscanned := device("10.0.0.1", "00:1a:2b:3c:4d:01", "nas")
+	if res := Compare([]*discovery.Device{o}, []*discovery.Device{unscanned}); !res.Empty() {
+		t.Fatalf("expected ports to be ignored when one scan has no port data, got %+v", res.Changed)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]string{
+		"scan results": `{"devices":[{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01"},{"ip":"10.0.0.2"}],"stats":{"count":2,"duration":"1s"}}`,
+		"array":        `[{"ip":"10.0.0.1","mac":"00:1a:2b:3c:4d:01"},{"ip":"10.0.0.2"}]`,
+		"ndjson":       "{\"ip\":\"10.0.0.1\",\"mac\":\"00:1a:2b:3c:4d:01\"}\n{\"ip\":\"10.0.0.2\"}\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			devices, err := Load(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(devices) != 2 || devices[0].MAC() != "00:1a:2b:3c:4d:01" || devices[1].IP().String() != "10.0.0.2" {
+				t.Fatalf("unexpected devices %v", devices)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "not json", `{"devices":[{"ip":"bogus"}]}`} {
+		if _, err := Load(strings.NewReader(input)); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
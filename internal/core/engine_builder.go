@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"log/slog"
+	"os"
+	"path/filepath"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/paths"
@@ -15,19 +17,7 @@ import (
 )
 
 func BuildEngine(cfg *config.Config, logger discovery2.Logger) (*discovery2.Engine, error) {
-	ctx := context.Background()
-
-	stateDir, err := paths.StateDir()
-	if err != nil {
-		logger.Log(ctx, slog.LevelWarn, "failed to resolve state dir for OUI cache; continuing with embedded OUI", "error", err)
-		stateDir = ""
-	}
-
-	ouiDB, err := oui.New(ctx, oui.WithCacheDir(stateDir))
-	if err != nil {
-		logger.Log(ctx, slog.LevelWarn, "failed to initialize OUI DB; continuing without OUI", "error", err)
-		ouiDB = nil
-	}
+	ouiDB := BuildOUIRegistry(cfg, logger)
 
 	iface, err := discovery2.NewInterfaceInfo(cfg.NetworkInterface)
 	if err != nil {
@@ -79,3 +69,67 @@ func BuildEngine(cfg *config.Config, logger discovery2.Logger) (*discovery2.Engi
 
 	return discovery2.NewEngine(opts...)
 }
+
+// BuildOUIRegistry creates the OUI registry, cached in the state dir and with the user
+// overrides file from cfg applied. Returns nil when the registry cannot be initialized.
+func BuildOUIRegistry(cfg *config.Config, logger discovery2.Logger, extra ...oui.Option) *oui.Registry {
+	ctx := context.Background()
+
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		logger.Log(ctx, slog.LevelWarn, "failed to resolve state dir for OUI cache; continuing with embedded OUI", "error", err)
+		stateDir = ""
+	}
+
+	opts := []oui.Option{
+		oui.WithCacheDir(stateDir),
+		oui.WithMaxAge(cfg.OUI.RefreshInterval),
+		oui.WithAutoRefresh(cfg.OUI.AutoRefresh),
+	}
+	if cfg.OUI.MirrorURL != "" {
+		opts = append(opts, oui.WithMirrorURL(cfg.OUI.MirrorURL))
+	}
+	if overrides := ouiOverridesFile(cfg); overrides != "" {
+		opts = append(opts, oui.WithOverridesFile(overrides))
+	}
+
+	opts = append(opts, extra...)
+
+	ouiDB, err := oui.New(ctx, opts...)
+	if err != nil {
+		logger.Log(ctx, slog.LevelWarn, "failed to initialize OUI DB; continuing without OUI", "error", err)
+		return nil
+	}
+	return ouiDB
+}
+
+// ouiOverridesFile returns the configured OUI overrides file, or oui_overrides.yaml in the
+// config dir (oui_overrides.csv when only that one exists).
+func ouiOverridesFile(cfg *config.Config) string {
+	if cfg.OUI.OverridesFile != "" {
+		return cfg.OUI.OverridesFile
+	}
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return ""
+	}
+	yamlFile := filepath.Join(dir, "oui_overrides.yaml")
+	csvFile := filepath.Join(dir, "oui_overrides.csv")
+	if _, err := os.Stat(yamlFile); err != nil {
+		if _, err := os.Stat(csvFile); err == nil {
+			return csvFile
+		}
+	}
+	return yamlFile
+}
+
+// BuildPortScanner creates a PortScanner bound to the given interface, configured
+// with the worker pool, per-host concurrency and rate limit settings from cfg.
+func BuildPortScanner(cfg *config.Config, iface *discovery2.InterfaceInfo) *discovery2.PortScanner {
+	return discovery2.NewPortScanner(
+		cfg.PortScanner.Workers,
+		iface,
+		discovery2.WithHostConcurrency(cfg.PortScanner.HostConcurrency),
+		discovery2.WithRateLimit(cfg.PortScanner.RateLimit),
+	)
+}
@@ -0,0 +1,234 @@
+// Package events fans out engine and device tracker events to subscribers, like the
+// Server-Sent Events stream of the daemon.
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Type is the kind of an event as seen by subscribers.
+type Type string
+
+const (
+	TypeDeviceDiscovered Type = "device_discovered"
+	TypeDeviceChanged    Type = "device_changed"
+	TypeDeviceOffline    Type = "device_offline"
+	TypeDeviceOnline     Type = "device_online"
+	TypePortsChanged     Type = "ports_changed"
+	TypeScanStarted      Type = "scan_started"
+	TypeScanCompleted    Type = "scan_completed"
+	TypeError            Type = "error"
+)
+
+// Types lists all event types, see ParseTypes.
+var Types = []Type{
+	TypeDeviceDiscovered, TypeDeviceChanged, TypeDeviceOffline, TypeDeviceOnline,
+	TypePortsChanged, TypeScanStarted, TypeScanCompleted, TypeError,
+}
+
+// DefaultBufferSize is the number of events buffered per subscriber before it is
+// considered too slow and disconnected.
+const DefaultBufferSize = 64
+
+// Message is a single event delivered to subscribers.
+type Message struct {
+	// ID increases by one for every published message.
+	ID     uint64            `json:"id"`
+	Type   Type              `json:"type"`
+	Time   time.Time         `json:"time"`
+	Device *discovery.Device `json:"device,omitempty"`
+	// Changes lists the changed fields of device_changed and device_online events.
+	Changes []tracker.FieldChange  `json:"changes,omitempty"`
+	Ports   *discovery.PortChanges `json:"ports,omitempty"`
+	Stats   *discovery.ScanStats   `json:"stats,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// FromChange converts a device tracker change into a message.
+func FromChange(c tracker.Change) Message {
+	msg := Message{Time: c.Time, Device: c.Device, Changes: c.Fields}
+	switch c.Type {
+	case tracker.ChangeNew:
+		msg.Type = TypeDeviceDiscovered
+	case tracker.ChangeUpdated:
+		msg.Type = TypeDeviceChanged
+	case tracker.ChangeOffline:
+		msg.Type = TypeDeviceOffline
+	case tracker.ChangeOnline:
+		msg.Type = TypeDeviceOnline
+	}
+	return msg
+}
+
+// FromEngineEvent converts a scan lifecycle, ports changed or error event of the engine
+// into a message. Device discovered events are reported through the device tracker
+// instead (see FromChange), so the second return value is false for those and for
+// engine start and stop events.
+func FromEngineEvent(e discovery.Event, now time.Time) (Message, bool) {
+	msg := Message{Time: now}
+	switch e.Type {
+	case discovery.EventScanStarted:
+		msg.Type = TypeScanStarted
+	case discovery.EventScanCompleted:
+		msg.Type = TypeScanCompleted
+		msg.Stats = e.Stats
+	case discovery.EventPortsChanged:
+		msg.Type = TypePortsChanged
+		msg.Device = e.Device
+		msg.Ports = e.Changes
+	case discovery.EventError:
+		if e.Error == nil {
+			return msg, false
+		}
+		msg.Type = TypeError
+		msg.Error = e.Error.Error()
+	default:
+		return msg, false
+	}
+	return msg, true
+}
+
+// ParseTypes parses a comma separated list of event types. An empty list selects all types.
+func ParseTypes(list string) ([]Type, error) {
+	var types []Type
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		t := Type(name)
+		if !t.valid() {
+			names := make([]string, len(Types))
+			for i, t := range Types {
+				names[i] = string(t)
+			}
+			return nil, fmt.Errorf("invalid event type %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func (t Type) valid() bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the messages of a Broker on C. C is closed when the subscription
+// is cancelled, or when the subscriber fell behind by more than its buffer size.
+type Subscription struct {
+	C <-chan Message
+
+	ch     chan Message
+	types  map[Type]bool
+	broker *Broker
+	closed bool
+	slow   bool
+}
+
+// Slow reports whether the subscription was closed because the subscriber could not keep up.
+// Only valid after C was closed.
+func (s *Subscription) Slow() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.slow
+}
+
+// Cancel stops the subscription and closes C. It is safe to call multiple times.
+func (s *Subscription) Cancel() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+func (s *Subscription) wants(t Type) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Broker publishes messages to all subscribers without blocking: a subscriber whose buffer
+// is full is disconnected, so one slow client cannot hold up the others. It is safe for
+// concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	nextID uint64
+	slow   uint64
+}
+
+// NewBroker returns a broker without subscribers.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription for the given types (all types when empty), buffering up
+// to buffer messages (DefaultBufferSize when <= 0).
+func (b *Broker) Subscribe(buffer int, types ...Type) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBufferSize
+	}
+	ch := make(chan Message, buffer)
+	s := &Subscription{C: ch, ch: ch, types: make(map[Type]bool, len(types)), broker: b}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Publish assigns the next ID to msg and delivers it to all interested subscribers.
+func (b *Broker) Publish(msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	msg.ID = b.nextID
+	for s := range b.subs {
+		if !s.wants(msg.Type) {
+			continue
+		}
+		select {
+		case s.ch <- msg:
+		default:
+			s.slow = true
+			b.slow++
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// SlowDisconnects returns the number of subscribers disconnected because their buffer was full.
+func (b *Broker) SlowDisconnects() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.slow
+}
+
+// remove closes and unregisters s, b.mu must be held.
+func (b *Broker) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subs, s)
+	close(s.ch)
+}
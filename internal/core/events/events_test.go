@@ -0,0 +1,125 @@
+package events
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/tracker"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker()
+	all := b.Subscribe(4)
+	scans := b.Subscribe(4, TypeScanStarted, TypeScanCompleted)
+
+	b.Publish(Message{Type: TypeScanStarted})
+	b.Publish(Message{Type: TypeDeviceDiscovered})
+
+	if msg := <-all.C; msg.Type != TypeScanStarted || msg.ID != 1 {
+		t.Fatalf("unexpected first message %+v", msg)
+	}
+	if msg := <-all.C; msg.Type != TypeDeviceDiscovered || msg.ID != 2 {
+		t.Fatalf("unexpected second message %+v", msg)
+	}
+	if msg := <-scans.C; msg.Type != TypeScanStarted {
+		t.Fatalf("unexpected filtered message %+v", msg)
+	}
+	select {
+	case msg := <-scans.C:
+		t.Fatalf("expected device event to be filtered, got %+v", msg)
+	default:
+	}
+
+	all.Cancel()
+	all.Cancel()
+	if _, ok := <-all.C; ok {
+		t.Fatal("expected channel to be closed after cancel")
+	}
+	if n := b.Subscribers(); n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+}
+
+func TestBrokerDisconnectsSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe(1)
+	fast := b.Subscribe(4)
+
+	b.Publish(Message{Type: TypeScanStarted})
+	b.Publish(Message{Type: TypeScanCompleted})
+
+	if len(fast.C) != 2 {
+		t.Fatalf("expected fast subscriber to receive both messages, got %d", len(fast.C))
+	}
+	if msg := <-slow.C; msg.ID != 1 {
+		t.Fatalf("expected buffered message to be delivered, got %+v", msg)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatal("expected slow subscriber to be disconnected")
+	}
+	if !slow.Slow() || fast.Slow() {
+		t.Fatal("expected only the slow subscriber to be marked slow")
+	}
+	if n := b.SlowDisconnects(); n != 1 {
+		t.Fatalf("expected 1 slow disconnect, got %d", n)
+	}
+	if n := b.Subscribers(); n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+}
+
+func TestFromChange(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("10.0.0.1"))
+	tests := map[tracker.ChangeType]Type{
+		tracker.ChangeNew:     TypeDeviceDiscovered,
+		tracker.ChangeUpdated: TypeDeviceChanged,
+		tracker.ChangeOffline: TypeDeviceOffline,
+		tracker.ChangeOnline:  TypeDeviceOnline,
+	}
+	for changeType, want := range tests {
+		if msg := FromChange(tracker.Change{Type: changeType, Device: d}); msg.Type != want || msg.Device != d {
+			t.Fatalf("%s: expected %s, got %+v", changeType, want, msg)
+		}
+	}
+}
+
+func TestFromEngineEvent(t *testing.T) {
+	now := time.Unix(100, 0)
+
+	msg, ok := FromEngineEvent(discovery.NewErrorEvent(errors.New("boom")), now)
+	if !ok || msg.Type != TypeError || msg.Error != "boom" || !msg.Time.Equal(now) {
+		t.Fatalf("unexpected error message %+v", msg)
+	}
+
+	stats := &discovery.ScanStats{Count: 2}
+	if msg, ok := FromEngineEvent(discovery.NewScanCompletedEvent(stats), now); !ok || msg.Type != TypeScanCompleted || msg.Stats != stats {
+		t.Fatalf("unexpected scan completed message %+v", msg)
+	}
+
+	if _, ok := FromEngineEvent(discovery.NewDeviceEvent(discovery.NewDevice(net.ParseIP("10.0.0.1"))), now); ok {
+		t.Fatal("expected device events to be skipped")
+	}
+	if _, ok := FromEngineEvent(discovery.NewEngineStartedEvent(), now); ok {
+		t.Fatal("expected engine started events to be skipped")
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes(" device_offline, SCAN_COMPLETED ,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(types) != 2 || types[0] != TypeDeviceOffline || types[1] != TypeScanCompleted {
+		t.Fatalf("unexpected types %v", types)
+	}
+
+	if types, err := ParseTypes(""); err != nil || len(types) != 0 {
+		t.Fatalf("expected no types for empty list, got %v, %v", types, err)
+	}
+	if _, err := ParseTypes("device_exploded"); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+}
@@ -0,0 +1,246 @@
+// Package jobs runs long running background tasks, like port scans requested through the
+// daemon API, with a concurrency limit and keeps track of their status.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// DefaultRetain is the number of finished jobs kept for status requests.
+const DefaultRetain = 100
+
+// ErrNotFound is returned for unknown (or no longer retained) job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// Func is the work of a job. The context is cancelled when the manager is closed.
+type Func func(ctx context.Context) (any, error)
+
+// Job is a snapshot of a submitted job.
+type Job struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	// Params distinguishes jobs of the same type and target, e.g. the ports of a port scan.
+	Params   string     `json:"params,omitempty"`
+	Status   Status     `json:"status"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Result   any        `json:"result,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Done reports whether the job completed or failed.
+func (j Job) Done() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+type job struct {
+	Job
+	done chan struct{}
+}
+
+// Manager runs jobs with at most a fixed number at the same time. It is safe for concurrent use.
+type Manager struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	order  []string
+	nextID int
+	retain int
+
+	sem    chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	now    func() time.Time
+}
+
+// NewManager returns a manager running at most concurrency jobs at the same time
+// (1 when <= 0) and keeping the DefaultRetain most recent finished jobs.
+func NewManager(concurrency int) *Manager {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		jobs:   make(map[string]*job),
+		retain: DefaultRetain,
+		sem:    make(chan struct{}, concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+		now:    time.Now,
+	}
+}
+
+// Submit queues a job of the given type for target. When a job of the same type for the same
+// target with the same params is still queued or running, that job is returned instead, so
+// repeated requests do not pile up.
+func (m *Manager) Submit(typ, target, params string, fn Func) Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.order {
+		if j := m.jobs[id]; j.Type == typ && j.Target == target && j.Params == params && !j.Done() {
+			return j.Job
+		}
+	}
+
+	m.nextID++
+	j := &job{
+		Job: Job{
+			ID:      fmt.Sprintf("%d", m.nextID),
+			Type:    typ,
+			Target:  target,
+			Params:  params,
+			Status:  StatusQueued,
+			Created: m.now(),
+		},
+		done: make(chan struct{}),
+	}
+	m.jobs[j.ID] = j
+	m.order = append(m.order, j.ID)
+	m.prune()
+
+	m.wg.Add(1)
+	go m.run(j, fn)
+	return j.Job
+}
+
+func (m *Manager) run(j *job, fn Func) {
+	defer m.wg.Done()
+	defer close(j.done)
+
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-m.ctx.Done():
+		m.finish(j, nil, m.ctx.Err())
+		return
+	}
+
+	m.mu.Lock()
+	started := m.now()
+	j.Status = StatusRunning
+	j.Started = &started
+	m.mu.Unlock()
+
+	result, err := fn(m.ctx)
+	m.finish(j, result, err)
+}
+
+func (m *Manager) finish(j *job, result any, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := m.now()
+	j.Finished = &finished
+	if err != nil {
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		return
+	}
+	j.Status = StatusCompleted
+	j.Result = result
+}
+
+// Get returns the job with the given ID.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.Job, nil
+}
+
+// List returns all retained jobs, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		out = append(out, m.jobs[m.order[i]].Job)
+	}
+	return out
+}
+
+// Latest returns the most recent job of the given type for target.
+func (m *Manager) Latest(typ, target string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.order) - 1; i >= 0; i-- {
+		if j := m.jobs[m.order[i]]; j.Type == typ && j.Target == target {
+			return j.Job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Wait blocks until the job is done or ctx is cancelled, and returns its final state.
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return m.Get(id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return j.Job, nil
+}
+
+// Close cancels all queued and running jobs and waits for them to return.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// prune drops the oldest finished jobs beyond the retain limit, m.mu must be held.
+func (m *Manager) prune() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].Done() {
+			finished++
+		}
+	}
+	if finished <= m.retain {
+		return
+	}
+
+	drop := finished - m.retain
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if drop > 0 && m.jobs[id].Done() {
+			delete(m.jobs, id)
+			drop--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManagerRunsJobs(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	releaseJob := func() { releaseOnce.Do(func() { close(release) }) }
+	defer releaseJob()
+
+	waitFor := func(id string, status Status) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if j, _ := m.Get(id); j.Status == status {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		j, _ := m.Get(id)
+		t.Fatalf("job %s: expected status %s, got %s", id, status, j.Status)
+	}
+
+	first := m.Submit("portscan", "10.0.0.1", "", func(context.Context) (any, error) {
+		<-release
+		return []int{22}, nil
+	})
+	waitFor(first.ID, StatusRunning)
+
+	second := m.Submit("portscan", "10.0.0.2", "", func(context.Context) (any, error) {
+		return nil, errors.New("unreachable")
+	})
+	if first.ID == second.ID {
+		t.Fatal("expected distinct job IDs")
+	}
+	if dup := m.Submit("portscan", "10.0.0.1", "", nil); dup.ID != first.ID {
+		t.Fatalf("expected pending job %s to be returned for the same target, got %s", first.ID, dup.ID)
+	}
+	if j, _ := m.Get(second.ID); j.Status != StatusQueued {
+		t.Fatalf("expected second job to wait for the concurrency limit, got %s", j.Status)
+	}
+
+	releaseJob()
+	done, err := m.Wait(context.Background(), first.ID)
+	if err != nil || done.Status != StatusCompleted || done.Started == nil || done.Finished == nil {
+		t.Fatalf("unexpected completed job %+v, %v", done, err)
+	}
+	failed, err := m.Wait(context.Background(), second.ID)
+	if err != nil || failed.Status != StatusFailed || failed.Error != "unreachable" {
+		t.Fatalf("unexpected failed job %+v, %v", failed, err)
+	}
+
+	if list := m.List(); len(list) != 2 || list[0].ID != second.ID {
+		t.Fatalf("expected newest job first, got %+v", list)
+	}
+	if latest, ok := m.Latest("portscan", "10.0.0.1"); !ok || latest.ID != first.ID {
+		t.Fatalf("unexpected latest job %+v", latest)
+	}
+	if _, err := m.Get("nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestManagerDeduplicatesByParams(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	block := func(context.Context) (any, error) {
+		<-release
+		return nil, nil
+	}
+
+	first := m.Submit("portscan", "10.0.0.1", "", block)
+	if dup := m.Submit("portscan", "10.0.0.1", "", block); dup.ID != first.ID {
+		t.Fatalf("expected pending job %s for the same params, got %s", first.ID, dup.ID)
+	}
+	other := m.Submit("portscan", "10.0.0.1", "22", block)
+	if other.ID == first.ID || other.Params != "22" {
+		t.Fatalf("expected a new job for different params, got %+v", other)
+	}
+}
+
+func TestManagerPrunesFinishedJobs(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+	m.retain = 2
+
+	var ids []string
+	for _, target := range []string{"a", "b", "c", "d"} {
+		j := m.Submit("portscan", target, "", func(context.Context) (any, error) { return nil, nil })
+		if _, err := m.Wait(context.Background(), j.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.ID)
+	}
+
+	if _, err := m.Get(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected oldest job to be pruned, got %v", err)
+	}
+	if len(m.List()) > 3 {
+		t.Fatalf("expected at most 3 jobs, got %d", len(m.List()))
+	}
+}
+
+func TestManagerCloseCancelsJobs(t *testing.T) {
+	m := NewManager(1)
+	j := m.Submit("portscan", "10.0.0.1", "", func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	m.Close()
+
+	if got, _ := m.Get(j.ID); got.Status != StatusFailed {
+		t.Fatalf("expected cancelled job to fail, got %s", got.Status)
+	}
+}
@@ -0,0 +1,117 @@
+// Package known remembers the identity keys (see discovery.Device.IdentityKey) of the devices
+// seen by the daemon, so devices that were already on the network before a restart can be
+// told apart from new ones.
+package known
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/internal/core/paths"
+)
+
+// FileName is the name of the known devices file in the state directory.
+const FileName = "known_devices.yaml"
+
+// Store holds the known identity keys and persists them to a YAML file. It is safe for
+// concurrent use.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	keys  map[string]struct{}
+	dirty bool
+}
+
+// DefaultPath returns the known devices file in the state directory.
+func DefaultPath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Load reads the known devices from path. A missing file results in an empty store, which
+// creates the file on the first Save. An empty path keeps the keys in memory only.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, keys: make(map[string]struct{})}
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := yaml.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse known devices %s: %w", path, err)
+	}
+	for _, key := range keys {
+		if key != "" {
+			s.keys[key] = struct{}{}
+		}
+	}
+	return s, nil
+}
+
+// Add records key and reports whether it was not known yet. Call Save to persist it.
+func (s *Store) Add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key]; ok || key == "" {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	s.dirty = true
+	return true
+}
+
+// Len returns the number of known devices.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+// Save writes the known devices to the file when keys were added since the last Save.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data, err := yaml.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,60 @@
+package known
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", FileName)
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !s.Add("00:1b:63:00:00:02") || !s.Add("10.0.0.3") {
+		t.Fatal("expected new keys to be added")
+	}
+	if s.Add("10.0.0.3") || s.Add("") {
+		t.Fatal("expected known and empty keys not to be added")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be written on Save only, got %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reloaded.Len() != 2 || reloaded.Add("00:1b:63:00:00:02") {
+		t.Fatalf("expected both keys after reload, got %d", reloaded.Len())
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("not: [a list"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for invalid file")
+	}
+}
+
+func TestMemoryOnly(t *testing.T) {
+	s, err := Load("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Add("10.0.0.1")
+	if err := s.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("expected 1 key, got %d", s.Len())
+	}
+}
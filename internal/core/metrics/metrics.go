@@ -0,0 +1,160 @@
+// Package metrics writes metrics in the Prometheus text exposition format, without
+// depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Type is the type of a metric family.
+type Type string
+
+const (
+	Counter   Type = "counter"
+	Gauge     Type = "gauge"
+	Histogram Type = "histogram"
+)
+
+// Label is a metric label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric family.
+type Sample struct {
+	// Suffix is appended to the family name, e.g. "_bucket" for histograms.
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with its help text, type and samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// NewFamily returns a family with a single unlabeled sample.
+func NewFamily(name, help string, typ Type, value float64) Family {
+	return Family{Name: name, Help: help, Type: typ, Samples: []Sample{{Value: value}}}
+}
+
+// LabeledFamily returns a family with a sample per value of the label, ordered by label value.
+func LabeledFamily(name, help string, typ Type, label string, values map[string]float64) Family {
+	f := Family{Name: name, Help: help, Type: typ}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f.Samples = append(f.Samples, Sample{Labels: []Label{{Name: label, Value: k}}, Value: values[k]})
+	}
+	return f
+}
+
+// Write writes the families in the text exposition format.
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		_, _ = fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		_, _ = fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			_, _ = bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				parts := make([]string, len(s.Labels))
+				for i, l := range s.Labels {
+					parts[i] = l.Name + `="` + escapeLabelValue(l.Value) + `"`
+				}
+				_, _ = bw.WriteString("{" + strings.Join(parts, ",") + "}")
+			}
+			_, _ = bw.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+// DefaultDurationBuckets are histogram buckets in seconds suited for scan durations.
+var DefaultDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 15, 20, 30, 60}
+
+// HistogramValue accumulates observations into cumulative buckets. It is safe for concurrent use.
+type HistogramValue struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram returns a histogram with the given upper bounds, which must be sorted.
+func NewHistogram(buckets []float64) *HistogramValue {
+	return &HistogramValue{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe adds a single observation.
+func (h *HistogramValue) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Family returns the histogram as a metric family with cumulative _bucket, _sum and _count samples.
+func (h *HistogramValue) Family(name, help string) Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f := Family{Name: name, Help: help, Type: Histogram}
+	for i, upper := range h.buckets {
+		f.Samples = append(f.Samples, Sample{
+			Suffix: "_bucket",
+			Labels: []Label{{Name: "le", Value: formatValue(upper)}},
+			Value:  float64(h.counts[i]),
+		})
+	}
+	f.Samples = append(f.Samples,
+		Sample{Suffix: "_bucket", Labels: []Label{{Name: "le", Value: "+Inf"}}, Value: float64(h.count)},
+		Sample{Suffix: "_sum", Value: h.sum},
+		Sample{Suffix: "_count", Value: float64(h.count)},
+	)
+	return f
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	h := NewHistogram([]float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(7)
+
+	families := []Family{
+		NewFamily("whosthere_devices", "Number of devices.", Gauge, 3),
+		LabeledFamily("whosthere_devices_by_vendor", "Devices per vendor.", Gauge, "vendor", map[string]float64{
+			`Acme "Labs"`: 1,
+			"Apple, Inc.": 2,
+		}),
+		h.Family("whosthere_scan_duration_seconds", "Scan duration\nin seconds."),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, families); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `# HELP whosthere_devices Number of devices.
+# TYPE whosthere_devices gauge
+whosthere_devices 3
+# HELP whosthere_devices_by_vendor Devices per vendor.
+# TYPE whosthere_devices_by_vendor gauge
+whosthere_devices_by_vendor{vendor="Acme \"Labs\""} 1
+whosthere_devices_by_vendor{vendor="Apple, Inc."} 2
+# HELP whosthere_scan_duration_seconds Scan duration\nin seconds.
+# TYPE whosthere_scan_duration_seconds histogram
+whosthere_scan_duration_seconds_bucket{le="1"} 1
+whosthere_scan_duration_seconds_bucket{le="5"} 2
+whosthere_scan_duration_seconds_bucket{le="+Inf"} 3
+whosthere_scan_duration_seconds_sum 10.5
+whosthere_scan_duration_seconds_count 3
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
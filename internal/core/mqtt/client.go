@@ -0,0 +1,242 @@
+// Package mqtt publishes device state to an MQTT broker, including Home Assistant MQTT
+// discovery messages. It implements the small part of MQTT 3.1.1 needed to publish with
+// QoS 0: connecting with a last will, publishing (retained) messages and keep-alive pings.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retain asks the broker to keep the message for future subscribers.
+	Retain bool
+}
+
+// connack return codes
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// writeTimeout bounds a single write, so a stalled broker is detected as a lost connection.
+const writeTimeout = 10 * time.Second
+
+// Options configures the connection to the broker.
+type Options struct {
+	// Broker is the broker URL: tcp://host:1883, or ssl://host:8883 (also mqtts:// and tls://)
+	// for TLS. The port defaults to 1883, or 8883 for TLS.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is the interval of the pings that keep the connection open.
+	KeepAlive time.Duration
+	// Will is published by the broker when the connection is lost.
+	Will *Message
+	// TLSConfig is used for TLS brokers, the system roots are used when nil.
+	TLSConfig *tls.Config
+}
+
+// Client is a connection to an MQTT broker that can publish messages. It is safe for
+// concurrent use.
+type Client struct {
+	conn net.Conn
+
+	writeMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Dial connects to the broker and waits for it to accept the connection.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	addr, useTLS, err := parseBroker(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if useTLS {
+		tlsConfig := opts.TLSConfig
+		if tlsConfig == nil {
+			host, _, _ := net.SplitHostPort(addr)
+			tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	br := bufio.NewReader(conn)
+	if err := handshake(conn, br, opts); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := &Client{conn: conn, done: make(chan struct{})}
+	go c.readLoop(br)
+	if opts.KeepAlive > 0 {
+		go c.pingLoop(opts.KeepAlive)
+	}
+	return c, nil
+}
+
+func parseBroker(broker string) (addr string, useTLS bool, err error) {
+	if !strings.Contains(broker, "://") {
+		// host:port without a scheme
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid broker %q: %w", broker, err)
+	}
+
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		port = "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("invalid broker %q", broker)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+func handshake(conn net.Conn, br *bufio.Reader, opts Options) error {
+	connect := connectPacket{
+		clientID:  opts.ClientID,
+		username:  opts.Username,
+		password:  opts.Password,
+		keepAlive: uint16(opts.KeepAlive / time.Second),
+		will:      opts.Will,
+	}
+	if _, err := conn.Write(connect.encode()); err != nil {
+		return err
+	}
+
+	p, err := readPacket(br)
+	if err != nil {
+		return fmt.Errorf("read CONNACK: %w", err)
+	}
+	if p.typ != packetConnack || len(p.body) != 2 {
+		return errors.New("expected CONNACK")
+	}
+	if code := p.body[1]; code != 0 {
+		if msg, ok := connackErrors[code]; ok {
+			return fmt.Errorf("connection refused: %s", msg)
+		}
+		return fmt.Errorf("connection refused: code %d", code)
+	}
+	return nil
+}
+
+// Publish sends m with QoS 0, there is no acknowledgement from the broker.
+func (c *Client) Publish(m Message) error {
+	if len(m.Topic) > 0xffff || 2+len(m.Topic)+len(m.Payload) > maxRemainingLength {
+		return errors.New("message too large")
+	}
+	return c.write(encodePublish(m))
+}
+
+// Done is closed when the connection is lost or closed, Err returns the reason.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, nil while connected or after Close.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close disconnects from the broker, which discards the last will.
+func (c *Client) Close() error {
+	err := c.write(encodePacket(packetDisconnect, 0, nil))
+	c.shutdown(nil)
+	return err
+}
+
+func (c *Client) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	select {
+	case <-c.done:
+		return errors.New("connection closed")
+	default:
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(data); err != nil {
+		c.shutdown(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		_ = c.conn.Close()
+		close(c.done)
+	})
+}
+
+// readLoop reads until the connection ends, the broker only sends ping responses to a
+// publishing client.
+func (c *Client) readLoop(br *bufio.Reader) {
+	for {
+		if _, err := readPacket(br); err != nil {
+			c.shutdown(fmt.Errorf("connection lost: %w", err))
+			return
+		}
+	}
+}
+
+func (c *Client) pingLoop(keepAlive time.Duration) {
+	ticker := time.NewTicker(keepAlive * 3 / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(encodePacket(packetPingreq, 0, nil)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBroker is an in-process stand-in for an MQTT broker. It accepts CONNECT, PUBLISH,
+// PINGREQ and DISCONNECT packets, keeps retained messages and publishes the will of clients
+// that disconnect uncleanly.
+type testBroker struct {
+	t        *testing.T
+	ln       net.Listener
+	username string
+	password string
+
+	mu        sync.Mutex
+	conns     []net.Conn
+	connects  []*connectPacket
+	published []Message
+	retained  map[string]Message
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := &testBroker{t: t, ln: ln, retained: make(map[string]Message)}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		b.drop()
+	})
+	go b.serve()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *testBroker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns = append(b.conns, conn)
+		b.mu.Unlock()
+		go b.handle(conn)
+	}
+}
+
+func (b *testBroker) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	br := bufio.NewReader(conn)
+
+	p, err := readPacket(br)
+	if err != nil || p.typ != packetConnect {
+		return
+	}
+	connect, err := decodeConnect(p.body)
+	if err != nil {
+		return
+	}
+	if b.username != "" && (connect.username != b.username || connect.password != b.password) {
+		_, _ = conn.Write(encodePacket(packetConnack, 0, []byte{0, 4}))
+		return
+	}
+	b.mu.Lock()
+	b.connects = append(b.connects, connect)
+	b.mu.Unlock()
+	if _, err := conn.Write(encodePacket(packetConnack, 0, []byte{0, 0})); err != nil {
+		return
+	}
+
+	for {
+		p, err := readPacket(br)
+		if err != nil {
+			if connect.will != nil {
+				b.publish(*connect.will)
+			}
+			return
+		}
+		switch p.typ {
+		case packetPublish:
+			m, err := decodePublish(p)
+			if err != nil {
+				b.t.Errorf("decode PUBLISH: %v", err)
+				return
+			}
+			b.publish(m)
+		case packetPingreq:
+			_, _ = conn.Write(encodePacket(packetPingresp, 0, nil))
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func (b *testBroker) publish(m Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m.Payload = append([]byte(nil), m.Payload...)
+	b.published = append(b.published, m)
+	if m.Retain {
+		b.retained[m.Topic] = m
+	}
+}
+
+// drop closes all client connections as if the network failed.
+func (b *testBroker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *testBroker) connectCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.connects)
+}
+
+// retainedPayload returns the retained payload of topic and whether there is one.
+func (b *testBroker) retainedPayload(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.retained[topic]
+	return string(m.Payload), ok
+}
+
+func (b *testBroker) waitRetained(t *testing.T, topic, payload string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		got, _ := b.retainedPayload(topic)
+		return got == payload
+	}, 5*time.Second, 5*time.Millisecond, "retained %s", topic)
+}
+
+func dialTestBroker(t *testing.T, b *testBroker, opts Options) *Client {
+	t.Helper()
+	opts.Broker = b.url()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, opts)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient_PublishAndClose(t *testing.T) {
+	b := newTestBroker(t)
+	b.username, b.password = "user", "pass"
+
+	c := dialTestBroker(t, b, Options{
+		ClientID:  "test",
+		Username:  "user",
+		Password:  "pass",
+		KeepAlive: 30 * time.Second,
+		Will:      &Message{Topic: "whosthere/status", Payload: []byte("offline"), Retain: true},
+	})
+	require.NoError(t, c.Publish(Message{Topic: "a/b", Payload: []byte("retained"), Retain: true}))
+	require.NoError(t, c.Publish(Message{Topic: "a/c", Payload: []byte("not retained")}))
+	require.NoError(t, c.Close())
+
+	b.waitRetained(t, "a/b", "retained")
+	<-c.Done()
+	assert.NoError(t, c.Err())
+	assert.Error(t, c.Publish(Message{Topic: "a/b"}), "publishing after Close")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	require.Len(t, b.connects, 1)
+	assert.Equal(t, "test", b.connects[0].clientID)
+	assert.Equal(t, uint16(30), b.connects[0].keepAlive)
+	assert.Len(t, b.published, 2)
+	assert.NotContains(t, b.retained, "a/c")
+	assert.NotContains(t, b.retained, "whosthere/status", "a clean disconnect discards the will")
+}
+
+func TestClient_WillOnConnectionLoss(t *testing.T) {
+	b := newTestBroker(t)
+	c := dialTestBroker(t, b, Options{
+		ClientID: "test",
+		Will:     &Message{Topic: "whosthere/status", Payload: []byte("offline"), Retain: true},
+	})
+
+	b.drop()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection loss was not detected")
+	}
+	assert.Error(t, c.Err())
+	b.waitRetained(t, "whosthere/status", "offline")
+}
+
+func TestClient_Rejected(t *testing.T) {
+	b := newTestBroker(t)
+	b.username, b.password = "user", "pass"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := Dial(ctx, Options{Broker: b.url(), ClientID: "test", Username: "user", Password: "wrong"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad user name or password")
+}
+
+func TestParseBroker(t *testing.T) {
+	tests := []struct {
+		broker  string
+		addr    string
+		useTLS  bool
+		wantErr bool
+	}{
+		{broker: "tcp://broker.lan", addr: "broker.lan:1883"},
+		{broker: "mqtt://broker.lan:1884", addr: "broker.lan:1884"},
+		{broker: "ssl://broker.lan", addr: "broker.lan:8883", useTLS: true},
+		{broker: "mqtts://broker.lan:9883", addr: "broker.lan:9883", useTLS: true},
+		{broker: "192.168.1.10:1883", addr: "192.168.1.10:1883"},
+		{broker: "http://broker.lan", wantErr: true},
+		{broker: "tcp://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.broker, func(t *testing.T) {
+			addr, useTLS, err := parseBroker(tt.broker)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.addr, addr)
+			assert.Equal(t, tt.useTLS, useTLS)
+		})
+	}
+}
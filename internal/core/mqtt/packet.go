@@ -0,0 +1,221 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, only the ones needed to publish are supported.
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// connect flags
+const (
+	flagCleanSession byte = 0x02
+	flagWill         byte = 0x04
+	flagWillRetain   byte = 0x20
+	flagPassword     byte = 0x40
+	flagUsername     byte = 0x80
+)
+
+// maxRemainingLength is the largest packet size the protocol can express.
+const maxRemainingLength = 268_435_455
+
+// packet is a decoded control packet: its type, the flags of the fixed header and the
+// variable header and payload.
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// connectPacket holds the fields of a CONNECT packet.
+type connectPacket struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive uint16
+	will      *Message
+}
+
+func (p *connectPacket) encode() []byte {
+	var flags byte = flagCleanSession
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+
+	payload := appendString(nil, p.clientID)
+	if p.will != nil {
+		flags |= flagWill
+		if p.will.Retain {
+			flags |= flagWillRetain
+		}
+		payload = appendString(payload, p.will.Topic)
+		payload = appendBytes(payload, p.will.Payload)
+	}
+	if p.username != "" {
+		flags |= flagUsername
+		payload = appendString(payload, p.username)
+	}
+	if p.password != "" {
+		flags |= flagPassword
+		payload = appendString(payload, p.password)
+	}
+
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, p.keepAlive)
+	return encodePacket(packetConnect, 0, append(body, payload...))
+}
+
+func decodeConnect(body []byte) (*connectPacket, error) {
+	r := &reader{buf: body}
+	if name := r.string(); name != "MQTT" {
+		return nil, fmt.Errorf("unsupported protocol %q", name)
+	}
+	if level := r.byte(); level != 4 {
+		return nil, fmt.Errorf("unsupported protocol level %d", level)
+	}
+	flags := r.byte()
+	p := &connectPacket{keepAlive: r.uint16(), clientID: r.string()}
+	if flags&flagWill != 0 {
+		p.will = &Message{Topic: r.string(), Payload: r.bytes(), Retain: flags&flagWillRetain != 0}
+	}
+	if flags&flagUsername != 0 {
+		p.username = r.string()
+	}
+	if flags&flagPassword != 0 {
+		p.password = r.string()
+	}
+	return p, r.err
+}
+
+func encodePublish(m Message) []byte {
+	var flags byte
+	if m.Retain {
+		flags = 0x01
+	}
+	body := appendString(nil, m.Topic)
+	return encodePacket(packetPublish, flags, append(body, m.Payload...))
+}
+
+// decodePublish decodes a QoS 0 PUBLISH packet.
+func decodePublish(p packet) (Message, error) {
+	if qos := (p.flags >> 1) & 0x03; qos != 0 {
+		return Message{}, fmt.Errorf("unsupported QoS %d", qos)
+	}
+	r := &reader{buf: p.body}
+	m := Message{Topic: r.string(), Retain: p.flags&0x01 != 0}
+	m.Payload = r.buf
+	return m, r.err
+}
+
+func encodePacket(typ, flags byte, body []byte) []byte {
+	out := []byte{typ<<4 | flags}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{typ: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// reader decodes the fields of a packet body, remembering the first error.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if len(r.buf) < 2 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.buf)
+	r.buf = r.buf[2:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := int(r.uint16())
+	if len(r.buf) < n {
+		r.fail()
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errors.New("malformed packet")
+	}
+	r.buf = nil
+}
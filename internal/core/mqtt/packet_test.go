@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectPacket_RoundTrip(t *testing.T) {
+	want := &connectPacket{
+		clientID:  "whosthere",
+		username:  "user",
+		password:  "pass",
+		keepAlive: 60,
+		will:      &Message{Topic: "whosthere/status", Payload: []byte("offline"), Retain: true},
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(want.encode())))
+	require.NoError(t, err)
+	assert.Equal(t, packetConnect, p.typ)
+
+	got, err := decodeConnect(p.body)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestPublishPacket_RoundTrip(t *testing.T) {
+	// a payload above 127 bytes needs a multi-byte remaining length
+	want := Message{Topic: "whosthere/devices/x/attributes", Payload: bytes.Repeat([]byte("a"), 20_000), Retain: true}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encodePublish(want))))
+	require.NoError(t, err)
+	assert.Equal(t, packetPublish, p.typ)
+
+	got, err := decodePublish(p)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestReadPacket_Malformed(t *testing.T) {
+	_, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01})))
+	assert.Error(t, err, "remaining length longer than 4 bytes")
+
+	_, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0x05, 0x00})))
+	assert.Error(t, err, "truncated body")
+
+	_, err = decodeConnect([]byte{0x00, 0x04, 'M', 'Q'})
+	assert.Error(t, err)
+}
@@ -0,0 +1,358 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+const (
+	// PayloadOnline and PayloadOffline are published to the availability and device state topics.
+	PayloadOnline  = "online"
+	PayloadOffline = "offline"
+
+	// DefaultKeepAlive is the keep-alive interval of the broker connection.
+	DefaultKeepAlive = 60 * time.Second
+	// DefaultReconnectBackoff is the wait before the first reconnect, it doubles up to a minute.
+	DefaultReconnectBackoff = time.Second
+
+	maxReconnectBackoff = time.Minute
+	// queueSize is the number of pending events, events beyond it are dropped. The device
+	// state is published again after reconnecting, so nothing is lost for long.
+	queueSize = 256
+)
+
+// DeviceState is a known device and whether it is online.
+type DeviceState struct {
+	Device *discovery.Device
+	Online bool
+}
+
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithLogger sets the logger of connection problems.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Publisher) { p.logger = l }
+}
+
+// WithKeepAlive sets the keep-alive interval of the broker connection.
+func WithKeepAlive(d time.Duration) Option {
+	return func(p *Publisher) { p.keepAlive = d }
+}
+
+// WithReconnectBackoff sets the wait before the first reconnect.
+func WithReconnectBackoff(d time.Duration) Option {
+	return func(p *Publisher) { p.backoff = d }
+}
+
+// Publisher publishes device state to an MQTT broker. Every device gets its own topics
+// below <topic_prefix>/devices/<id>:
+//
+//	state       online or offline
+//	ip          IP address
+//	name        display name
+//	vendor      manufacturer
+//	attributes  JSON with all of the above, the sources and the open ports
+//
+// All messages are retained. <topic_prefix>/status tells whether whosthere itself is
+// connected, the broker publishes offline when the connection is lost. With Home Assistant
+// enabled, a discovery message makes every device a device_tracker entity.
+type Publisher struct {
+	cfg       config.DaemonMQTTConfig
+	snapshot  func() []DeviceState
+	queue     chan events.Message
+	logger    *slog.Logger
+	keepAlive time.Duration
+	backoff   time.Duration
+}
+
+// NewPublisher returns a publisher for the config. snapshot returns the known devices, which
+// are published after every (re)connect.
+func NewPublisher(cfg config.DaemonMQTTConfig, snapshot func() []DeviceState, opts ...Option) *Publisher {
+	p := &Publisher{
+		cfg:       cfg,
+		snapshot:  snapshot,
+		queue:     make(chan events.Message, queueSize),
+		logger:    slog.New(slog.DiscardHandler),
+		keepAlive: DefaultKeepAlive,
+		backoff:   DefaultReconnectBackoff,
+	}
+	p.cfg.TopicPrefix = strings.Trim(p.cfg.TopicPrefix, "/")
+	p.cfg.DiscoveryPrefix = strings.Trim(p.cfg.DiscoveryPrefix, "/")
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Types returns the event types the publisher is interested in.
+func (p *Publisher) Types() []events.Type {
+	return []events.Type{
+		events.TypeDeviceDiscovered, events.TypeDeviceChanged, events.TypeDeviceOnline,
+		events.TypeDeviceOffline, events.TypePortsChanged,
+	}
+}
+
+// Notify queues msg for publishing without blocking.
+func (p *Publisher) Notify(msg events.Message) {
+	if msg.Device == nil {
+		return
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.logger.Log(context.Background(), slog.LevelWarn, "MQTT publisher queue full, dropping event", "type", msg.Type)
+	}
+}
+
+// Run connects to the broker and publishes until ctx is done, reconnecting with backoff
+// when the connection fails. The queued events are published before disconnecting.
+func (p *Publisher) Run(ctx context.Context) {
+	backoff := p.backoff
+	for {
+		client, err := p.connect(ctx)
+		if err == nil {
+			backoff = p.backoff
+			p.logger.Log(ctx, slog.LevelInfo, "connected to MQTT broker", "broker", p.cfg.Broker)
+			err = p.serve(ctx, client)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		p.logger.Log(ctx, slog.LevelWarn, "MQTT connection failed, reconnecting", "broker", p.cfg.Broker, "error", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+func (p *Publisher) connect(ctx context.Context) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return Dial(ctx, Options{
+		Broker:    p.cfg.Broker,
+		ClientID:  p.cfg.ClientID,
+		Username:  p.cfg.Username,
+		Password:  p.cfg.Password,
+		KeepAlive: p.keepAlive,
+		Will:      &Message{Topic: p.statusTopic(), Payload: []byte(PayloadOffline), Retain: true},
+	})
+}
+
+// serve publishes the known devices and then the queued events until the connection is
+// lost, or ctx is done which disconnects cleanly. Events queued while disconnected are
+// dropped, the known devices are newer and published events would overwrite them.
+func (p *Publisher) serve(ctx context.Context, client *Client) error {
+	defer func() { _ = client.Close() }()
+
+	if err := client.Publish(Message{Topic: p.statusTopic(), Payload: []byte(PayloadOnline), Retain: true}); err != nil {
+		return err
+	}
+	p.drainQueue()
+	for _, s := range p.snapshot() {
+		if err := p.publishDevice(client, s.Device, s.Online); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// publish what happened before stopping, writes time out when the broker is gone
+			for len(p.queue) > 0 {
+				if err := p.publishEvent(client, <-p.queue); err != nil {
+					return err
+				}
+			}
+			// the will is discarded on a clean disconnect, so publish offline ourselves
+			_ = client.Publish(Message{Topic: p.statusTopic(), Payload: []byte(PayloadOffline), Retain: true})
+			return nil
+		case <-client.Done():
+			return client.Err()
+		case msg := <-p.queue:
+			if err := p.publishEvent(client, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *Publisher) publishEvent(client *Client, msg events.Message) error {
+	switch msg.Type {
+	case events.TypeDeviceOffline:
+		return p.publishDevice(client, msg.Device, false)
+	case events.TypePortsChanged:
+		return p.publishAttributes(client, msg.Device)
+	default:
+		return p.publishDevice(client, msg.Device, true)
+	}
+}
+
+// drainQueue drops the queued events.
+func (p *Publisher) drainQueue() {
+	for {
+		select {
+		case <-p.queue:
+		default:
+			return
+		}
+	}
+}
+
+func (p *Publisher) publishDevice(client *Client, d *discovery.Device, online bool) error {
+	id := DeviceID(d)
+	if id == "" {
+		return nil
+	}
+	if p.cfg.HomeAssistant {
+		payload, err := json.Marshal(p.discoveryConfig(d, id))
+		if err != nil {
+			return err
+		}
+		topic := p.cfg.DiscoveryPrefix + "/device_tracker/whosthere_" + id + "/config"
+		if err := client.Publish(Message{Topic: topic, Payload: payload, Retain: true}); err != nil {
+			return err
+		}
+	}
+
+	state := PayloadOffline
+	if online {
+		state = PayloadOnline
+	}
+	ip := ""
+	if d.IP() != nil {
+		ip = d.IP().String()
+	}
+	for _, m := range []Message{
+		{Topic: p.deviceTopic(id, "state"), Payload: []byte(state)},
+		{Topic: p.deviceTopic(id, "ip"), Payload: []byte(ip)},
+		{Topic: p.deviceTopic(id, "name"), Payload: []byte(d.DisplayName())},
+		{Topic: p.deviceTopic(id, "vendor"), Payload: []byte(d.Manufacturer())},
+	} {
+		m.Retain = true
+		if err := client.Publish(m); err != nil {
+			return err
+		}
+	}
+	return p.publishAttributes(client, d)
+}
+
+// attributes is the payload of the attributes topic, ip, mac and host_name are the names
+// Home Assistant uses for device trackers.
+type attributes struct {
+	IP        string           `json:"ip"`
+	MAC       string           `json:"mac,omitempty"`
+	HostName  string           `json:"host_name,omitempty"`
+	Vendor    string           `json:"vendor,omitempty"`
+	Sources   []string         `json:"sources,omitempty"`
+	OpenPorts map[string][]int `json:"open_ports,omitempty"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+}
+
+func (p *Publisher) publishAttributes(client *Client, d *discovery.Device) error {
+	id := DeviceID(d)
+	if id == "" {
+		return nil
+	}
+	attrs := attributes{
+		MAC:       d.MAC(),
+		HostName:  d.DisplayName(),
+		Vendor:    d.Manufacturer(),
+		OpenPorts: d.OpenPorts(),
+		FirstSeen: d.FirstSeen(),
+		LastSeen:  d.LastSeen(),
+	}
+	if d.IP() != nil {
+		attrs.IP = d.IP().String()
+	}
+	for source := range d.Sources() {
+		attrs.Sources = append(attrs.Sources, source)
+	}
+	sort.Strings(attrs.Sources)
+
+	payload, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	return client.Publish(Message{Topic: p.deviceTopic(id, "attributes"), Payload: payload, Retain: true})
+}
+
+// haDevice is the device section of a Home Assistant discovery message.
+type haDevice struct {
+	Identifiers  []string    `json:"identifiers"`
+	Name         string      `json:"name"`
+	Manufacturer string      `json:"manufacturer,omitempty"`
+	Connections  [][2]string `json:"connections,omitempty"`
+}
+
+// haDeviceTracker is a Home Assistant MQTT discovery message of a device_tracker entity.
+type haDeviceTracker struct {
+	// Name is null so the entity is named after the device.
+	Name                *string  `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	StateTopic          string   `json:"state_topic"`
+	PayloadHome         string   `json:"payload_home"`
+	PayloadNotHome      string   `json:"payload_not_home"`
+	SourceType          string   `json:"source_type"`
+	JSONAttributesTopic string   `json:"json_attributes_topic"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	Device              haDevice `json:"device"`
+}
+
+func (p *Publisher) discoveryConfig(d *discovery.Device, id string) haDeviceTracker {
+	name := d.DisplayName()
+	if name == "" && d.IP() != nil {
+		name = d.IP().String()
+	}
+	msg := haDeviceTracker{
+		UniqueID:            "whosthere_" + id,
+		StateTopic:          p.deviceTopic(id, "state"),
+		PayloadHome:         PayloadOnline,
+		PayloadNotHome:      PayloadOffline,
+		SourceType:          "router",
+		JSONAttributesTopic: p.deviceTopic(id, "attributes"),
+		AvailabilityTopic:   p.statusTopic(),
+		Device: haDevice{
+			Identifiers:  []string{"whosthere_" + id},
+			Name:         name,
+			Manufacturer: d.Manufacturer(),
+		},
+	}
+	if mac := d.MAC(); mac != "" {
+		msg.Device.Connections = [][2]string{{"mac", strings.ToLower(mac)}}
+	}
+	return msg
+}
+
+func (p *Publisher) statusTopic() string {
+	return p.cfg.TopicPrefix + "/status"
+}
+
+func (p *Publisher) deviceTopic(id, field string) string {
+	return p.cfg.TopicPrefix + "/devices/" + id + "/" + field
+}
+
+// DeviceID returns the topic level of a device: its MAC address, or its IP address when the
+// MAC address is randomized or unknown, with every character other than a-z and 0-9
+// replaced by an underscore.
+func DeviceID(d *discovery.Device) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, d.IdentityKey())
+}
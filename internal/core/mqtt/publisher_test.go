@@ -0,0 +1,241 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDevice(ip, mac, name string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetMAC(mac)
+	d.SetDisplayName(name)
+	d.SetManufacturer("Acme")
+	d.AddSource("arp")
+	return d
+}
+
+// runPublisher runs a publisher against b until the test ends.
+func runPublisher(t *testing.T, b *testBroker, cfg config.DaemonMQTTConfig, snapshot func() []DeviceState) *Publisher {
+	t.Helper()
+	cfg.Broker = b.url()
+	if snapshot == nil {
+		snapshot = func() []DeviceState { return nil }
+	}
+	p := NewPublisher(cfg, snapshot, WithReconnectBackoff(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return p
+}
+
+func testMQTTConfig() config.DaemonMQTTConfig {
+	return config.DaemonMQTTConfig{
+		Enabled:         true,
+		ClientID:        "whosthere",
+		TopicPrefix:     "whosthere/",
+		HomeAssistant:   true,
+		DiscoveryPrefix: "homeassistant",
+	}
+}
+
+func TestPublisher_PublishesSnapshotAndEvents(t *testing.T) {
+	b := newTestBroker(t)
+	tv := newDevice("192.168.1.20", "00:1A:2B:3C:4D:01", "tv")
+	runPublisher(t, b, testMQTTConfig(), func() []DeviceState {
+		return []DeviceState{{Device: tv, Online: true}}
+	})
+
+	b.waitRetained(t, "whosthere/status", PayloadOnline)
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/state", PayloadOnline)
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/ip", "192.168.1.20")
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/name", "tv")
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/vendor", "Acme")
+
+	raw, ok := b.retainedPayload("whosthere/devices/00_1a_2b_3c_4d_01/attributes")
+	require.True(t, ok)
+	var attrs map[string]any
+	require.NoError(t, json.Unmarshal([]byte(raw), &attrs))
+	assert.Equal(t, "192.168.1.20", attrs["ip"])
+	assert.Equal(t, "00:1A:2B:3C:4D:01", attrs["mac"])
+	assert.Equal(t, "tv", attrs["host_name"])
+	assert.Equal(t, []any{"arp"}, attrs["sources"])
+
+	raw, ok = b.retainedPayload("homeassistant/device_tracker/whosthere_00_1a_2b_3c_4d_01/config")
+	require.True(t, ok)
+	var disc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(raw), &disc))
+	assert.Nil(t, disc["name"])
+	assert.Equal(t, "whosthere_00_1a_2b_3c_4d_01", disc["unique_id"])
+	assert.Equal(t, "whosthere/devices/00_1a_2b_3c_4d_01/state", disc["state_topic"])
+	assert.Equal(t, "online", disc["payload_home"])
+	assert.Equal(t, "offline", disc["payload_not_home"])
+	assert.Equal(t, "router", disc["source_type"])
+	assert.Equal(t, "whosthere/status", disc["availability_topic"])
+	assert.Equal(t, map[string]any{
+		"identifiers":  []any{"whosthere_00_1a_2b_3c_4d_01"},
+		"name":         "tv",
+		"manufacturer": "Acme",
+		"connections":  []any{[]any{"mac", "00:1a:2b:3c:4d:01"}},
+	}, disc["device"])
+}
+
+func TestPublisher_Events(t *testing.T) {
+	b := newTestBroker(t)
+	p := runPublisher(t, b, testMQTTConfig(), nil)
+	b.waitRetained(t, "whosthere/status", PayloadOnline)
+
+	phone := newDevice("192.168.1.30", "00:1A:2B:3C:4D:02", "phone")
+	p.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: phone})
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_02/state", PayloadOnline)
+
+	p.Notify(events.Message{Type: events.TypeDeviceOffline, Device: phone})
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_02/state", PayloadOffline)
+
+	phone.SetOpenPorts(map[string][]int{"tcp": {22, 443}})
+	p.Notify(events.Message{Type: events.TypePortsChanged, Device: phone})
+	require.Eventually(t, func() bool {
+		raw, _ := b.retainedPayload("whosthere/devices/00_1a_2b_3c_4d_02/attributes")
+		var attrs struct {
+			OpenPorts map[string][]int `json:"open_ports"`
+		}
+		return json.Unmarshal([]byte(raw), &attrs) == nil && len(attrs.OpenPorts["tcp"]) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	got, _ := b.retainedPayload("whosthere/devices/00_1a_2b_3c_4d_02/state")
+	assert.Equal(t, PayloadOffline, got, "a port change does not change the state")
+}
+
+func TestPublisher_WithoutHomeAssistant(t *testing.T) {
+	b := newTestBroker(t)
+	cfg := testMQTTConfig()
+	cfg.HomeAssistant = false
+	p := runPublisher(t, b, cfg, nil)
+	b.waitRetained(t, "whosthere/status", PayloadOnline)
+
+	p.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("192.168.1.40", "", "printer")})
+	b.waitRetained(t, "whosthere/devices/192_168_1_40/state", PayloadOnline)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for topic := range b.retained {
+		assert.NotContains(t, topic, "homeassistant")
+	}
+}
+
+func TestPublisher_ReconnectsAndResyncs(t *testing.T) {
+	b := newTestBroker(t)
+	tv := newDevice("192.168.1.20", "00:1A:2B:3C:4D:01", "tv")
+	online := true
+	var mu sync.Mutex
+	runPublisher(t, b, testMQTTConfig(), func() []DeviceState {
+		mu.Lock()
+		defer mu.Unlock()
+		return []DeviceState{{Device: tv, Online: online}}
+	})
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/state", PayloadOnline)
+
+	mu.Lock()
+	online = false
+	mu.Unlock()
+	b.drop()
+
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/state", PayloadOffline)
+	assert.GreaterOrEqual(t, b.connectCount(), 2)
+	b.waitRetained(t, "whosthere/status", PayloadOnline)
+}
+
+func TestPublisher_SnapshotReplacesQueuedEvents(t *testing.T) {
+	b := newTestBroker(t)
+	cfg := testMQTTConfig()
+	cfg.Broker = b.url()
+	tv := newDevice("192.168.1.20", "00:1A:2B:3C:4D:01", "tv")
+	p := NewPublisher(cfg, func() []DeviceState { return []DeviceState{{Device: tv, Online: true}} })
+	// queued while disconnected, the snapshot is newer
+	p.Notify(events.Message{Type: events.TypeDeviceOffline, Device: tv})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_01/state", PayloadOnline)
+
+	// events are published in order, so the queued event would have been published by now
+	p.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("192.168.1.30", "00:1A:2B:3C:4D:02", "phone")})
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4d_02/state", PayloadOnline)
+	got, _ := b.retainedPayload("whosthere/devices/00_1a_2b_3c_4d_01/state")
+	assert.Equal(t, PayloadOnline, got)
+}
+
+func TestPublisher_OfflineOnStop(t *testing.T) {
+	b := newTestBroker(t)
+	cfg := testMQTTConfig()
+	cfg.Broker = b.url()
+	snapshot, release := make(chan struct{}), make(chan struct{})
+	p := NewPublisher(cfg, func() []DeviceState {
+		close(snapshot)
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+	<-snapshot
+
+	// events queued when stopping are published before disconnecting
+	for i := range 20 {
+		phone := newDevice(fmt.Sprintf("192.168.1.%d", 100+i), fmt.Sprintf("00:1A:2B:3C:4E:%02d", i), "phone")
+		p.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: phone})
+	}
+	cancel()
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher did not stop")
+	}
+	b.waitRetained(t, "whosthere/devices/00_1a_2b_3c_4e_19/state", PayloadOnline)
+	b.waitRetained(t, "whosthere/status", PayloadOffline)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	require.NotEmpty(t, b.connects)
+	will := b.connects[0].will
+	require.NotNil(t, will)
+	assert.Equal(t, Message{Topic: "whosthere/status", Payload: []byte(PayloadOffline), Retain: true}, *will)
+}
+
+func TestDeviceID(t *testing.T) {
+	assert.Equal(t, "00_1a_2b_3c_4d_01", DeviceID(newDevice("10.0.0.1", "00:1A:2B:3C:4D:01", "")))
+	assert.Equal(t, "10_0_0_2", DeviceID(newDevice("10.0.0.2", "", "")))
+	// a randomized MAC address changes, so the IP address is used
+	assert.Equal(t, "10_0_0_3", DeviceID(newDevice("10.0.0.3", "DA:BB:CC:DD:EE:01", "")))
+}
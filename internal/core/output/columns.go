@@ -0,0 +1,173 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Column is a device field that can be selected for table and CSV output, see WithColumns.
+type Column struct {
+	// Name is the canonical name of the column, used as the CSV header.
+	Name string
+	// Header is the table header of the column.
+	Header string
+	// value returns the values of the column for a device, multi-valued fields
+	// (sources, ports, extra data) return one value per item.
+	value func(d *discovery.Device) []string
+}
+
+// allColumns lists all columns in their default CSV order.
+var allColumns = []Column{
+	{Name: "ip", Header: "IP", value: func(d *discovery.Device) []string { return single(d.IP().String()) }},
+	{Name: "mac", Header: "MAC", value: func(d *discovery.Device) []string { return single(d.MAC()) }},
+	{Name: "randomized_mac", Header: "RANDOMIZED", value: func(d *discovery.Device) []string {
+		return single(strconv.FormatBool(d.RandomizedMAC()))
+	}},
+	{Name: "display_name", Header: "DISPLAY NAME", value: func(d *discovery.Device) []string { return single(d.DisplayName()) }},
+	{Name: "manufacturer", Header: "MANUFACTURER", value: func(d *discovery.Device) []string { return single(d.Manufacturer()) }},
+	{Name: "sources", Header: "SOURCES", value: func(d *discovery.Device) []string { return sourceValues(d.Sources()) }},
+	{Name: "first_seen", Header: "FIRST SEEN", value: func(d *discovery.Device) []string { return single(formatTime(d.FirstSeen())) }},
+	{Name: "last_seen", Header: "LAST SEEN", value: func(d *discovery.Device) []string { return single(formatTime(d.LastSeen())) }},
+	{Name: "open_ports", Header: "OPEN PORTS", value: func(d *discovery.Device) []string { return portValues(d.OpenPorts()) }},
+	{Name: "last_port_scan", Header: "LAST PORT SCAN", value: func(d *discovery.Device) []string {
+		return single(formatTime(d.LastPortScan()))
+	}},
+	{Name: "extra_data", Header: "EXTRA DATA", value: func(d *discovery.Device) []string { return extraDataValues(d.ExtraData()) }},
+}
+
+// columnAliases maps short column names to their canonical name.
+var columnAliases = map[string]string{
+	"name":       "display_name",
+	"vendor":     "manufacturer",
+	"ports":      "open_ports",
+	"randomized": "randomized_mac",
+	"extra":      "extra_data",
+}
+
+// DefaultColumns are the columns of the table output.
+var DefaultColumns = []string{"ip", "display_name", "mac", "manufacturer"}
+
+// ColumnNames returns the canonical names of all columns.
+func ColumnNames() []string {
+	names := make([]string, len(allColumns))
+	for i, c := range allColumns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// LookupColumns returns the columns with the given (canonical or alias) names, in order.
+func LookupColumns(names ...string) ([]Column, error) {
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := columnAliases[name]; ok {
+			name = alias
+		}
+		col, ok := findColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(ColumnNames(), ", "))
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// ParseColumns parses a comma separated list of column names, e.g. "ip,name,ports".
+func ParseColumns(spec string) ([]Column, error) {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return LookupColumns(names...)
+}
+
+func findColumn(name string) (Column, bool) {
+	for _, c := range allColumns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+func mustColumns(names ...string) []Column {
+	columns, err := LookupColumns(names...)
+	if err != nil {
+		panic(err)
+	}
+	return columns
+}
+
+func single(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+func sourceValues(sources map[string]struct{}) []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openPort is an open port of a device with its well-known service name, if any.
+type openPort struct {
+	Port     int
+	Protocol string
+	Service  string
+}
+
+// sortedOpenPorts returns the open ports ordered by protocol and port.
+func sortedOpenPorts(ports map[string][]int) []openPort {
+	protocols := make([]string, 0, len(ports))
+	for proto := range ports {
+		protocols = append(protocols, proto)
+	}
+	sort.Strings(protocols)
+
+	var out []openPort
+	for _, proto := range protocols {
+		sorted := append([]int(nil), ports[proto]...)
+		sort.Ints(sorted)
+		for _, p := range sorted {
+			out = append(out, openPort{Port: p, Protocol: proto, Service: discovery.ServiceName(proto, p)})
+		}
+	}
+	return out
+}
+
+func portValues(ports map[string][]int) []string {
+	var values []string
+	for _, p := range sortedOpenPorts(ports) {
+		values = append(values, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+	}
+	return values
+}
+
+func extraDataValues(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = k + "=" + data[k]
+	}
+	return values
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("ip, name,vendor,PORTS")
+	if err != nil {
+		t.Fatalf("ParseColumns failed: %v", err)
+	}
+
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "ip,display_name,manufacturer,open_ports" {
+		t.Errorf("unexpected columns: %s", got)
+	}
+
+	if _, err := ParseColumns("ip,bogus"); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := ParseColumns(" , "); err == nil {
+		t.Error("expected error for empty column list")
+	}
+}
+
+func TestPrintDevices_TableWithColumns(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	d.AddSource("mdns")
+	d.AddSource("arp")
+	d.SetOpenPorts(map[string][]int{"tcp": {443, 22}})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatTable, WithColumns("ip", "sources", "ports", "name")); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "IP SOURCES OPEN PORTS DISPLAY NAME" {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "arp, mdns") || !strings.Contains(lines[2], "22/tcp, 443/tcp") {
+		t.Errorf("unexpected row: %q", lines[2])
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[2]), "-") {
+		t.Errorf("expected empty name to be shown as -, got %q", lines[2])
+	}
+
+	buf.Reset()
+	if err := PrintDevices(&buf, results, FormatCSV, WithColumns("ip", "ports")); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+	if buf.String() != "ip,open_ports\n192.168.1.1,22/tcp;443/tcp\n" {
+		t.Errorf("unexpected CSV output: %q", buf.String())
+	}
+
+	if _, err := NewOutput(FormatTable, WithColumns("nope")); err == nil {
+		t.Error("expected error for unknown column")
+	}
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var _ Formatter = (*CSVFormatter)(nil)
+
+// CSVFormatter implements Formatter for CSV output, e.g. for spreadsheets.
+// Multi-valued fields are flattened into a single cell separated by semicolons:
+// sources as "arp;mdns", open ports as "22/tcp;443/tcp" and extra data as "key=value;key=value".
+type CSVFormatter struct {
+	columns []Column
+}
+
+// NewCSVFormatter returns a CSV formatter with the given columns, or all columns when none are given.
+func NewCSVFormatter(columns ...Column) *CSVFormatter {
+	if len(columns) == 0 {
+		columns = allColumns
+	}
+	return &CSVFormatter{columns: columns}
+}
+
+func (f *CSVFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(f.columns))
+	for i, c := range f.columns {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, d := range results.Devices {
+		record := make([]string, len(f.columns))
+		for i, c := range f.columns {
+			record[i] = strings.Join(c.value(d), ";")
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatTime formats t as RFC 3339, or an empty string for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
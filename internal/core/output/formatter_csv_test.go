@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestPrintDevices_CSV(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetMAC("28:6f:b9:00:11:22")
+	d.SetDisplayName("printer, office")
+	d.SetManufacturer("Acme")
+	d.AddSource("mdns")
+	d.AddSource("arp")
+	d.AddExtraData("model", "X1")
+	d.AddExtraData("fw", "1.2")
+	d.SetFirstSeen(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC))
+	d.SetOpenPorts(map[string][]int{"tcp": {443, 22}})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d, discovery.NewDevice(net.ParseIP("192.168.1.20"))},
+		Stats:   &discovery.ScanStats{Count: 2, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatCSV); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d", len(records))
+	}
+
+	row := make(map[string]string)
+	for i, col := range records[0] {
+		row[col] = records[1][i]
+	}
+
+	expected := map[string]string{
+		"ip":             "192.168.1.10",
+		"display_name":   "printer, office",
+		"randomized_mac": "false",
+		"sources":        "arp;mdns",
+		"first_seen":     "2025-01-01T10:00:00Z",
+		"last_port_scan": "",
+		"open_ports":     "22/tcp;443/tcp",
+		"extra_data":     "fw=1.2;model=X1",
+	}
+	for col, want := range expected {
+		if row[col] != want {
+			t.Errorf("column %s: expected %q, got %q", col, want, row[col])
+		}
+	}
+}
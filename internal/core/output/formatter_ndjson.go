@@ -0,0 +1,28 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var _ Formatter = (*NDJSONFormatter)(nil)
+
+// NDJSONFormatter implements Formatter for newline delimited JSON output,
+// writing one device object per line, e.g. for log pipelines.
+type NDJSONFormatter struct{}
+
+func NewNDJSONFormatter() *NDJSONFormatter {
+	return &NDJSONFormatter{}
+}
+
+func (f *NDJSONFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	encoder := json.NewEncoder(w)
+	for _, d := range results.Devices {
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
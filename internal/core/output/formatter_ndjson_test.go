@@ -0,0 +1,41 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestPrintDevices_NDJSON(t *testing.T) {
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{
+			discovery.NewDevice(net.ParseIP("192.168.1.20")),
+			discovery.NewDevice(net.ParseIP("192.168.1.3")),
+		},
+		Stats: &discovery.ScanStats{Count: 2, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatNDJSON); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	var ips []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var d discovery.Device
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		ips = append(ips, d.IP().String())
+	}
+
+	if len(ips) != 2 || ips[0] != "192.168.1.3" || ips[1] != "192.168.1.20" {
+		t.Fatalf("expected one sorted device per line, got %v", ips)
+	}
+}
@@ -0,0 +1,202 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var _ Formatter = (*NmapFormatter)(nil)
+
+// nmapTimeFormat is the format of the startstr and timestr attributes, like nmap's ctime output.
+const nmapTimeFormat = "Mon Jan 2 15:04:05 2006"
+
+// NmapFormatter implements Formatter for nmap's XML output format, so results can be
+// imported into tools that understand nmap scans. Devices are reported as hosts that are up,
+// with their MAC address and vendor, display name (as hostname) and open ports.
+type NmapFormatter struct {
+	version string
+	args    string
+	now     func() time.Time
+}
+
+// NewNmapFormatter returns an nmap XML formatter, version is reported as the scanner version
+// and args as the command line of the scan.
+func NewNmapFormatter(version, args string) *NmapFormatter {
+	return &NmapFormatter{version: version, args: args, now: time.Now}
+}
+
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapHost struct {
+	StartTime int64         `xml:"starttime,attr,omitempty"`
+	EndTime   int64         `xml:"endtime,attr,omitempty"`
+	Status    nmapStatus    `xml:"status"`
+	Addresses []nmapAddress `xml:"address"`
+	Hostnames nmapHostnames `xml:"hostnames"`
+	Ports     *nmapPorts    `xml:"ports,omitempty"`
+}
+
+type nmapStatus struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type nmapHostnames struct {
+	Hostnames []nmapHostname `xml:"hostname"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPorts struct {
+	Ports []nmapPort `xml:"port"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapStatus   `xml:"state"`
+	Service  *nmapService `xml:"service,omitempty"`
+}
+
+type nmapService struct {
+	Name   string `xml:"name,attr"`
+	Method string `xml:"method,attr"`
+	Conf   int    `xml:"conf,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHostsRun `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHostsRun struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+func (f *NmapFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	end := f.now()
+	var elapsed time.Duration
+	if results.Stats != nil {
+		elapsed = results.Stats.Duration
+	}
+	start := end.Add(-elapsed)
+
+	run := nmapRun{
+		Scanner:          "whosthere",
+		Args:             f.args,
+		Start:            start.Unix(),
+		StartStr:         start.Format(nmapTimeFormat),
+		Version:          f.version,
+		XMLOutputVersion: "1.05",
+		Hosts:            make([]nmapHost, 0, len(results.Devices)),
+		RunStats: nmapRunStats{
+			Finished: nmapFinished{
+				Time:    end.Unix(),
+				TimeStr: end.Format(nmapTimeFormat),
+				Elapsed: fmt.Sprintf("%.2f", elapsed.Seconds()),
+				Summary: fmt.Sprintf("whosthere done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds",
+					end.Format(nmapTimeFormat), len(results.Devices), len(results.Devices), elapsed.Seconds()),
+				Exit: "success",
+			},
+			Hosts: nmapHostsRun{Up: len(results.Devices), Total: len(results.Devices)},
+		},
+	}
+
+	for _, d := range results.Devices {
+		run.Hosts = append(run.Hosts, newNmapHost(d))
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newNmapHost(d *discovery.Device) nmapHost {
+	host := nmapHost{Status: nmapStatus{State: "up", Reason: nmapReason(d)}}
+	if !d.FirstSeen().IsZero() {
+		host.StartTime = d.FirstSeen().Unix()
+	}
+	if !d.LastSeen().IsZero() {
+		host.EndTime = d.LastSeen().Unix()
+	}
+
+	addrType := "ipv4"
+	if d.IP().To4() == nil {
+		addrType = "ipv6"
+	}
+	host.Addresses = append(host.Addresses, nmapAddress{Addr: d.IP().String(), AddrType: addrType})
+	if d.MAC() != "" {
+		host.Addresses = append(host.Addresses, nmapAddress{
+			Addr:     strings.ToUpper(d.MAC()),
+			AddrType: "mac",
+			Vendor:   d.Manufacturer(),
+		})
+	}
+
+	if name := d.DisplayName(); name != "" {
+		host.Hostnames.Hostnames = append(host.Hostnames.Hostnames, nmapHostname{Name: name, Type: "user"})
+	}
+
+	if ports := sortedOpenPorts(d.OpenPorts()); len(ports) > 0 {
+		host.Ports = &nmapPorts{}
+		for _, op := range ports {
+			p := nmapPort{Protocol: op.Protocol, PortID: op.Port, State: nmapStatus{State: "open", Reason: "syn-ack"}}
+			if op.Service != "" {
+				p.Service = &nmapService{Name: op.Service, Method: "table", Conf: 3}
+			}
+			host.Ports.Ports = append(host.Ports.Ports, p)
+		}
+	}
+	return host
+}
+
+// nmapReason returns the host status reason: arp-response for devices found in the ARP
+// cache, user-set otherwise, since they were discovered by announcements rather than probes.
+func nmapReason(d *discovery.Device) string {
+	if _, ok := d.Sources()["arp-cache"]; ok {
+		return "arp-response"
+	}
+	return "user-set"
+}
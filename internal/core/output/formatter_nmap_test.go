@@ -0,0 +1,69 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestNmapFormatter_Golden(t *testing.T) {
+	seen := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	router := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	router.SetDisplayName("router.lan")
+	router.SetMAC("aa:bb:cc:dd:ee:ff")
+	router.SetManufacturer("Acme & Sons")
+	router.AddSource("arp-cache")
+	router.SetFirstSeen(seen)
+	router.SetLastSeen(seen.Add(time.Minute))
+	router.SetOpenPorts(map[string][]int{"tcp": {443, 22, 8081}})
+
+	speaker := discovery.NewDevice(net.ParseIP("fe80::1"))
+	speaker.AddSource("mdns")
+	speaker.SetFirstSeen(seen)
+	speaker.SetLastSeen(seen)
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{router, speaker},
+		Stats:   &discovery.ScanStats{Count: 2, Duration: 5 * time.Second},
+	}
+
+	f := NewNmapFormatter("1.2.3", "whosthere scan --format=nmap --filter \"vendor~acme\"")
+	f.now = func() time.Time { return seen.Add(2 * time.Minute) }
+
+	var buf bytes.Buffer
+	if err := f.Format(&buf, results); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "nmap.golden.xml")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output does not match %s (run with -update to regenerate):\n%s", golden, buf.String())
+	}
+
+	var run nmapRun
+	if err := xml.Unmarshal(buf.Bytes(), &run); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+	if len(run.Hosts) != 2 || run.RunStats.Hosts.Up != 2 {
+		t.Errorf("expected 2 hosts up, got %+v", run.RunStats.Hosts)
+	}
+}
@@ -0,0 +1,159 @@
+package output
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var (
+	_ Formatter = (*ReportFormatter)(nil)
+
+	//go:embed templates/*.tmpl
+	reportTemplates embed.FS
+)
+
+// executor is implemented by both text/template and html/template templates.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// ReportFormatter implements Formatter for human-readable network inventory reports,
+// rendered from the embedded HTML or Markdown template.
+type ReportFormatter struct {
+	tmpl executor
+	now  func() time.Time
+}
+
+// NewHTMLFormatter returns a formatter for a self-contained HTML report with a sortable
+// device table, per-device details and the scan stats.
+func NewHTMLFormatter() *ReportFormatter {
+	tmpl := htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(htmltemplate.FuncMap(reportFuncs)).
+		ParseFS(reportTemplates, "templates/report.html.tmpl"))
+	return &ReportFormatter{tmpl: tmpl, now: time.Now}
+}
+
+// NewMarkdownFormatter returns a formatter for a Markdown report, e.g. for wiki pages.
+func NewMarkdownFormatter() *ReportFormatter {
+	tmpl := template.Must(template.New("report.md.tmpl").Funcs(reportFuncs).
+		ParseFS(reportTemplates, "templates/report.md.tmpl"))
+	return &ReportFormatter{tmpl: tmpl, now: time.Now}
+}
+
+func (f *ReportFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	return f.tmpl.Execute(w, newReport(results, f.now()))
+}
+
+// reportFuncs are the functions available in the report templates.
+var reportFuncs = template.FuncMap{
+	"md":   escapeMarkdown,
+	"time": formatReportTime,
+}
+
+// report is the data passed to the report templates.
+type report struct {
+	Generated time.Time
+	Duration  string
+	Devices   []reportDevice
+	OpenPorts int
+}
+
+type reportDevice struct {
+	IP           string
+	Name         string
+	MAC          string
+	Randomized   bool
+	Vendor       string
+	Sources      []string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Ports        []openPort
+	LastPortScan time.Time
+	ExtraData    []reportKV
+	// SortIP is the IP as a fixed-width string, so the HTML table sorts IPs numerically.
+	SortIP string
+}
+
+type reportKV struct {
+	Key, Value string
+}
+
+func newReport(results *discovery.ScanResults, now time.Time) report {
+	r := report{Generated: now, Devices: make([]reportDevice, 0, len(results.Devices))}
+	if results.Stats != nil {
+		r.Duration = formatDuration(results.Stats.Duration)
+	}
+
+	for _, d := range results.Devices {
+		rd := reportDevice{
+			IP:           d.IP().String(),
+			Name:         d.DisplayName(),
+			MAC:          d.MAC(),
+			Randomized:   d.RandomizedMAC(),
+			Vendor:       d.Manufacturer(),
+			Sources:      sourceValues(d.Sources()),
+			FirstSeen:    d.FirstSeen(),
+			LastSeen:     d.LastSeen(),
+			LastPortScan: d.LastPortScan(),
+			SortIP:       sortableIP(d),
+		}
+
+		rd.Ports = sortedOpenPorts(d.OpenPorts())
+		r.OpenPorts += len(rd.Ports)
+
+		extra := d.ExtraData()
+		for _, k := range sortedKeys(extra) {
+			rd.ExtraData = append(rd.ExtraData, reportKV{Key: k, Value: extra[k]})
+		}
+
+		r.Devices = append(r.Devices, rd)
+	}
+	return r
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortableIP returns the 16-byte form of the IP as hex, which sorts like discovery.CompareIPs.
+func sortableIP(d *discovery.Device) string {
+	const hexDigits = "0123456789abcdef"
+	ip := d.IP().To16()
+	var b strings.Builder
+	for _, octet := range ip {
+		b.WriteByte(hexDigits[octet>>4])
+		b.WriteByte(hexDigits[octet&0x0f])
+	}
+	return b.String()
+}
+
+func formatReportTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// markdownEscaper escapes the characters that would break Markdown table cells or formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;",
+	"\r\n", " ", "\n", " ",
+)
+
+func escapeMarkdown(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return markdownEscaper.Replace(s)
+}
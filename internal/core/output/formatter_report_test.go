@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func reportResults() *discovery.ScanResults {
+	tv := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	tv.SetDisplayName("Living|Room <TV>")
+	tv.SetMAC("28:6f:b9:00:11:22")
+	tv.SetManufacturer("Apple, Inc.")
+	tv.AddSource("mdns")
+	tv.AddExtraData("model", "AppleTV6,2")
+	tv.SetLastSeen(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC))
+	tv.SetOpenPorts(map[string][]int{"tcp": {7000, 22}})
+
+	return &discovery.ScanResults{
+		Devices: []*discovery.Device{tv, discovery.NewDevice(net.ParseIP("192.168.1.2"))},
+		Stats:   &discovery.ScanStats{Count: 2, Duration: 2500 * time.Millisecond},
+	}
+}
+
+func TestReportFormatter_HTML(t *testing.T) {
+	f := NewHTMLFormatter()
+	f.now = func() time.Time { return time.Date(2025, 1, 2, 8, 0, 0, 0, time.UTC) }
+
+	var buf bytes.Buffer
+	if err := f.Format(&buf, reportResults()); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	output := buf.String()
+	if os.Getenv("DUMP") != "" {
+		t.Log(output)
+	}
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"2025-01-02 08:00:00",
+		"Living|Room &lt;TV&gt;",
+		"22/tcp ssh",
+		"<b>2</b>devices",
+		"<b>2.5s</b>scan duration",
+		`<details id="device-192.168.1.10">`,
+		"AppleTV6,2",
+		"<script>",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected HTML report to contain %q", want)
+		}
+	}
+	if strings.Contains(output, "<TV>") {
+		t.Error("expected device values to be HTML escaped")
+	}
+}
+
+func TestReportFormatter_Markdown(t *testing.T) {
+	f := NewMarkdownFormatter()
+	f.now = func() time.Time { return time.Date(2025, 1, 2, 8, 0, 0, 0, time.UTC) }
+
+	var buf bytes.Buffer
+	if err := f.Format(&buf, reportResults()); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	output := buf.String()
+	if os.Getenv("DUMP") != "" {
+		t.Log(output)
+	}
+
+	for _, want := range []string{
+		"# Network inventory",
+		"| 2 | 2 | 2.5s |",
+		`| 192.168.1.10 | Living\|Room &lt;TV&gt; | 28:6f:b9:00:11:22 | Apple, Inc. | mdns | 22/tcp, 7000/tcp | 2025-01-01 10:00:00 |`,
+		"| 192.168.1.2 | - | - | - | - | - |",
+		"### 192.168.1.10",
+		"| 22 | tcp | ssh |",
+		"| model | AppleTV6,2 |",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected Markdown report to contain %q", want)
+		}
+	}
+}
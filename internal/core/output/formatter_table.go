@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"io"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
@@ -11,35 +12,39 @@ import (
 var _ Formatter = (*TableFormatter)(nil)
 
 // TableFormatter implements Formatter for table output
-type TableFormatter struct{}
+type TableFormatter struct {
+	columns []Column
+}
 
-func NewTableFormatter() *TableFormatter {
-	return &TableFormatter{}
+// NewTableFormatter returns a table formatter with the given columns, or DefaultColumns when none are given.
+func NewTableFormatter(columns ...Column) *TableFormatter {
+	if len(columns) == 0 {
+		columns = mustColumns(DefaultColumns...)
+	}
+	return &TableFormatter{columns: columns}
 }
 
 func (f *TableFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
 
-	_, _ = fmt.Fprintln(tw, "IP\tDISPLAY NAME\tMAC\tMANUFACTURER")
-	_, _ = fmt.Fprintln(tw, "──\t────────────\t───\t────────────")
+	headers := make([]string, len(f.columns))
+	underlines := make([]string, len(f.columns))
+	for i, c := range f.columns {
+		headers[i] = c.Header
+		underlines[i] = strings.Repeat("─", len(c.Header))
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	_, _ = fmt.Fprintln(tw, strings.Join(underlines, "\t"))
 
 	for _, d := range results.Devices {
-		ip := d.IP().String()
-		name := d.DisplayName()
-		mac := d.MAC()
-		manufacturer := d.Manufacturer()
-
-		if name == "" {
-			name = "-"
+		cells := make([]string, len(f.columns))
+		for i, c := range f.columns {
+			cells[i] = strings.Join(c.value(d), ", ")
+			if cells[i] == "" {
+				cells[i] = "-"
+			}
 		}
-		if mac == "" {
-			mac = "-"
-		}
-		if manufacturer == "" {
-			manufacturer = "-"
-		}
-
-		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ip, name, mac, manufacturer)
+		_, _ = fmt.Fprintln(tw, strings.Join(cells, "\t"))
 	}
 
 	if err := tw.Flush(); err != nil {
@@ -88,3 +88,39 @@ func TestPrintDevices_Empty(t *testing.T) {
 		t.Error("expected output to contain elapsed time")
 	}
 }
+
+func TestPrintDevices_FilterAndSort(t *testing.T) {
+	router := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	router.SetDisplayName("router")
+	nas := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	nas.SetDisplayName("nas")
+	phone := discovery.NewDevice(net.ParseIP("192.168.1.30"))
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{router, nas, phone},
+		Stats:   &discovery.ScanStats{Count: 3, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	err := PrintDevices(&buf, results, FormatTable,
+		WithFilter(func(d *discovery.Device) bool { return d.DisplayName() != "" }),
+		WithSort(func(a, b *discovery.Device) bool { return a.DisplayName() < b.DisplayName() }),
+	)
+	if err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "192.168.1.30") {
+		t.Error("expected filtered device to be omitted")
+	}
+	if strings.Index(output, "nas") > strings.Index(output, "router") {
+		t.Error("expected devices to be sorted by name")
+	}
+	if !strings.Contains(output, "2 device(s) found") {
+		t.Error("expected count of the filtered devices")
+	}
+	if len(results.Devices) != 3 {
+		t.Error("filtering should not modify the results")
+	}
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var _ Formatter = (*TemplateFormatter)(nil)
+
+// templateFuncs are the functions available in output templates, next to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"sources": func(d *discovery.Device) string { return strings.Join(sourceValues(d.Sources()), ",") },
+	"ports":   func(d *discovery.Device) string { return strings.Join(portValues(d.OpenPorts()), ",") },
+}
+
+// TemplateFormatter implements Formatter using a Go text/template, similar to `docker ps --format`.
+// The template is executed once per device (a *discovery.Device, e.g. {{.IP}} {{.DisplayName}})
+// and each result is followed by a newline.
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewTemplateFormatter parses text as a device template. Escaped tabs and newlines ("\t", "\n")
+// are unescaped, so templates can be passed on the command line.
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	text = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(text)
+	tmpl, err := template.New("device").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &TemplateFormatter{tmpl: tmpl}, nil
+}
+
+func (f *TemplateFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	for _, d := range results.Devices {
+		if err := f.tmpl.Execute(w, d); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package output
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestPrintDevices_Template(t *testing.T) {
+	router := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	router.SetDisplayName("Router")
+	router.SetOpenPorts(map[string][]int{"tcp": {80, 22}})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{discovery.NewDevice(net.ParseIP("192.168.1.20")), router},
+		Stats:   &discovery.ScanStats{Count: 2, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	err := PrintDevices(&buf, results, FormatJSON, WithTemplate(`{{.IP}}\t{{or .DisplayName "?"}}\t{{ports .}}`))
+	if err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	expected := "192.168.1.1\tRouter\t22/tcp,80/tcp\n192.168.1.20\t?\t\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	if _, err := NewOutput(FormatTable, WithTemplate("{{.IP")); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
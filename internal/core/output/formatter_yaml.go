@@ -0,0 +1,32 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+var _ Formatter = (*YAMLFormatter)(nil)
+
+// YAMLFormatter implements Formatter for YAML output.
+// The document has the same structure and field names as the JSON output.
+type YAMLFormatter struct{}
+
+func NewYAMLFormatter() *YAMLFormatter {
+	return &YAMLFormatter{}
+}
+
+func (f *YAMLFormatter) Format(w io.Writer, results *discovery.ScanResults) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.JSONToYAML(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,52 @@
+package output
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestPrintDevices_YAML(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetDisplayName("printer")
+	d.SetOpenPorts(map[string][]int{"tcp": {22}})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: 1500 * time.Millisecond},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatYAML); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	var doc struct {
+		Devices []struct {
+			IP          string           `yaml:"ip"`
+			DisplayName string           `yaml:"displayName"`
+			OpenPorts   map[string][]int `yaml:"openPorts"`
+		} `yaml:"devices"`
+		Stats struct {
+			Count    int    `yaml:"count"`
+			Duration string `yaml:"duration"`
+		} `yaml:"stats"`
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, buf.String())
+	}
+
+	if len(doc.Devices) != 1 || doc.Devices[0].IP != "192.168.1.10" || doc.Devices[0].DisplayName != "printer" {
+		t.Fatalf("unexpected devices: %+v", doc.Devices)
+	}
+	if ports := doc.Devices[0].OpenPorts["tcp"]; len(ports) != 1 || ports[0] != 22 {
+		t.Errorf("unexpected open ports: %v", doc.Devices[0].OpenPorts)
+	}
+	if doc.Stats.Count != 1 || doc.Stats.Duration != "1.5s" {
+		t.Errorf("unexpected stats: %+v", doc.Stats)
+	}
+}
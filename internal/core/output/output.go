@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
@@ -15,8 +16,53 @@ type Format int
 const (
 	FormatTable Format = iota
 	FormatJSON
+	FormatCSV
+	FormatYAML
+	FormatNDJSON
+	FormatHTML
+	FormatMarkdown
+	FormatNmap
 )
 
+var formatNames = map[Format]string{
+	FormatTable:    "table",
+	FormatJSON:     "json",
+	FormatCSV:      "csv",
+	FormatYAML:     "yaml",
+	FormatNDJSON:   "ndjson",
+	FormatHTML:     "html",
+	FormatMarkdown: "markdown",
+	FormatNmap:     "nmap",
+}
+
+// String returns the name of the format as accepted by ParseFormat.
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatNames returns the names of all supported formats, in declaration order.
+func FormatNames() []string {
+	names := make([]string, 0, len(formatNames))
+	for f := FormatTable; int(f) < len(formatNames); f++ {
+		names = append(names, formatNames[f])
+	}
+	return names
+}
+
+// ParseFormat returns the format with the given (case-insensitive) name.
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return FormatTable, fmt.Errorf("invalid output format %q, expected one of %s", name, strings.Join(FormatNames(), ", "))
+}
+
 var DefaultSortFunc = func(a, b *discovery.Device) bool {
 	return discovery.CompareIPs(a.IP(), b.IP())
 }
@@ -31,7 +77,12 @@ func formatDuration(d time.Duration) string {
 type Output struct {
 	formatter Formatter
 	sortFunc  func(a, b *discovery.Device) bool
+	filter    func(d *discovery.Device) bool
 	pretty    bool
+	version   string
+	args      string
+	columns   []Column
+	template  *TemplateFormatter
 }
 
 // Formatter defines the interface for output formatters
@@ -53,11 +104,25 @@ func NewOutput(format Format, opts ...Option) (*Output, error) {
 	}
 
 	var formatter Formatter
-	switch format {
-	case FormatJSON:
+	switch {
+	case o.template != nil:
+		formatter = o.template
+	case format == FormatJSON:
 		formatter = NewJSONFormatter(o.pretty)
+	case format == FormatCSV:
+		formatter = NewCSVFormatter(o.columns...)
+	case format == FormatYAML:
+		formatter = NewYAMLFormatter()
+	case format == FormatNDJSON:
+		formatter = NewNDJSONFormatter()
+	case format == FormatHTML:
+		formatter = NewHTMLFormatter()
+	case format == FormatMarkdown:
+		formatter = NewMarkdownFormatter()
+	case format == FormatNmap:
+		formatter = NewNmapFormatter(o.version, o.args)
 	default:
-		formatter = NewTableFormatter()
+		formatter = NewTableFormatter(o.columns...)
 	}
 
 	o.formatter = formatter
@@ -66,6 +131,16 @@ func NewOutput(format Format, opts ...Option) (*Output, error) {
 
 // PrintDevices prints devices to the writer
 func (o *Output) PrintDevices(w io.Writer, results *discovery.ScanResults) error {
+	if o.filter != nil {
+		devices := make([]*discovery.Device, 0, len(results.Devices))
+		for _, d := range results.Devices {
+			if o.filter(d) {
+				devices = append(devices, d)
+			}
+		}
+		results = &discovery.ScanResults{Devices: devices, Stats: results.Stats}
+	}
+
 	sort.Slice(results.Devices, func(i, j int) bool {
 		return o.sortFunc(results.Devices[i], results.Devices[j])
 	})
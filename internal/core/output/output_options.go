@@ -1,6 +1,11 @@
 package output
 
-import "github.com/ramonvermeulen/whosthere/pkg/discovery"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
 
 type Option func(output *Output) error
 
@@ -11,9 +16,66 @@ func WithPretty() Option {
 	}
 }
 
+// WithFilter only prints the devices for which match returns true.
+func WithFilter(match func(d *discovery.Device) bool) Option {
+	return func(o *Output) error {
+		o.filter = match
+		return nil
+	}
+}
+
+// WithVersion sets the whosthere version reported by formats that include it, e.g. nmap XML.
+func WithVersion(version string) Option {
+	return func(o *Output) error {
+		o.version = version
+		return nil
+	}
+}
+
+// WithCommandLine sets the command line reported by formats that include it, e.g. nmap XML.
+// Arguments containing whitespace or quotes are quoted.
+func WithCommandLine(args []string) Option {
+	return func(o *Output) error {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = arg
+			if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+				quoted[i] = strconv.Quote(arg)
+			}
+		}
+		o.args = strings.Join(quoted, " ")
+		return nil
+	}
+}
+
 func WithSort(sortFunc func(a, b *discovery.Device) bool) Option {
 	return func(o *Output) error {
 		o.sortFunc = sortFunc
 		return nil
 	}
 }
+
+// WithColumns selects the columns of the table and CSV output, see ColumnNames.
+func WithColumns(names ...string) Option {
+	return func(o *Output) error {
+		columns, err := LookupColumns(names...)
+		if err != nil {
+			return err
+		}
+		o.columns = columns
+		return nil
+	}
+}
+
+// WithTemplate formats each device with a Go text/template instead of the output format,
+// see NewTemplateFormatter.
+func WithTemplate(text string) Option {
+	return func(o *Output) error {
+		f, err := NewTemplateFormatter(text)
+		if err != nil {
+			return err
+		}
+		o.template = f
+		return nil
+	}
+}
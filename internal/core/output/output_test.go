@@ -0,0 +1,33 @@
+package output
+
+import "testing"
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range FormatNames() {
+		f, err := ParseFormat(name)
+		if err != nil {
+			t.Fatalf("ParseFormat(%q) failed: %v", name, err)
+		}
+		if f.String() != name {
+			t.Errorf("expected %q to round trip, got %q", name, f.String())
+		}
+	}
+
+	if f, err := ParseFormat(" NDJSON "); err != nil || f != FormatNDJSON {
+		t.Errorf("expected case-insensitive match, got %v, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestWithCommandLine(t *testing.T) {
+	o, err := NewOutput(FormatNmap, WithCommandLine([]string{"whosthere", "scan", "--format=nmap", "--filter", "name~living room", ""}))
+	if err != nil {
+		t.Fatalf("NewOutput failed: %v", err)
+	}
+	want := `whosthere scan --format=nmap --filter "name~living room" ""`
+	if got := o.formatter.(*NmapFormatter).args; got != want {
+		t.Errorf("expected args %q, got %q", want, got)
+	}
+}
@@ -10,8 +10,10 @@ const (
 	appName          = "whosthere"
 	xdgConfigDirEnv  = "XDG_CONFIG_HOME"
 	xdgStateDirEnv   = "XDG_STATE_HOME"
+	xdgRuntimeDirEnv = "XDG_RUNTIME_DIR"
 	defaultConfigDir = ".config"
 	defaultStateDir  = ".local/state"
+	runtimeSubDir    = "run"
 )
 
 // ConfigDir returns the XDG config directory for this app without creating it.
@@ -81,3 +83,28 @@ func StateDir() (string, error) {
 	}
 	return dir, nil
 }
+
+// RuntimeDir returns the XDG runtime directory for this app, for files such as
+// sockets, creating it if necessary. It follows XDG_RUNTIME_DIR when set and
+// otherwise falls back to the run directory in the state directory, see StateDir.
+// The directory is only accessible by the current user, also when it already existed.
+func RuntimeDir() (string, error) {
+	var dir string
+	if env := os.Getenv(xdgRuntimeDirEnv); env != "" {
+		dir = filepath.Join(env, appName)
+	} else {
+		stateDir, err := StateDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(stateDir, runtimeSubDir)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
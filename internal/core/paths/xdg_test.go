@@ -74,3 +74,53 @@ func TestStateDir(t *testing.T) {
 		t.Errorf("expected %s, got %s", expected, dir)
 	}
 }
+
+func TestRuntimeDir(t *testing.T) {
+	// Test with XDG_RUNTIME_DIR set
+	tmpDir := t.TempDir()
+	t.Setenv(xdgRuntimeDirEnv, tmpDir)
+	dir, err := RuntimeDir()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := filepath.Join(tmpDir, appName)
+	if dir != expected {
+		t.Errorf("expected %s, got %s", expected, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("expected %s to be created: %v", dir, err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("expected mode 0700, got %o", info.Mode().Perm())
+	}
+
+	// an existing directory is made private
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RuntimeDir(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		t.Errorf("stat %s: %v", dir, err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("expected mode 0700, got %o", info.Mode().Perm())
+	}
+
+	// Test without XDG_RUNTIME_DIR - falls back to the run directory in the state directory
+	t.Setenv(xdgRuntimeDirEnv, "")
+	stateDir := t.TempDir()
+	t.Setenv(xdgStateDirEnv, stateDir)
+	dir, err = RuntimeDir()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected = filepath.Join(stateDir, appName, runtimeSubDir)
+	if dir != expected {
+		t.Errorf("expected %s, got %s", expected, dir)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		t.Errorf("expected %s to be created: %v", dir, err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("expected mode 0700, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,320 @@
+// Package query implements the device filter expressions and sort orders shared by
+// the CLI output and the TUI.
+package query
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Fields that can be used in filter expressions.
+var fields = map[string]func(d *discovery.Device) []string{
+	"ip":     func(d *discovery.Device) []string { return []string{d.IP().String()} },
+	"name":   func(d *discovery.Device) []string { return []string{d.DisplayName()} },
+	"mac":    func(d *discovery.Device) []string { return []string{d.MAC()} },
+	"vendor": func(d *discovery.Device) []string { return []string{d.Manufacturer()} },
+	"source": func(d *discovery.Device) []string {
+		var sources []string
+		for s := range d.Sources() {
+			sources = append(sources, s)
+		}
+		return sources
+	},
+	"port": func(d *discovery.Device) []string {
+		var ports []string
+		for _, list := range d.OpenPorts() {
+			for _, p := range list {
+				ports = append(ports, strconv.Itoa(p))
+			}
+		}
+		return ports
+	},
+	"randomized": func(d *discovery.Device) []string { return []string{strconv.FormatBool(d.RandomizedMAC())} },
+}
+
+// fieldAliases maps alternative field names to their canonical name.
+var fieldAliases = map[string]string{
+	"manufacturer": "vendor",
+	"hostname":     "name",
+	"sources":      "source",
+	"ports":        "port",
+}
+
+// searchFields are matched by terms without a field, like the TUI search always did.
+var searchFields = []string{"ip", "name", "mac", "vendor"}
+
+// operators ordered so that two-character operators are matched first.
+var operators = []string{"!=", "!~", "=", "~"}
+
+// Filter is a parsed filter expression, see ParseFilter. A nil Filter matches all devices.
+type Filter struct {
+	expr  string
+	terms []term
+}
+
+type term struct {
+	field  string // empty for free text terms
+	op     string
+	value  string
+	re     *regexp.Regexp
+	subnet *net.IPNet
+}
+
+// ParseFilter parses a filter expression. The expression consists of whitespace separated
+// terms that must all match:
+//
+//	field=value   equals (case-insensitive), ip also accepts a CIDR, e.g. ip=192.168.1.0/24
+//	field!=value  does not equal
+//	field~regex   matches the regular expression (case-insensitive)
+//	field!~regex  does not match the regular expression
+//	regex         matches ip, name, mac or vendor
+//
+// Fields are ip, name, mac, vendor, source, port and randomized. For fields with multiple
+// values (source, port) a term matches when any of the values matches. Values containing
+// whitespace can be quoted, e.g. name="living room". An empty expression returns a nil Filter.
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	f := &Filter{expr: strings.TrimSpace(expr)}
+	for _, tok := range tokens {
+		t, err := parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		f.terms = append(f.terms, t)
+	}
+	return f, nil
+}
+
+// String returns the expression the filter was parsed from.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// HasFields reports whether any term of the filter is for a specific field, i.e. whether the
+// expression is more than free text.
+func (f *Filter) HasFields() bool {
+	if f == nil {
+		return false
+	}
+	for _, t := range f.terms {
+		if t.field != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the device matches all terms of the filter.
+func (f *Filter) Match(d *discovery.Device) bool {
+	if f == nil {
+		return true
+	}
+	if d == nil {
+		return false
+	}
+	for i := range f.terms {
+		if !f.terms[i].match(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the devices matching the filter, in their original order.
+func (f *Filter) Apply(devices []*discovery.Device) []*discovery.Device {
+	if f == nil {
+		return devices
+	}
+	out := make([]*discovery.Device, 0, len(devices))
+	for _, d := range devices {
+		if f.Match(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// FieldFilter returns a filter with a single term, e.g. FieldFilter("vendor", "~", "apple").
+// Unlike with ParseFilter, the value is used as is and may contain whitespace and quotes.
+func FieldFilter(field, op, value string) (*Filter, error) {
+	if !slices.Contains(operators, op) {
+		return nil, fmt.Errorf("invalid filter operator %q", op)
+	}
+	tok := field + op + value
+	t, err := newTerm(field, op, value, tok)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: tok, terms: []term{t}}, nil
+}
+
+// And returns a filter matching the devices that match all filters, nil filters are skipped.
+// It returns nil when all filters are nil.
+func And(filters ...*Filter) *Filter {
+	var out *Filter
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if out == nil {
+			out = &Filter{expr: f.expr}
+		} else {
+			out.expr += " " + f.expr
+		}
+		out.terms = append(out.terms, f.terms...)
+	}
+	return out
+}
+
+func parseTerm(tok string) (term, error) {
+	field, op, value := splitTerm(tok)
+	if op == "" {
+		re, err := regexp.Compile("(?i)" + tok)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid filter %q: %w", tok, err)
+		}
+		return term{op: "~", value: tok, re: re}, nil
+	}
+	return newTerm(field, op, value, tok)
+}
+
+func newTerm(field, op, value, tok string) (term, error) {
+	field = strings.ToLower(field)
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	if _, ok := fields[field]; !ok {
+		return term{}, fmt.Errorf("unknown filter field %q, expected one of ip, name, mac, vendor, source, port, randomized", field)
+	}
+
+	t := term{field: field, op: op, value: value}
+	switch op {
+	case "~", "!~":
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid filter %q: %w", tok, err)
+		}
+		t.re = re
+	case "=", "!=":
+		if field == "ip" && strings.Contains(value, "/") {
+			_, subnet, err := net.ParseCIDR(value)
+			if err != nil {
+				return term{}, fmt.Errorf("invalid filter %q: %w", tok, err)
+			}
+			t.subnet = subnet
+		}
+	}
+	return t, nil
+}
+
+// splitTerm splits a term into field, operator and value at the first operator.
+// The operator is empty for free text terms.
+func splitTerm(tok string) (field, op, value string) {
+	idx := strings.IndexAny(tok, "=~!")
+	if idx <= 0 || !isFieldName(tok[:idx]) {
+		return "", "", tok
+	}
+	for _, candidate := range operators {
+		if strings.HasPrefix(tok[idx:], candidate) {
+			return tok[:idx], candidate, tok[idx+len(candidate):]
+		}
+	}
+	return "", "", tok
+}
+
+func isFieldName(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *term) match(d *discovery.Device) bool {
+	if t.field == "" {
+		for _, name := range searchFields {
+			if t.anyMatch(fields[name](d)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched := t.anyMatch(fields[t.field](d))
+	if strings.HasPrefix(t.op, "!") {
+		return !matched
+	}
+	return matched
+}
+
+// anyMatch reports whether any of the values matches the term, ignoring negation.
+func (t *term) anyMatch(values []string) bool {
+	for _, v := range values {
+		switch {
+		case t.re != nil:
+			if t.re.MatchString(v) {
+				return true
+			}
+		case t.subnet != nil:
+			if ip := net.ParseIP(v); ip != nil && t.subnet.Contains(ip) {
+				return true
+			}
+		default:
+			if strings.EqualFold(v, t.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tokenize splits the expression on whitespace, keeping double quoted sections together
+// and removing the quotes.
+func tokenize(expr string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if started {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid filter %q: unterminated quote", expr)
+	}
+	if started {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func testDevices() []*discovery.Device {
+	tv := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	tv.SetDisplayName("Living Room TV")
+	tv.SetMAC("28:6f:b9:00:11:22")
+	tv.SetManufacturer("Apple, Inc.")
+	tv.AddSource("mdns")
+	tv.SetOpenPorts(map[string][]int{"tcp": {7000, 22}})
+
+	printer := discovery.NewDevice(net.ParseIP("192.168.2.20"))
+	printer.SetDisplayName("printer")
+	printer.SetMAC("da:a1:19:00:11:22")
+	printer.SetManufacturer("HP")
+	printer.AddSource("ssdp")
+	printer.AddSource("arp")
+
+	return []*discovery.Device{tv, printer}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"", []string{"192.168.1.10", "192.168.2.20"}},
+		{"vendor~apple", []string{"192.168.1.10"}},
+		{"VENDOR=hp", []string{"192.168.2.20"}},
+		{"vendor!~apple", []string{"192.168.2.20"}},
+		{"source=mdns", []string{"192.168.1.10"}},
+		{"source!=mdns", []string{"192.168.2.20"}},
+		{"port=22", []string{"192.168.1.10"}},
+		{"port=80", nil},
+		{"ip=192.168.2.0/24", []string{"192.168.2.20"}},
+		{"ip!=192.168.2.0/24", []string{"192.168.1.10"}},
+		{"randomized=true", []string{"192.168.2.20"}},
+		{`name="living room tv"`, []string{"192.168.1.10"}},
+		{"print", []string{"192.168.2.20"}},
+		{"^192\\.168 source=arp", []string{"192.168.2.20"}},
+		{"source=arp port=22", nil},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) failed: %v", tt.expr, err)
+		}
+		var got []string
+		for _, d := range f.Apply(testDevices()) {
+			got = append(got, d.IP().String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseFilter(%q): expected %v, got %v", tt.expr, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseFilter(%q): expected %v, got %v", tt.expr, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{"color=red", "vendor~(", "[", "ip=10.0.0.0/99", `name="unterminated`} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if !f.Match(testDevices()[0]) {
+		t.Error("nil filter should match all devices")
+	}
+	if f.String() != "" {
+		t.Errorf("nil filter should have an empty expression, got %q", f.String())
+	}
+}
+
+func TestHasFields(t *testing.T) {
+	for expr, want := range map[string]bool{
+		"":                   false,
+		"apple":              false,
+		"apple tv":           false,
+		"vendor~apple":       true,
+		"tv source=mdns":     true,
+		`name="living room"`: true,
+	} {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) failed: %v", expr, err)
+		}
+		if got := f.HasFields(); got != want {
+			t.Errorf("HasFields(%q) = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestFieldFilterAndCombine(t *testing.T) {
+	name, err := FieldFilter("name", "~", "living room")
+	if err != nil {
+		t.Fatalf("FieldFilter failed: %v", err)
+	}
+	source, err := FieldFilter("source", "=", "mdns")
+	if err != nil {
+		t.Fatalf("FieldFilter failed: %v", err)
+	}
+
+	f := And(nil, name, source)
+	devices := f.Apply(testDevices())
+	if len(devices) != 1 || devices[0].IP().String() != "192.168.1.10" {
+		t.Fatalf("expected only the tv to match, got %v", devices)
+	}
+	if f.String() != "name~living room source=mdns" {
+		t.Errorf("unexpected expression %q", f.String())
+	}
+	if And(nil, nil) != nil {
+		t.Error("And of nil filters should be nil")
+	}
+
+	for _, args := range [][3]string{{"color", "=", "red"}, {"name", "~", "("}, {"name", "==", "x"}} {
+		if _, err := FieldFilter(args[0], args[1], args[2]); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
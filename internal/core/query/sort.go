@@ -0,0 +1,73 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// LessFunc reports whether device a sorts before device b.
+type LessFunc func(a, b *discovery.Device) bool
+
+// sortKeys are the supported sort orders, see ParseSort. Ties are broken by IP.
+var sortKeys = map[string]LessFunc{
+	"ip": byIP,
+	"name": func(a, b *discovery.Device) bool {
+		return compareStrings(a.DisplayName(), b.DisplayName(), a, b)
+	},
+	"mac": func(a, b *discovery.Device) bool {
+		return compareStrings(a.MAC(), b.MAC(), a, b)
+	},
+	"vendor": func(a, b *discovery.Device) bool {
+		return compareStrings(a.Manufacturer(), b.Manufacturer(), a, b)
+	},
+	"last_seen": func(a, b *discovery.Device) bool {
+		if !a.LastSeen().Equal(b.LastSeen()) {
+			return a.LastSeen().Before(b.LastSeen())
+		}
+		return byIP(a, b)
+	},
+}
+
+// SortKeys lists the names accepted by ParseSort.
+var SortKeys = []string{"ip", "name", "mac", "vendor", "last_seen"}
+
+// ParseSort returns the sort order for the given key (ip, name, mac, vendor or last_seen),
+// reversed when reverse is set. Empty values sort last for the string keys.
+func ParseSort(key string, reverse bool) (LessFunc, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		key = "ip"
+	}
+	if alias, ok := fieldAliases[key]; ok {
+		key = alias
+	}
+	less, ok := sortKeys[key]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort key %q, expected one of %s", key, strings.Join(SortKeys, ", "))
+	}
+	if reverse {
+		return func(a, b *discovery.Device) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+func byIP(a, b *discovery.Device) bool {
+	return discovery.CompareIPs(a.IP(), b.IP())
+}
+
+// compareStrings orders case-insensitively with empty values last, falling back to the IPs of a and b.
+func compareStrings(x, y string, a, b *discovery.Device) bool {
+	x, y = strings.ToLower(x), strings.ToLower(y)
+	switch {
+	case x == y:
+		return byIP(a, b)
+	case x == "":
+		return false
+	case y == "":
+		return true
+	default:
+		return x < y
+	}
+}
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestParseSort(t *testing.T) {
+	a := discovery.NewDevice(net.ParseIP("10.0.0.2"))
+	a.SetDisplayName("beta")
+	a.SetLastSeen(time.Unix(300, 0))
+
+	b := discovery.NewDevice(net.ParseIP("10.0.0.10"))
+	b.SetDisplayName("Alpha")
+	b.SetLastSeen(time.Unix(100, 0))
+
+	c := discovery.NewDevice(net.ParseIP("10.0.0.1"))
+	c.SetLastSeen(time.Unix(200, 0))
+
+	tests := []struct {
+		key     string
+		reverse bool
+		want    []string
+	}{
+		{"", false, []string{"10.0.0.1", "10.0.0.2", "10.0.0.10"}},
+		{"ip", true, []string{"10.0.0.10", "10.0.0.2", "10.0.0.1"}},
+		{"name", false, []string{"10.0.0.10", "10.0.0.2", "10.0.0.1"}},
+		{"last_seen", false, []string{"10.0.0.10", "10.0.0.1", "10.0.0.2"}},
+		{"last_seen", true, []string{"10.0.0.2", "10.0.0.1", "10.0.0.10"}},
+	}
+
+	for _, tt := range tests {
+		less, err := ParseSort(tt.key, tt.reverse)
+		if err != nil {
+			t.Fatalf("ParseSort(%q) failed: %v", tt.key, err)
+		}
+		devices := []*discovery.Device{a, b, c}
+		sort.Slice(devices, func(i, j int) bool { return less(devices[i], devices[j]) })
+		for i, d := range devices {
+			if d.IP().String() != tt.want[i] {
+				t.Errorf("ParseSort(%q, %v): expected %v, got %s at %d", tt.key, tt.reverse, tt.want, d.IP(), i)
+				break
+			}
+		}
+	}
+
+	if _, err := ParseSort("color", false); err == nil {
+		t.Error("expected error for unknown sort key")
+	}
+}
@@ -21,6 +21,7 @@ type ReadOnly interface {
 	FilterPattern() string
 	IsDiscovering() bool
 	IsPortscanning() bool
+	PortScanTargets() []string
 	Config() config.Config
 	GetDevice(ip string) (*discovery.Device, bool)
 	SearchActive() bool
@@ -41,6 +42,7 @@ type AppState struct {
 	filterPattern  string
 	isDiscovering  bool
 	isPortscanning bool
+	scanTargets    []string
 	cfg            *config.Config
 	searchError    bool
 	searchActive   bool
@@ -64,7 +66,9 @@ func NewAppState(cfg *config.Config, version string) *AppState {
 	return s
 }
 
-// UpsertDevice merges a device into the canonical device map.
+// UpsertDevice merges a device into the canonical device map. The manufacturer d carries
+// replaces the known one, it is looked up again for every scan, e.g. after the OUI overrides
+// changed.
 func (s *AppState) UpsertDevice(d *discovery.Device) {
 	if d.IP() == nil {
 		return
@@ -79,6 +83,9 @@ func (s *AppState) UpsertDevice(d *discovery.Device) {
 
 	if existing, ok := s.devices[key]; ok {
 		existing.Merge(d)
+		if vendor := d.Manufacturer(); vendor != "" {
+			existing.SetManufacturer(vendor)
+		}
 		s.devices[key] = existing
 	} else {
 		// Stores a copy to prevent race conditions between discovery engine and UI rendering
@@ -211,6 +218,20 @@ func (s *AppState) IsPortscanning() bool {
 	return s.isPortscanning
 }
 
+// SetPortScanTargets sets the IPs to port scan. An empty list means the selected device.
+func (s *AppState) SetPortScanTargets(ips []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanTargets = append([]string(nil), ips...)
+}
+
+// PortScanTargets returns a copy of the IPs to port scan, empty when the selected device should be scanned.
+func (s *AppState) PortScanTargets() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.scanTargets...)
+}
+
 // Config returns the port scanner configuration.
 func (s *AppState) Config() config.Config {
 	s.mu.RLock()
@@ -42,6 +42,30 @@ func TestUpsertDevice(t *testing.T) {
 	}
 }
 
+func TestUpsertDeviceManufacturer(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	ip := net.ParseIP("192.168.1.1")
+	device := discovery.NewDevice(ip)
+	device.SetMAC("00:1a:2b:3c:4d:5e")
+	device.SetManufacturer("Old Vendor")
+	state.UpsertDevice(device)
+
+	// e.g. the OUI overrides changed since the device was discovered
+	rescanned := discovery.NewDevice(ip)
+	rescanned.SetMAC("00:1a:2b:3c:4d:5e")
+	rescanned.SetManufacturer("Lab Switch")
+	state.UpsertDevice(rescanned)
+	if got, _ := state.GetDevice("192.168.1.1"); got.Manufacturer() != "Lab Switch" {
+		t.Errorf("expected the manufacturer to be replaced, got %q", got.Manufacturer())
+	}
+
+	state.UpsertDevice(discovery.NewDevice(ip))
+	if got, _ := state.GetDevice("192.168.1.1"); got.Manufacturer() != "Lab Switch" {
+		t.Errorf("expected the manufacturer to be kept, got %q", got.Manufacturer())
+	}
+}
+
 func TestDevicesSnapshot(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
@@ -149,6 +173,23 @@ func TestIsPortscanning(t *testing.T) {
 	}
 }
 
+func TestPortScanTargets(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	if len(state.PortScanTargets()) != 0 {
+		t.Errorf("expected no port scan targets by default")
+	}
+
+	ips := []string{"192.168.1.1", "192.168.1.2"}
+	state.SetPortScanTargets(ips)
+	ips[0] = "mutated"
+
+	targets := state.PortScanTargets()
+	if len(targets) != 2 || targets[0] != "192.168.1.1" {
+		t.Errorf("expected targets to be copied, got %v", targets)
+	}
+}
+
 func TestGetDevice(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
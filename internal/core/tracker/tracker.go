@@ -0,0 +1,215 @@
+// Package tracker follows devices across continuous scans and reports when devices
+// appear, change, go offline or come back online.
+package tracker
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// ChangeType describes what happened to a device.
+type ChangeType string
+
+const (
+	// ChangeNew is reported the first time a device is seen.
+	ChangeNew ChangeType = "new"
+	// ChangeUpdated is reported when the name, MAC, vendor or sources of a device changed.
+	ChangeUpdated ChangeType = "changed"
+	// ChangeOffline is reported when a device was not seen for the offline threshold.
+	ChangeOffline ChangeType = "offline"
+	// ChangeOnline is reported when an offline device is seen again.
+	ChangeOnline ChangeType = "online"
+)
+
+// FieldChange is a single changed field of a device.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change is reported by the Tracker for every device that appeared, changed, went offline
+// or came back online.
+type Change struct {
+	Time   time.Time         `json:"time"`
+	Type   ChangeType        `json:"event"`
+	Device *discovery.Device `json:"device"`
+	// Fields lists the changed fields for ChangeUpdated, and ChangeOnline when fields changed while offline.
+	Fields []FieldChange `json:"changes,omitempty"`
+}
+
+type entry struct {
+	device   *discovery.Device
+	lastSeen time.Time
+	offline  bool
+}
+
+// Tracker keeps the last known state of all devices, keyed by IP. It is safe for concurrent use.
+type Tracker struct {
+	mu           sync.Mutex
+	devices      map[string]*entry
+	offlineAfter time.Duration
+}
+
+// New returns a tracker that considers devices offline when they were not observed for offlineAfter.
+func New(offlineAfter time.Duration) *Tracker {
+	return &Tracker{
+		devices:      make(map[string]*entry),
+		offlineAfter: offlineAfter,
+	}
+}
+
+// Observe records that d was seen at now and returns the resulting change,
+// or nil when the device is already known and nothing changed.
+func (t *Tracker) Observe(d *discovery.Device, now time.Time) *Change {
+	if d == nil || d.IP() == nil {
+		return nil
+	}
+	key := d.IP().String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.devices[key]
+	if !ok {
+		e = &entry{device: d.Copy(), lastSeen: now}
+		t.devices[key] = e
+		return &Change{Time: now, Type: ChangeNew, Device: e.device.Copy()}
+	}
+
+	prev := e.device
+	e.device = observe(prev, d)
+	e.lastSeen = now
+	fields := diff(prev, e.device)
+
+	if e.offline {
+		e.offline = false
+		return &Change{Time: now, Type: ChangeOnline, Device: e.device.Copy(), Fields: fields}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Change{Time: now, Type: ChangeUpdated, Device: e.device.Copy(), Fields: fields}
+}
+
+// Replace overwrites the known state of a device instead of merging d into it, e.g. after
+// the user renamed it, and returns the resulting change. It returns nil when the device is
+// unknown or nothing changed.
+func (t *Tracker) Replace(d *discovery.Device, now time.Time) *Change {
+	if d == nil || d.IP() == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.devices[d.IP().String()]
+	if !ok {
+		return nil
+	}
+	prev := e.device
+	e.device = d.Copy()
+	fields := diff(prev, e.device)
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Change{Time: now, Type: ChangeUpdated, Device: e.device.Copy(), Fields: fields}
+}
+
+// Sweep marks the devices that were not observed for the offline threshold as offline
+// and returns a change for each of them, ordered by IP.
+func (t *Tracker) Sweep(now time.Time) []Change {
+	if t.offlineAfter <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changes []Change
+	for _, e := range t.devices {
+		if e.offline || now.Sub(e.lastSeen) < t.offlineAfter {
+			continue
+		}
+		e.offline = true
+		changes = append(changes, Change{Time: now, Type: ChangeOffline, Device: e.device.Copy()})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return discovery.CompareIPs(changes[i].Device.IP(), changes[j].Device.IP())
+	})
+	return changes
+}
+
+// Online returns the number of devices that are currently considered online.
+func (t *Tracker) Online() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, e := range t.devices {
+		if !e.offline {
+			n++
+		}
+	}
+	return n
+}
+
+// Offline reports whether the device with the given IP is currently considered offline.
+func (t *Tracker) Offline(ip net.IP) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.devices[ip.String()]
+	return ok && e.offline
+}
+
+// observe returns the state of a known device after observation d. The name and vendor that
+// d carries replace the known ones, as does its MAC when it identifies a different device
+// (see discovery.Device.IdentityKey), so a renamed device or a different device taking over
+// the IP is reported as changed. Fields d does not carry are kept, and sources, ports and
+// other data are merged.
+func observe(prev, d *discovery.Device) *discovery.Device {
+	cur := prev.Copy()
+	cur.Merge(d)
+	if name := d.DisplayName(); name != "" {
+		cur.SetDisplayName(name)
+	}
+	if mac := d.MAC(); mac != "" && d.IdentityKey() != prev.IdentityKey() {
+		// the vendor of the old MAC does not apply to the new one
+		cur.SetMAC(mac)
+		cur.SetManufacturer(d.Manufacturer())
+	}
+	if vendor := d.Manufacturer(); vendor != "" {
+		cur.SetManufacturer(vendor)
+	}
+	return cur
+}
+
+// diff returns the fields that differ between the previous and current state of a device.
+func diff(prev, cur *discovery.Device) []FieldChange {
+	var fields []FieldChange
+	add := func(field, before, after string) {
+		if before != after {
+			fields = append(fields, FieldChange{Field: field, Old: before, New: after})
+		}
+	}
+	add("name", prev.DisplayName(), cur.DisplayName())
+	add("mac", prev.MAC(), cur.MAC())
+	add("vendor", prev.Manufacturer(), cur.Manufacturer())
+	add("sources", joinSources(prev), joinSources(cur))
+	return fields
+}
+
+func joinSources(d *discovery.Device) string {
+	sources := make([]string, 0, len(d.Sources()))
+	for s := range d.Sources() {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	return strings.Join(sources, ",")
+}
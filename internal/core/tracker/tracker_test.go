@@ -0,0 +1,153 @@
+package tracker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func device(ip, name string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetDisplayName(name)
+	d.AddSource("arp")
+	return d
+}
+
+func TestTrackerObserve(t *testing.T) {
+	tr := New(time.Minute)
+	now := time.Unix(1000, 0)
+
+	change := tr.Observe(device("10.0.0.1", "nas"), now)
+	if change == nil || change.Type != ChangeNew {
+		t.Fatalf("expected new device, got %+v", change)
+	}
+
+	if change := tr.Observe(device("10.0.0.1", "nas"), now.Add(time.Second)); change != nil {
+		t.Fatalf("expected no change for unchanged device, got %+v", change)
+	}
+
+	renamed := device("10.0.0.1", "nas.lan")
+	renamed.AddSource("mdns")
+	change = tr.Observe(renamed, now.Add(2*time.Second))
+	if change == nil || change.Type != ChangeUpdated {
+		t.Fatalf("expected changed device, got %+v", change)
+	}
+	want := []FieldChange{{Field: "name", Old: "nas", New: "nas.lan"}, {Field: "sources", Old: "arp", New: "arp,mdns"}}
+	if len(change.Fields) != 2 || change.Fields[0] != want[0] || change.Fields[1] != want[1] {
+		t.Fatalf("expected name and sources change, got %+v", change.Fields)
+	}
+
+	// an observation without a name keeps the known one
+	if change := tr.Observe(device("10.0.0.1", ""), now.Add(3*time.Second)); change != nil {
+		t.Fatalf("expected no change for an observation without a name, got %+v", change)
+	}
+
+	if tr.Observe(nil, now) != nil {
+		t.Fatal("expected nil change for nil device")
+	}
+}
+
+func TestTrackerOfflineAndOnline(t *testing.T) {
+	tr := New(time.Minute)
+	now := time.Unix(1000, 0)
+
+	tr.Observe(device("10.0.0.2", "b"), now)
+	tr.Observe(device("10.0.0.1", "a"), now.Add(30*time.Second))
+
+	if changes := tr.Sweep(now.Add(59 * time.Second)); len(changes) != 0 {
+		t.Fatalf("expected no offline devices yet, got %+v", changes)
+	}
+
+	changes := tr.Sweep(now.Add(2 * time.Minute))
+	if len(changes) != 2 || changes[0].Device.IP().String() != "10.0.0.1" || changes[0].Type != ChangeOffline {
+		t.Fatalf("expected both devices offline ordered by IP, got %+v", changes)
+	}
+	if !tr.Offline(net.ParseIP("10.0.0.1")) || tr.Online() != 0 {
+		t.Fatal("expected devices to be offline")
+	}
+	if changes := tr.Sweep(now.Add(3 * time.Minute)); len(changes) != 0 {
+		t.Fatalf("offline devices should only be reported once, got %+v", changes)
+	}
+
+	change := tr.Observe(device("10.0.0.1", "a"), now.Add(4*time.Minute))
+	if change == nil || change.Type != ChangeOnline {
+		t.Fatalf("expected device back online, got %+v", change)
+	}
+	if tr.Online() != 1 {
+		t.Fatalf("expected 1 online device, got %d", tr.Online())
+	}
+}
+
+func TestTrackerWithoutOfflineThreshold(t *testing.T) {
+	tr := New(0)
+	tr.Observe(device("10.0.0.1", "a"), time.Unix(0, 0))
+	if changes := tr.Sweep(time.Unix(1_000_000, 0)); changes != nil {
+		t.Fatalf("expected no offline detection, got %+v", changes)
+	}
+}
+
+func TestTrackerReplace(t *testing.T) {
+	tr := New(time.Minute)
+	now := time.Unix(1000, 0)
+
+	if change := tr.Replace(device("10.0.0.1", "nas"), now); change != nil {
+		t.Fatalf("expected no change for unknown device, got %+v", change)
+	}
+
+	tr.Observe(device("10.0.0.1", "nas"), now)
+	change := tr.Replace(device("10.0.0.1", "storage"), now)
+	if change == nil || change.Type != ChangeUpdated {
+		t.Fatalf("expected changed device, got %+v", change)
+	}
+	if len(change.Fields) != 1 || change.Fields[0] != (FieldChange{Field: "name", Old: "nas", New: "storage"}) {
+		t.Fatalf("expected name change, got %+v", change.Fields)
+	}
+
+	// observations carry the alias, see aliases.Store.Apply
+	if change := tr.Observe(device("10.0.0.1", "storage"), now.Add(time.Second)); change != nil {
+		t.Fatalf("expected replaced name to be kept, got %+v", change)
+	}
+}
+
+func TestTrackerHostnameVendorAndMACChanges(t *testing.T) {
+	tr := New(time.Minute)
+	now := time.Unix(1000, 0)
+
+	d := device("10.0.0.1", "laptop")
+	d.SetMAC("00:11:22:33:44:55")
+	d.SetManufacturer("Dell")
+	tr.Observe(d, now)
+
+	renamed := device("10.0.0.1", "laptop-2")
+	change := tr.Observe(renamed, now.Add(time.Second))
+	if change == nil || len(change.Fields) != 1 || change.Fields[0] != (FieldChange{Field: "name", Old: "laptop", New: "laptop-2"}) {
+		t.Fatalf("expected hostname change, got %+v", change)
+	}
+	if change.Device.MAC() != "00:11:22:33:44:55" || change.Device.Manufacturer() != "Dell" {
+		t.Fatalf("expected MAC and vendor to be kept, got %+v", change.Device)
+	}
+
+	other := device("10.0.0.1", "laptop-2")
+	other.SetMAC("00:aa:bb:cc:dd:ee")
+	other.SetManufacturer("Apple")
+	change = tr.Observe(other, now.Add(2*time.Second))
+	if change == nil || len(change.Fields) != 2 {
+		t.Fatalf("expected MAC and vendor change, got %+v", change)
+	}
+	if change.Fields[0] != (FieldChange{Field: "mac", Old: "00:11:22:33:44:55", New: "00:aa:bb:cc:dd:ee"}) ||
+		change.Fields[1] != (FieldChange{Field: "vendor", Old: "Dell", New: "Apple"}) {
+		t.Fatalf("unexpected changes %+v", change.Fields)
+	}
+
+	// a randomized MAC identifies the device by its IP, a new one is the same device
+	phone := device("10.0.0.2", "phone")
+	phone.SetMAC("da:a1:19:00:00:01")
+	tr.Observe(phone, now)
+	rotated := device("10.0.0.2", "phone")
+	rotated.SetMAC("da:a1:19:00:00:02")
+	if change := tr.Observe(rotated, now.Add(time.Second)); change != nil {
+		t.Fatalf("expected no change for a rotated randomized MAC, got %+v", change)
+	}
+}
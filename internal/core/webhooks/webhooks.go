@@ -0,0 +1,367 @@
+// Package webhooks delivers events to HTTP endpoints, e.g. to be alerted in chat when a new
+// device joins the network.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body as "sha256=<hex>" when a secret is set.
+	SignatureHeader = "X-Whosthere-Signature"
+	// EventHeader carries the event type.
+	EventHeader = "X-Whosthere-Event"
+	// DeliveryHeader carries the delivery ID, which is the same for all attempts.
+	DeliveryHeader = "X-Whosthere-Delivery"
+
+	// DefaultLogSize is the number of deliveries kept in the delivery log.
+	DefaultLogSize = 100
+	// DefaultBackoff is the wait before the first retry, it doubles with every retry.
+	DefaultBackoff = time.Second
+
+	// queueSize is the number of pending events per webhook, events beyond it are dropped.
+	queueSize = 64
+)
+
+// Delivery is the outcome of sending an event to a webhook.
+type Delivery struct {
+	ID       uint64      `json:"id"`
+	URL      string      `json:"url"`
+	Event    events.Type `json:"event"`
+	EventID  uint64      `json:"eventId"`
+	Time     time.Time   `json:"time"`
+	Attempts int         `json:"attempts"`
+	// StatusCode is the HTTP status of the last attempt, 0 when no response was received.
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK reports whether the event was delivered.
+func (d Delivery) OK() bool {
+	return d.Error == ""
+}
+
+// Option configures a Notifier.
+type Option func(*Notifier)
+
+// WithClient sets the HTTP client used for deliveries, the timeout of each webhook still applies.
+func WithClient(c *http.Client) Option {
+	return func(n *Notifier) { n.client = c }
+}
+
+// WithBackoff sets the wait before the first retry.
+func WithBackoff(d time.Duration) Option {
+	return func(n *Notifier) { n.backoff = d }
+}
+
+// WithLogSize sets the number of deliveries kept in the delivery log.
+func WithLogSize(size int) Option {
+	return func(n *Notifier) { n.logSize = size }
+}
+
+// Notifier sends events to the configured webhooks. Every webhook has its own queue, so a
+// slow or failing endpoint does not hold up the others.
+type Notifier struct {
+	hooks   []*hook
+	client  *http.Client
+	backoff time.Duration
+	logSize int
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	drain     chan struct{}
+	drainOnce sync.Once
+
+	mu     sync.Mutex
+	nextID uint64
+	log    []Delivery
+}
+
+type hook struct {
+	cfg      config.WebhookConfig
+	retries  int
+	types    map[events.Type]bool
+	filter   *query.Filter
+	template *template.Template
+	queue    chan events.Message
+}
+
+// New validates the webhooks and starts delivering the events passed to Notify.
+func New(cfgs []config.WebhookConfig, opts ...Option) (*Notifier, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		client:  &http.Client{},
+		backoff: DefaultBackoff,
+		logSize: DefaultLogSize,
+		ctx:     ctx,
+		cancel:  cancel,
+		drain:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	for i, cfg := range cfgs {
+		h, err := newHook(cfg)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("webhook %d: %w", i, err)
+		}
+		n.hooks = append(n.hooks, h)
+	}
+	for _, h := range n.hooks {
+		n.wg.Add(1)
+		go n.run(h)
+	}
+	return n, nil
+}
+
+func newHook(cfg config.WebhookConfig) (*hook, error) {
+	if len(cfg.Events) == 0 {
+		cfg.Events = []string{string(events.TypeDeviceDiscovered)}
+	}
+	types, err := events.ParseTypes(strings.Join(cfg.Events, ","))
+	if err != nil {
+		return nil, err
+	}
+	filter, err := query.ParseFilter(cfg.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hook{cfg: cfg, types: make(map[events.Type]bool), filter: filter, queue: make(chan events.Message, queueSize)}
+	for _, t := range types {
+		h.types[t] = true
+	}
+	h.retries = config.DefaultWebhookRetries
+	if cfg.Retries != nil {
+		h.retries = *cfg.Retries
+	}
+	if cfg.Template != "" {
+		h.template, err = template.New(cfg.URL).Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse template: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// toJSON is available as "json" in templates, e.g. {"text": {{json .Device.DisplayName}}}.
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Types returns the event types any of the webhooks is interested in.
+func (n *Notifier) Types() []events.Type {
+	var types []events.Type
+	for _, t := range events.Types {
+		for _, h := range n.hooks {
+			if h.types[t] {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+// Notify queues msg for the webhooks interested in it without blocking. When the queue of a
+// webhook is full the event is dropped and recorded as a failed delivery.
+func (n *Notifier) Notify(msg events.Message) {
+	for _, h := range n.hooks {
+		if !h.types[msg.Type] || (msg.Device != nil && !h.filter.Match(msg.Device)) {
+			continue
+		}
+		select {
+		case h.queue <- msg:
+		default:
+			n.record(Delivery{URL: h.cfg.URL, Event: msg.Type, EventID: msg.ID, Time: time.Now(), Error: "queue full, event dropped"})
+		}
+	}
+}
+
+// Deliveries returns the most recent deliveries, newest first.
+func (n *Notifier) Deliveries() []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	out := make([]Delivery, len(n.log))
+	for i, d := range n.log {
+		out[len(n.log)-1-i] = d
+	}
+	return out
+}
+
+// Close stops delivering, pending retries are abandoned.
+func (n *Notifier) Close() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+// Shutdown delivers the queued events, including their retries, and stops. When ctx is done
+// first the remaining deliveries are abandoned like with Close. Events passed to Notify
+// afterwards are not delivered.
+func (n *Notifier) Shutdown(ctx context.Context) {
+	n.drainOnce.Do(func() { close(n.drain) })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	n.Close()
+}
+
+func (n *Notifier) run(h *hook) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case msg := <-h.queue:
+			n.record(n.deliver(h, msg))
+		case <-n.drain:
+			for {
+				select {
+				case msg := <-h.queue:
+					if n.ctx.Err() != nil {
+						return
+					}
+					n.record(n.deliver(h, msg))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends msg to the webhook, retrying network errors, 429 and 5xx responses.
+func (n *Notifier) deliver(h *hook, msg events.Message) Delivery {
+	n.mu.Lock()
+	n.nextID++
+	d := Delivery{ID: n.nextID, URL: h.cfg.URL, Event: msg.Type, EventID: msg.ID, Time: time.Now()}
+	n.mu.Unlock()
+
+	body, err := h.render(msg)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	backoff := n.backoff
+	for {
+		d.Attempts++
+		var retry bool
+		d.StatusCode, retry, err = n.send(h, d.ID, msg.Type, body)
+		if err == nil {
+			d.Error = ""
+			return d
+		}
+		d.Error = err.Error()
+		if !retry || d.Attempts > h.retries {
+			return d
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return d
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) send(h *hook, id uint64, typ events.Type, body []byte) (status int, retry bool, err error) {
+	ctx := n.ctx
+	if h.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "whosthere")
+	req.Header.Set(EventHeader, string(typ))
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(id, 10))
+	if h.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.cfg.Secret, body))
+	}
+	for k, v := range h.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return resp.StatusCode, retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// render returns the request body of msg, the template output or the JSON event.
+func (h *hook) render(msg events.Message) ([]byte, error) {
+	if h.template == nil {
+		return json.Marshal(msg)
+	}
+	var buf bytes.Buffer
+	if err := h.template.Execute(&buf, msg); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// record appends d to the delivery log, dropping the oldest delivery when it is full.
+func (n *Notifier) record(d Delivery) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if d.ID == 0 {
+		n.nextID++
+		d.ID = n.nextID
+	}
+	n.log = append(n.log, d)
+	if over := len(n.log) - n.logSize; over > 0 {
+		n.log = append(n.log[:0], n.log[over:]...)
+	}
+}
+
+// Sign returns the signature of body for the SignatureHeader: "sha256=" followed by the hex
+// encoded HMAC-SHA256 of body with the secret as key.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
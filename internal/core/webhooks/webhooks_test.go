@@ -0,0 +1,251 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/events"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a webhook endpoint answering with the queued status codes, 200 when empty.
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rec *recorder) count() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.requests)
+}
+
+func newDevice(ip, name string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetDisplayName(name)
+	return d
+}
+
+func waitDeliveries(t *testing.T, n *Notifier, count int) []Delivery {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(n.Deliveries()) >= count }, 5*time.Second, 5*time.Millisecond)
+	return n.Deliveries()
+}
+
+func TestNotifier_DeliversSignedJSON(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{
+		URL:     srv.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"X-Team": "infra"},
+	}})
+	require.NoError(t, err)
+	defer n.Close()
+	assert.Equal(t, []events.Type{events.TypeDeviceDiscovered}, n.Types())
+
+	n.Notify(events.Message{ID: 7, Type: events.TypeDeviceOffline, Device: newDevice("10.0.0.1", "tv")})
+	n.Notify(events.Message{ID: 8, Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.2", "phone")})
+
+	deliveries := waitDeliveries(t, n, 1)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].OK())
+	assert.Equal(t, uint64(8), deliveries[0].EventID)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+
+	require.Equal(t, 1, rec.count())
+	req, body := rec.requests[0], rec.bodies[0]
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "device_discovered", req.Header.Get(EventHeader))
+	assert.Equal(t, "infra", req.Header.Get("X-Team"))
+	assert.Equal(t, Sign("s3cret", body), req.Header.Get(SignatureHeader))
+
+	var msg events.Message
+	require.NoError(t, json.Unmarshal(body, &msg))
+	assert.Equal(t, "phone", msg.Device.DisplayName())
+}
+
+func TestNotifier_TemplateAndFilter(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{
+		URL:      srv.URL,
+		Events:   []string{"device_discovered", "ports_changed"},
+		Filter:   "name~^un",
+		Template: `{"text": {{json (printf "New device %s (%s)" .Device.DisplayName .Device.IP)}}}`,
+	}})
+	require.NoError(t, err)
+	defer n.Close()
+
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.1", "tv")})
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.2", `unknown "x"`)})
+
+	waitDeliveries(t, n, 1)
+	require.Equal(t, 1, rec.count())
+	assert.JSONEq(t, `{"text": "New device unknown \"x\" (10.0.0.2)"}`, string(rec.bodies[0]))
+}
+
+func TestNotifier_RetriesWithBackoff(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL, Retries: retries(3)}}, WithBackoff(time.Millisecond))
+	require.NoError(t, err)
+	defer n.Close()
+
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.1", "tv")})
+
+	d := waitDeliveries(t, n, 1)[0]
+	assert.True(t, d.OK())
+	assert.Equal(t, 3, d.Attempts)
+	assert.Equal(t, 3, rec.count())
+	// all attempts of a delivery share its ID
+	assert.Equal(t, rec.requests[0].Header.Get(DeliveryHeader), rec.requests[2].Header.Get(DeliveryHeader))
+}
+
+func retries(n int) *int { return &n }
+
+func TestNotifier_GivesUp(t *testing.T) {
+	rec := &recorder{statuses: []int{500, 500, 500, 500, http.StatusBadRequest}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL, Retries: retries(2)}}, WithBackoff(time.Millisecond))
+	require.NoError(t, err)
+	defer n.Close()
+
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.1", "tv")})
+	d := waitDeliveries(t, n, 1)[0]
+	assert.False(t, d.OK())
+	assert.Equal(t, 3, d.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, d.StatusCode)
+	assert.Contains(t, d.Error, "500")
+
+	// client errors are not retried
+	rec.mu.Lock()
+	rec.statuses = []int{http.StatusBadRequest}
+	rec.mu.Unlock()
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.2", "tv")})
+	d = waitDeliveries(t, n, 2)[0]
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusBadRequest, d.StatusCode)
+}
+
+func TestNotifier_RetriesDisabled(t *testing.T) {
+	rec := &recorder{statuses: []int{500, 500}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL, Retries: retries(0)}}, WithBackoff(time.Millisecond))
+	require.NoError(t, err)
+	defer n.Close()
+
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.1", "tv")})
+	d := waitDeliveries(t, n, 1)[0]
+	assert.False(t, d.OK())
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, 1, rec.count())
+}
+
+func TestNotifier_ShutdownDeliversQueued(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL}}, WithBackoff(time.Millisecond))
+	require.NoError(t, err)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice(ip, "tv")})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n.Shutdown(ctx)
+	assert.Len(t, n.Deliveries(), 3)
+	assert.Equal(t, 4, rec.count(), "the failed delivery is retried")
+}
+
+func TestNotifier_ShutdownDeadline(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-block }))
+	defer srv.Close()
+	defer close(block)
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL, Timeout: time.Minute}})
+	require.NoError(t, err)
+	n.Notify(events.Message{Type: events.TypeDeviceDiscovered, Device: newDevice("10.0.0.1", "tv")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	n.Shutdown(ctx)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestNotifier_DeliveryLogSize(t *testing.T) {
+	srv := httptest.NewServer(&recorder{})
+	defer srv.Close()
+
+	n, err := New([]config.WebhookConfig{{URL: srv.URL}}, WithLogSize(2))
+	require.NoError(t, err)
+	defer n.Close()
+
+	for i := 1; i <= 3; i++ {
+		n.Notify(events.Message{ID: uint64(i), Type: events.TypeDeviceDiscovered})
+		waitDeliveries(t, n, min(i, 2))
+		require.Eventually(t, func() bool { return n.Deliveries()[0].EventID == uint64(i) }, 5*time.Second, 5*time.Millisecond)
+	}
+	deliveries := n.Deliveries()
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, []uint64{3, 2}, []uint64{deliveries[0].EventID, deliveries[1].EventID})
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, cfg := range map[string]config.WebhookConfig{
+		"event type": {URL: "http://localhost", Events: []string{"bogus"}},
+		"filter":     {URL: "http://localhost", Filter: "color=red"},
+		"template":   {URL: "http://localhost", Template: "{{.Device"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New([]config.WebhookConfig{cfg})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494", Sign("secret", []byte(`{"a":1}`)))
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
@@ -74,8 +75,7 @@ func NewApp(cfg *config.Config, logger *slog.Logger, version string) (*App, erro
 		return nil, fmt.Errorf("build engine: %w", err)
 	}
 	a.engine = engine
-	// todo(ramon) handle in BuildEngine -> WithPortScanner(...)
-	a.portScanner = discovery.NewPortScanner(100, engine.Iface)
+	a.portScanner = core.BuildPortScanner(a.cfg, engine.Iface)
 
 	app.SetRoot(a.pages, true)
 	app.SetInputCapture(a.handleGlobalKeys)
@@ -199,6 +199,11 @@ func (a *App) handleEngineEvents() {
 			if event.Device != nil {
 				a.state.UpsertDevice(event.Device)
 			}
+		case discovery.EventPortsChanged:
+			if event.Device != nil && event.Changes != nil {
+				a.logger.Info("ports changed", "ip", event.Device.IP().String(),
+					"opened", event.Changes.Opened, "closed", event.Changes.Closed)
+			}
 		case discovery.EventError:
 			a.emit(events.DiscoveryStopped{})
 			if event.Error != nil {
@@ -299,6 +304,8 @@ func (a *App) handleEvents() {
 			a.state.SetIsDiscovering(true)
 		case events.DiscoveryStopped:
 			a.state.SetIsDiscovering(false)
+		case events.PortScanTargetsSelected:
+			a.state.SetPortScanTargets(event.IPs)
 		case events.PortScanStarted:
 			a.state.SetIsPortscanning(true)
 			a.emit(events.HideView{})
@@ -347,26 +354,63 @@ func (a *App) handleEvents() {
 }
 
 func (a *App) startPortscan() {
-	device, ok := a.state.Selected()
-	if !ok {
-		a.emit(events.PortScanStopped{})
-		return
+	targets := a.state.PortScanTargets()
+	if len(targets) == 0 {
+		device, ok := a.state.Selected()
+		if !ok {
+			a.emit(events.PortScanStopped{})
+			return
+		}
+		targets = []string{device.IP().String()}
+	}
+
+	devices := make(map[string]*discovery.Device, len(targets))
+	openPorts := make(map[string]map[string][]int, len(targets))
+	for _, ip := range targets {
+		device, ok := a.state.GetDevice(ip)
+		if !ok {
+			continue
+		}
+		devices[ip] = device
+		openPorts[ip] = make(map[string][]int)
+		device.SetOpenPorts(openPorts[ip])
+		device.SetLastPortScan(time.Now())
 	}
-	ip := device.IP().String()
-	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ScanTimeout)
-	defer cancel()
 
-	openPorts := make(map[string][]int)
-	device.SetOpenPorts(openPorts)
-	device.SetLastPortScan(time.Now())
+	// every connection can take the port scan timeout, spread over the workers, which are
+	// limited to host_concurrency connections per host
+	ports := a.cfg.PortScanner.TCP
+	workers := max(a.cfg.PortScanner.Workers, 1)
+	if hc := a.cfg.PortScanner.HostConcurrency; hc > 0 {
+		workers = min(workers, hc*len(targets))
+	}
+	total := a.cfg.PortScanner.Timeout * time.Duration(len(ports)*len(targets)/workers+1)
+	ctx, cancel := context.WithTimeout(context.Background(), total)
+	defer cancel()
 
 	var mu sync.Mutex
-	_ = a.portScanner.Stream(ctx, ip, a.cfg.PortScanner.TCP, a.cfg.PortScanner.Timeout, func(port int) {
+	err := a.portScanner.ScanHosts(ctx, targets, ports, a.cfg.PortScanner.Timeout, func(ip string, port int) {
 		mu.Lock()
 		defer mu.Unlock()
-		openPorts["tcp"] = append(openPorts["tcp"], port)
+		if ports, ok := openPorts[ip]; ok {
+			ports["tcp"] = append(ports["tcp"], port)
+		}
 	})
 
-	device.SetOpenPorts(openPorts)
+	mu.Lock()
+	for ip, device := range devices {
+		sort.Ints(openPorts[ip]["tcp"])
+		if err != nil {
+			// partial results of an interrupted scan are not recorded in the history,
+			// they would show up as falsely closed ports
+			device.SetOpenPorts(openPorts[ip])
+			continue
+		}
+		a.engine.RecordPortScan(device, openPorts[ip])
+	}
+	mu.Unlock()
+	if err != nil {
+		a.logger.Warn("port scan interrupted", "error", err)
+	}
 	a.emit(events.PortScanStopped{})
 }
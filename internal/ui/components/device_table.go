@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/query"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/internal/ui/utils"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
@@ -21,6 +23,7 @@ var _ UIComponent = &DeviceTable{}
 type DeviceTable struct {
 	*tview.Table
 	devices     []*discovery.Device
+	filter      *query.Filter
 	filterRE    *regexp.Regexp
 	searching   bool
 	searchInput string
@@ -58,7 +61,7 @@ func (dt *DeviceTable) HandleInput(ev *tcell.EventKey) *tcell.EventKey {
 func (dt *DeviceTable) handleNormalKey(ev *tcell.EventKey) *tcell.EventKey {
 	switch {
 	case ev.Key() == tcell.KeyEsc:
-		if dt.filterRE != nil {
+		if dt.filter != nil || dt.filterRE != nil {
 			dt.applySearch("")
 			return nil
 		}
@@ -86,6 +89,13 @@ func (dt *DeviceTable) handleNormalKey(ev *tcell.EventKey) *tcell.EventKey {
 			dt.emit(events.CopyMac{MAC: mac})
 		}
 		return nil
+	case ev.Rune() == 'P':
+		ips := dt.VisibleIPs()
+		if len(ips) > 0 {
+			dt.emit(events.PortScanTargetsSelected{IPs: ips})
+			dt.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
+		}
+		return nil
 	default:
 		return ev
 	}
@@ -124,19 +134,25 @@ func (dt *DeviceTable) handleSearchKey(ev *tcell.EventKey) *tcell.EventKey {
 	return nil
 }
 
-// SetFilter compiles and applies a regex filter across visible columns (case-insensitive).
+// SetFilter applies a filter expression (see query.ParseFilter) when the pattern contains
+// a valid field term, e.g. vendor~apple. Other patterns are matched as a whole as a
+// case-insensitive regex against all visible columns.
 func (dt *DeviceTable) SetFilter(pattern string) error {
 	pattern = strings.TrimSpace(pattern)
-	if pattern == "" {
-		dt.filterRE = nil
+	if filter, err := query.ParseFilter(pattern); err == nil && filter.HasFields() {
+		dt.filter, dt.filterRE = filter, nil
 		dt.refresh()
 		return nil
 	}
-	re, err := regexp.Compile("(?i)" + pattern)
-	if err != nil {
-		return err
+
+	dt.filter, dt.filterRE = nil, nil
+	if pattern != "" {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return err
+		}
+		dt.filterRE = re
 	}
-	dt.filterRE = re
 	dt.refresh()
 	return nil
 }
@@ -188,6 +204,18 @@ func (dt *DeviceTable) SelectedMAC() string {
 	return cell.Text
 }
 
+// VisibleIPs returns the IPs of all rows currently shown, i.e. the devices matching the active filter.
+func (dt *DeviceTable) VisibleIPs() []string {
+	rows := dt.GetRowCount()
+	ips := make([]string, 0, rows)
+	for row := 1; row < rows; row++ {
+		if cell := dt.GetCell(row, 0); cell != nil && cell.Text != "" {
+			ips = append(ips, cell.Text)
+		}
+	}
+	return ips
+}
+
 // SelectFirst selects the first data row below the header, if any.
 func (dt *DeviceTable) SelectFirst() {
 	if dt.GetRowCount() > 1 {
@@ -209,7 +237,7 @@ type tableRow struct {
 
 func (dt *DeviceTable) buildRows() []tableRow {
 	rows := make([]tableRow, 0, len(dt.devices))
-	for _, d := range dt.devices {
+	for _, d := range dt.filter.Apply(dt.devices) {
 		row := tableRow{
 			ip:           d.IP().String(),
 			hostname:     d.DisplayName(),
@@ -217,7 +245,7 @@ func (dt *DeviceTable) buildRows() []tableRow {
 			manufacturer: d.Manufacturer(),
 			lastSeen:     utils.FmtDuration(time.Since(d.LastSeen())),
 		}
-		if dt.filterRE != nil && !dt.rowMatches(&row) {
+		if !dt.rowMatches(&row) {
 			continue
 		}
 		rows = append(rows, row)
@@ -243,8 +271,8 @@ func (dt *DeviceTable) refresh() {
 	rows := dt.buildRows()
 
 	title := fmt.Sprintf(" Devices (%v) ", len(rows))
-	if dt.filterRE != nil {
-		title += fmt.Sprintf(" [%s]<%s>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), dt.filterRE.String())
+	if pattern := dt.filterPattern(); pattern != "" {
+		title += fmt.Sprintf(" [%s]<%s>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), tview.Escape(pattern))
 	}
 	dt.SetTitle(title)
 
@@ -290,3 +318,11 @@ func (dt *DeviceTable) rowMatches(r *tableRow) bool {
 		dt.filterRE.MatchString(r.manufacturer) ||
 		dt.filterRE.MatchString(r.lastSeen)
 }
+
+// filterPattern returns the pattern of the active filter, empty when there is none.
+func (dt *DeviceTable) filterPattern() string {
+	if dt.filterRE != nil {
+		return strings.TrimPrefix(dt.filterRE.String(), "(?i)")
+	}
+	return dt.filter.String()
+}
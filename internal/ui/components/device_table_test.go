@@ -0,0 +1,65 @@
+package components
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func testTable() *DeviceTable {
+	tv := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	tv.SetDisplayName("Living Room TV")
+	tv.SetMAC("28:6f:b9:00:11:22")
+	tv.SetManufacturer("Apple, Inc.")
+	tv.AddSource("mdns")
+
+	printer := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	printer.SetDisplayName("printer a=b")
+	printer.SetManufacturer("HP")
+	printer.SetLastSeen(time.Now().Add(-5 * time.Minute))
+
+	dt := NewDeviceTable(func(events.Event) {})
+	dt.devices = []*discovery.Device{tv, printer}
+	return dt
+}
+
+func TestDeviceTableSetFilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"", []string{"192.168.1.10", "192.168.1.20"}},
+		// free text is matched as a whole, like the search always did
+		{"living room", []string{"192.168.1.10"}},
+		{"room living", []string{}},
+		{"a=b", []string{"192.168.1.20"}},
+		{"^5m$", []string{"192.168.1.20"}},
+		{"APPLE|hp", []string{"192.168.1.10", "192.168.1.20"}},
+		// patterns with a field term are filter expressions
+		{"vendor~apple", []string{"192.168.1.10"}},
+		{"source=mdns room", []string{"192.168.1.10"}},
+		{"name!~tv ip=192.168.1.0/24", []string{"192.168.1.20"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			dt := testTable()
+			if err := dt.SetFilter(tt.pattern); err != nil {
+				t.Fatalf("SetFilter(%q) failed: %v", tt.pattern, err)
+			}
+			if got := dt.VisibleIPs(); !slices.Equal(got, tt.want) {
+				t.Errorf("SetFilter(%q) shows %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceTableSetFilterInvalid(t *testing.T) {
+	dt := testTable()
+	if err := dt.SetFilter("("); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
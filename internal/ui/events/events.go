@@ -41,6 +41,12 @@ type DiscoveryStarted struct{}
 // DiscoveryStopped is emitted when discovery stops.
 type DiscoveryStopped struct{}
 
+// PortScanTargetsSelected is emitted to choose which devices the next port scan targets.
+// An empty IPs list targets the currently selected device.
+type PortScanTargetsSelected struct {
+	IPs []string
+}
+
 // PortScanStarted is emitted when port scan starts.
 type PortScanStarted struct{}
 
@@ -38,6 +38,7 @@ func NewDashboardView(emit func(events.Event), queue func(f func())) *DashboardV
 		"j/k: up/down" + components.Divider +
 		"Enter: details" + components.Divider +
 		"y: copy" + components.Divider +
+		"P: scan filtered" + components.Divider +
 		"Ctrl+T: theme" + components.Divider +
 		"q: quit",
 	)
@@ -12,6 +12,7 @@ import (
 	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/internal/ui/utils"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/rivo/tview"
 )
 
@@ -70,6 +71,7 @@ func handleInput(p *DetailView) func(ev *tcell.EventKey) *tcell.EventKey {
 			p.emit(events.NavigateTo{Route: routes.RouteDashboard, Overlay: true})
 			return nil
 		case ev.Rune() == 'p':
+			p.emit(events.PortScanTargetsSelected{})
 			p.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
 			return nil
 		case ev.Rune() == 'y':
@@ -143,7 +145,11 @@ func (d *DetailView) Render(s state.ReadOnly) {
 
 	writeLine("IP", device.IP().String())
 	writeLine("Display Name", device.DisplayName())
-	writeLine("MAC", device.MAC())
+	mac := device.MAC()
+	if device.RandomizedMAC() {
+		mac += " (randomized)"
+	}
+	writeLine("MAC", mac)
 	writeLine("Manufacturer", device.Manufacturer())
 	writeLine("First Seen", formatTime(device.FirstSeen()))
 	writeLine("Last Seen", formatTime(device.LastSeen()))
@@ -168,7 +174,11 @@ func (d *DetailView) Render(s state.ReadOnly) {
 			if len(ports) > 0 {
 				writeProto(key)
 				for _, port := range ports {
-					_, _ = fmt.Fprintf(d.info, "    %d\n", port)
+					if service := discovery.ServiceName(key, port); service != "" {
+						_, _ = fmt.Fprintf(d.info, "    %d (%s)\n", port, service)
+					} else {
+						_, _ = fmt.Fprintf(d.info, "    %d\n", port)
+					}
 				}
 				_, _ = fmt.Fprintln(d.info)
 			}
@@ -178,6 +188,36 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		}
 	}
 
+	if history := device.PortScanHistory(); len(history) > 1 {
+		_, _ = fmt.Fprintln(d.info)
+		writeSection("Port Changes")
+		changes := discovery.DiffPorts(history[len(history)-2].OpenPorts, history[len(history)-1].OpenPorts)
+		if changes.Empty() {
+			_, _ = fmt.Fprintln(d.info, "  (no changes since previous scan)")
+		} else {
+			for _, key := range utils.SortedKeys(changes.Opened) {
+				for _, port := range changes.Opened[key] {
+					_, _ = fmt.Fprintf(d.info, "  + %d/%s opened\n", port, key)
+				}
+			}
+			for _, key := range utils.SortedKeys(changes.Closed) {
+				for _, port := range changes.Closed[key] {
+					_, _ = fmt.Fprintf(d.info, "  - %d/%s closed\n", port, key)
+				}
+			}
+		}
+
+		_, _ = fmt.Fprintln(d.info)
+		writeSection("Port Scan History")
+		for i := len(history) - 1; i >= 0; i-- {
+			open := 0
+			for _, ports := range history[i].OpenPorts {
+				open += len(ports)
+			}
+			_, _ = fmt.Fprintf(d.info, "  %s  %d open\n", formatTime(history[i].Time), open)
+		}
+	}
+
 	_, _ = fmt.Fprintln(d.info)
 	writeSection("Extra Data")
 	if len(device.ExtraData()) == 0 {
@@ -51,13 +51,28 @@ func NewPortScanModalView(emit func(events.Event)) *PortScanModalView {
 func (p *PortScanModalView) FocusTarget() tview.Primitive { return p.Modal }
 
 func (p *PortScanModalView) Render(s state.ReadOnly) {
+	cfg := s.Config()
+	tcpPorts := cfg.PortScanner.TCP
+
+	// the filtered devices are scanned, also when the filter matches a single device
+	if targets := s.PortScanTargets(); len(targets) > 0 {
+		devices := "devices"
+		if len(targets) == 1 {
+			devices = "device"
+		}
+		text := fmt.Sprintf("The following ports will be scanned on %d %s:\n\n", len(targets), devices)
+		text += fmt.Sprintf("TCP: %v\n\n", tcpPorts)
+		text += "Only scan hosts that you have permission to scan!"
+
+		p.Modal.SetText(text).SetTitle(fmt.Sprintf(" %d %s ", len(targets), devices))
+		return
+	}
+
 	device, ok := s.Selected()
 	if !ok {
 		p.SetText("No device selected.")
 		return
 	}
-	cfg := s.Config()
-	tcpPorts := cfg.PortScanner.TCP
 
 	text := "The following ports will be scanned:\n\n"
 	text += fmt.Sprintf("TCP: %v\n\n", tcpPorts)
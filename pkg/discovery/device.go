@@ -2,9 +2,14 @@ package discovery
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
 )
 
 // Device represents a discovered network device with information aggregated
@@ -22,8 +27,9 @@ import (
 //   - firstSeen: When this device was first discovered
 //   - lastSeen: Most recent discovery time
 //   - extraData: Protocol-specific metadata (e.g., SSDP device type, mDNS TXT records)
-//   - openPorts: Results from port scans, organized by protocol (not serialized to JSON)
-//   - lastPortScan: Timestamp of the most recent port scan (not serialized to JSON)
+//   - openPorts: Results from port scans, organized by protocol
+//   - lastPortScan: Timestamp of the most recent port scan
+//   - portScanHistory: The most recent port scan results, oldest first (not serialized to JSON)
 //
 // Devices are uniquely identified by their IP address. When the same IP is seen
 // by multiple scanners, their data is merged using the Merge method.
@@ -39,6 +45,8 @@ type Device struct {
 	extraData    map[string]string
 	openPorts    map[string][]int
 	lastPortScan time.Time
+
+	portScanHistory []PortScanResult
 }
 
 // NewDevice creates a Device with the given IP address and initializes all maps.
@@ -65,13 +73,16 @@ func NewDevice(ip net.IP) *Device {
 // Merge combines information from another Device into this one.
 // Fields are merged as follows:
 //   - ip: copied if missing
-//   - mac: copied if missing
+//   - mac: copied if missing, or when it is randomized and the other MAC is not
 //   - displayName: copied if missing
 //   - manufacturer: copied if missing
 //   - sources: union of all sources
 //   - extraData: merged, new keys added
 //   - firstSeen: earliest time
 //   - lastSeen: latest time
+//   - openPorts: union of all open ports
+//   - lastPortScan: latest time
+//   - portScanHistory: copied if missing
 //
 // Thread-safe: both devices are locked during the operation.
 //
@@ -105,8 +116,14 @@ func (d *Device) Merge(other *Device) {
 	if d.ip == nil && other.ip != nil {
 		d.ip = other.ip
 	}
-	if d.mac == "" && other.mac != "" {
+	switch {
+	case d.mac == "" && other.mac != "":
 		d.mac = other.mac
+	case oui.IsRandomized(d.mac) && other.mac != "" && !oui.IsRandomized(other.mac):
+		// a randomized MAC is a weak identity, prefer the globally unique one;
+		// the manufacturer was derived from the old MAC, so it is replaced as well
+		d.mac = other.mac
+		d.manufacturer = other.manufacturer
 	}
 	if d.displayName == "" && other.displayName != "" {
 		d.displayName = other.displayName
@@ -157,6 +174,9 @@ func (d *Device) Merge(other *Device) {
 	if other.lastPortScan.After(d.lastPortScan) {
 		d.lastPortScan = other.lastPortScan
 	}
+	if len(d.portScanHistory) == 0 && len(other.portScanHistory) > 0 {
+		d.portScanHistory = copyPortScanHistory(other.portScanHistory)
+	}
 }
 
 // IP returns a copy of the device's IP address.
@@ -176,6 +196,34 @@ func (d *Device) MAC() string {
 	return d.mac
 }
 
+// RandomizedMAC reports whether the device's MAC address is most likely randomized
+// (a locally administered address, e.g. a private Wi-Fi address of a phone).
+// Such addresses do not identify the manufacturer and may change over time.
+func (d *Device) RandomizedMAC() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return oui.IsRandomized(d.mac)
+}
+
+// IdentityKey returns the key that identifies the device across scans: its MAC address in
+// lower case, or its IP address when the MAC address is unknown or randomized, since a
+// randomized MAC may change while the device keeps its IP. It returns an empty string when
+// both are unknown.
+func (d *Device) IdentityKey() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.mac != "" && !oui.IsRandomized(d.mac) {
+		if mac, err := net.ParseMAC(strings.TrimSpace(d.mac)); err == nil {
+			return mac.String()
+		}
+		return strings.ToLower(strings.TrimSpace(d.mac))
+	}
+	if d.ip == nil {
+		return ""
+	}
+	return d.ip.String()
+}
+
 // DisplayName returns the device's display name.
 func (d *Device) DisplayName() string {
 	d.mu.RLock()
@@ -244,6 +292,13 @@ func (d *Device) LastPortScan() time.Time {
 	return d.lastPortScan
 }
 
+// PortScanHistory returns a deep copy of the most recent port scan results, oldest first.
+func (d *Device) PortScanHistory() []PortScanResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return copyPortScanHistory(d.portScanHistory)
+}
+
 // SetIP sets the device's IP address.
 func (d *Device) SetIP(ip net.IP) {
 	d.mu.Lock()
@@ -327,6 +382,31 @@ func (d *Device) SetLastPortScan(t time.Time) {
 	d.lastPortScan = t
 }
 
+// RecordPortScan stores the result of a completed port scan: it replaces the
+// open ports, updates the last port scan time and appends the result to the
+// port scan history (keeping at most MaxPortScanHistory entries).
+//
+// Returns the ports that were opened or closed compared to the previous
+// recorded scan, or nil when this is the first recorded scan of the device.
+func (d *Device) RecordPortScan(t time.Time, ports map[string][]int) *PortChanges {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var changes *PortChanges
+	if n := len(d.portScanHistory); n > 0 {
+		changes = DiffPorts(d.portScanHistory[n-1].OpenPorts, ports)
+	}
+
+	d.openPorts = copyPorts(ports)
+	d.lastPortScan = t
+	d.portScanHistory = append(d.portScanHistory, PortScanResult{Time: t, OpenPorts: copyPorts(ports)})
+	if n := len(d.portScanHistory); n > MaxPortScanHistory {
+		d.portScanHistory = append([]PortScanResult(nil), d.portScanHistory[n-MaxPortScanHistory:]...)
+	}
+
+	return changes
+}
+
 // AddSource adds a scanner source to the device.
 func (d *Device) AddSource(name string) {
 	d.mu.Lock()
@@ -363,6 +443,8 @@ func (d *Device) Copy() *Device {
 		extraData:    make(map[string]string),
 		openPorts:    make(map[string][]int),
 		lastPortScan: d.lastPortScan,
+
+		portScanHistory: copyPortScanHistory(d.portScanHistory),
 	}
 
 	for k := range d.sources {
@@ -378,45 +460,110 @@ func (d *Device) Copy() *Device {
 	return newD
 }
 
+// deviceJSON is the stable JSON schema of a Device, shared by MarshalJSON and UnmarshalJSON.
+type deviceJSON struct {
+	IP            string            `json:"ip"`
+	MAC           string            `json:"mac"`
+	RandomizedMAC bool              `json:"randomizedMac"`
+	DisplayName   string            `json:"displayName"`
+	Manufacturer  string            `json:"manufacturer"`
+	Sources       []string          `json:"sources"`
+	FirstSeen     time.Time         `json:"firstSeen"`
+	LastSeen      time.Time         `json:"lastSeen"`
+	ExtraData     map[string]string `json:"extraData"`
+	OpenPorts     map[string][]int  `json:"openPorts"`
+	LastPortScan  *time.Time        `json:"lastPortScan"`
+}
+
 // MarshalJSON customizes the JSON encoding of the Device struct.
-// It ensures thread-safe access to the fields.
+// It ensures thread-safe access to the fields. Sources and ports are sorted
+// so the output is stable, and lastPortScan is null when no port scan was done.
 func (d *Device) MarshalJSON() ([]byte, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	type temp struct {
-		IP           string            `json:"ip"`
-		MAC          string            `json:"mac"`
-		DisplayName  string            `json:"displayName"`
-		Manufacturer string            `json:"manufacturer"`
-		Sources      []string          `json:"sources"`
-		FirstSeen    time.Time         `json:"firstSeen"`
-		LastSeen     time.Time         `json:"lastSeen"`
-		ExtraData    map[string]string `json:"extraData"`
-	}
-
 	ipStr := ""
 	if d.ip != nil {
 		ipStr = d.ip.String()
 	}
 
-	t := temp{
-		IP:           ipStr,
-		MAC:          d.mac,
-		DisplayName:  d.displayName,
-		Manufacturer: d.manufacturer,
-		Sources:      make([]string, 0, len(d.sources)),
-		FirstSeen:    d.firstSeen,
-		LastSeen:     d.lastSeen,
-		ExtraData:    make(map[string]string, len(d.extraData)),
+	t := deviceJSON{
+		IP:            ipStr,
+		MAC:           d.mac,
+		RandomizedMAC: oui.IsRandomized(d.mac),
+		DisplayName:   d.displayName,
+		Manufacturer:  d.manufacturer,
+		Sources:       make([]string, 0, len(d.sources)),
+		FirstSeen:     d.firstSeen,
+		LastSeen:      d.lastSeen,
+		ExtraData:     make(map[string]string, len(d.extraData)),
+		OpenPorts:     make(map[string][]int, len(d.openPorts)),
 	}
 
 	for source := range d.sources {
 		t.Sources = append(t.Sources, source)
 	}
+	sort.Strings(t.Sources)
 	for k, v := range d.extraData {
 		t.ExtraData[k] = v
 	}
+	for protocol, ports := range d.openPorts {
+		sorted := append([]int{}, ports...)
+		sort.Ints(sorted)
+		t.OpenPorts[protocol] = sorted
+	}
+	if !d.lastPortScan.IsZero() {
+		lastPortScan := d.lastPortScan
+		t.LastPortScan = &lastPortScan
+	}
 
 	return json.Marshal(t)
 }
+
+// UnmarshalJSON decodes a Device from the JSON produced by MarshalJSON.
+// An empty ip is allowed, an invalid ip results in an error.
+//
+// Example:
+//
+//	var devices []*discovery.Device
+//	if err := json.Unmarshal(data, &devices); err != nil {
+//	    log.Fatal(err)
+//	}
+func (d *Device) UnmarshalJSON(data []byte) error {
+	var t deviceJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+
+	var ip net.IP
+	if t.IP != "" {
+		if ip = net.ParseIP(t.IP); ip == nil {
+			return fmt.Errorf("invalid device ip %q", t.IP)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.ip = ip
+	d.mac = t.MAC
+	d.displayName = t.DisplayName
+	d.manufacturer = t.Manufacturer
+	d.sources = make(map[string]struct{}, len(t.Sources))
+	for _, source := range t.Sources {
+		d.sources[source] = struct{}{}
+	}
+	d.firstSeen = t.FirstSeen
+	d.lastSeen = t.LastSeen
+	d.extraData = make(map[string]string, len(t.ExtraData))
+	for k, v := range t.ExtraData {
+		d.extraData[k] = v
+	}
+	d.openPorts = copyPorts(t.OpenPorts)
+	d.lastPortScan = time.Time{}
+	if t.LastPortScan != nil {
+		d.lastPortScan = *t.LastPortScan
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"encoding/json"
 	"net"
 	"testing"
 	"time"
@@ -55,3 +56,223 @@ func TestDeviceMergeNilOther(t *testing.T) {
 	d := NewDevice(net.IP{})
 	d.Merge(nil)
 }
+
+func TestDeviceRecordPortScan(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+
+	if changes := d.RecordPortScan(time.Unix(100, 0), map[string][]int{"tcp": {22, 80}}); changes != nil {
+		t.Fatalf("first scan should not report changes, got %+v", changes)
+	}
+
+	changes := d.RecordPortScan(time.Unix(200, 0), map[string][]int{"tcp": {22, 23}})
+	if changes.Empty() {
+		t.Fatalf("expected changes")
+	}
+	if got := changes.Opened["tcp"]; len(got) != 1 || got[0] != 23 {
+		t.Fatalf("expected port 23 opened, got %v", got)
+	}
+	if got := changes.Closed["tcp"]; len(got) != 1 || got[0] != 80 {
+		t.Fatalf("expected port 80 closed, got %v", got)
+	}
+
+	if !d.LastPortScan().Equal(time.Unix(200, 0)) {
+		t.Fatalf("LastPortScan should be updated, got %v", d.LastPortScan())
+	}
+	if got := d.OpenPorts()["tcp"]; len(got) != 2 || got[0] != 22 || got[1] != 23 {
+		t.Fatalf("OpenPorts should be replaced, got %v", got)
+	}
+
+	if changes := d.RecordPortScan(time.Unix(300, 0), map[string][]int{"tcp": {23, 22}}); !changes.Empty() {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+
+	history := d.PortScanHistory()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(history))
+	}
+	if !history[0].Time.Equal(time.Unix(100, 0)) || !history[2].Time.Equal(time.Unix(300, 0)) {
+		t.Fatalf("history should be ordered oldest first, got %+v", history)
+	}
+}
+
+func TestDeviceRecordPortScanCapsHistory(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	for i := 0; i < MaxPortScanHistory+5; i++ {
+		d.RecordPortScan(time.Unix(int64(i), 0), map[string][]int{"tcp": {i}})
+	}
+
+	history := d.PortScanHistory()
+	if len(history) != MaxPortScanHistory {
+		t.Fatalf("expected %d history entries, got %d", MaxPortScanHistory, len(history))
+	}
+	if !history[0].Time.Equal(time.Unix(5, 0)) {
+		t.Fatalf("oldest entries should be discarded, got %v", history[0].Time)
+	}
+}
+
+func TestDevicePortScanHistoryCopyAndMerge(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.RecordPortScan(time.Unix(100, 0), map[string][]int{"tcp": {22}})
+
+	history := d.PortScanHistory()
+	history[0].OpenPorts["tcp"][0] = 9999
+	if d.PortScanHistory()[0].OpenPorts["tcp"][0] != 22 {
+		t.Fatalf("PortScanHistory should return a deep copy")
+	}
+
+	cp := d.Copy()
+	if len(cp.PortScanHistory()) != 1 {
+		t.Fatalf("Copy should include the port scan history")
+	}
+
+	merged := NewDevice(net.ParseIP("10.0.0.1"))
+	merged.Merge(d)
+	if len(merged.PortScanHistory()) != 1 {
+		t.Fatalf("Merge should copy a missing port scan history")
+	}
+}
+
+func TestDeviceJSONRoundTrip(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	d.SetDisplayName("host")
+	d.SetManufacturer("Acme")
+	d.AddSource("mdns")
+	d.AddSource("arp")
+	d.AddExtraData("k", "v")
+	d.SetFirstSeen(time.Unix(100, 0).UTC())
+	d.SetLastSeen(time.Unix(200, 0).UTC())
+	d.SetOpenPorts(map[string][]int{"tcp": {443, 22}})
+	d.SetLastPortScan(time.Unix(300, 0).UTC())
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal raw: %v", err)
+	}
+	if string(raw["sources"]) != `["arp","mdns"]` {
+		t.Fatalf("sources should be sorted, got %s", raw["sources"])
+	}
+	if string(raw["openPorts"]) != `{"tcp":[22,443]}` {
+		t.Fatalf("open ports should be sorted, got %s", raw["openPorts"])
+	}
+
+	var got Device
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.IP().String() != "10.0.0.1" || got.MAC() != "aa:bb:cc:dd:ee:ff" || got.DisplayName() != "host" || got.Manufacturer() != "Acme" {
+		t.Fatalf("basic fields not round-tripped: %s", data)
+	}
+	if _, ok := got.Sources()["mdns"]; !ok || len(got.Sources()) != 2 {
+		t.Fatalf("sources not round-tripped: %v", got.Sources())
+	}
+	if got.ExtraData()["k"] != "v" {
+		t.Fatalf("extra data not round-tripped: %v", got.ExtraData())
+	}
+	if !got.FirstSeen().Equal(d.FirstSeen()) || !got.LastSeen().Equal(d.LastSeen()) {
+		t.Fatalf("seen times not round-tripped: %s", data)
+	}
+	if ports := got.OpenPorts()["tcp"]; len(ports) != 2 || ports[0] != 22 || ports[1] != 443 {
+		t.Fatalf("open ports not round-tripped: %v", got.OpenPorts())
+	}
+	if !got.LastPortScan().Equal(d.LastPortScan()) {
+		t.Fatalf("last port scan not round-tripped: %v", got.LastPortScan())
+	}
+
+	again, err := json.Marshal(&got)
+	if err != nil {
+		t.Fatalf("marshal again: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Fatalf("round trip not stable:\n%s\n%s", data, again)
+	}
+}
+
+func TestDeviceJSONWithoutPortScan(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal raw: %v", err)
+	}
+	if string(raw["openPorts"]) != `{}` {
+		t.Fatalf("openPorts should be an empty object, got %s", raw["openPorts"])
+	}
+	if string(raw["lastPortScan"]) != `null` {
+		t.Fatalf("lastPortScan should be null, got %s", raw["lastPortScan"])
+	}
+
+	var got Device
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !got.LastPortScan().IsZero() {
+		t.Fatalf("lastPortScan should be zero, got %v", got.LastPortScan())
+	}
+}
+
+func TestDeviceUnmarshalJSONInvalidIP(t *testing.T) {
+	var d Device
+	if err := json.Unmarshal([]byte(`{"ip":"not-an-ip"}`), &d); err == nil {
+		t.Fatalf("expected error for invalid ip")
+	}
+}
+
+func TestDeviceMergePrefersGlobalMAC(t *testing.T) {
+	base := NewDevice(net.ParseIP("10.0.0.1"))
+	base.SetMAC("da:a1:19:00:11:22")
+	base.SetManufacturer("Randomized MAC")
+	if !base.RandomizedMAC() {
+		t.Fatalf("expected randomized MAC")
+	}
+
+	other := NewDevice(net.ParseIP("10.0.0.1"))
+	other.SetMAC("28:6f:b9:00:11:22")
+
+	base.Merge(other)
+
+	if base.MAC() != "28:6f:b9:00:11:22" {
+		t.Fatalf("expected globally unique MAC to replace randomized MAC, got %s", base.MAC())
+	}
+	if base.RandomizedMAC() {
+		t.Fatalf("expected non-randomized MAC after merge")
+	}
+	if base.Manufacturer() != "" {
+		t.Fatalf("manufacturer of the randomized MAC should be dropped, got %s", base.Manufacturer())
+	}
+
+	randomized := NewDevice(net.ParseIP("10.0.0.1"))
+	randomized.SetMAC("da:a1:19:00:11:22")
+	base.Merge(randomized)
+	if base.MAC() != "28:6f:b9:00:11:22" {
+		t.Fatalf("randomized MAC should not replace a globally unique MAC, got %s", base.MAC())
+	}
+}
+
+func TestDeviceIdentityKey(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	if got := d.IdentityKey(); got != "10.0.0.1" {
+		t.Fatalf("expected IP without MAC, got %q", got)
+	}
+	d.SetMAC("00:1B:63:AA:BB:CC")
+	if got := d.IdentityKey(); got != "00:1b:63:aa:bb:cc" {
+		t.Fatalf("expected lower case MAC, got %q", got)
+	}
+	d.SetMAC("da:a1:19:00:00:01")
+	if got := d.IdentityKey(); got != "10.0.0.1" {
+		t.Fatalf("expected IP for a randomized MAC, got %q", got)
+	}
+	if got := (&Device{}).IdentityKey(); got != "" {
+		t.Fatalf("expected empty key, got %q", got)
+	}
+}
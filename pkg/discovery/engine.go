@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
@@ -53,6 +54,39 @@ type Sweeper interface {
 	Start(ctx context.Context)
 }
 
+// SweepStats describes the sweeps performed by a sweeper.
+type SweepStats struct {
+	// Sweeps is the number of completed sweeps.
+	Sweeps int
+	// Interrupted is the number of sweeps cancelled before all targets were triggered.
+	Interrupted int
+	// LastTargets is the number of IPs targeted by the most recent sweep.
+	LastTargets int
+	// LastDuration is the duration of the most recent sweep.
+	LastDuration time.Duration
+	// LastSweep is the time the most recent sweep finished.
+	LastSweep time.Time
+}
+
+// SweepStatsReporter is implemented by sweepers that keep statistics, see Engine.SweepStats.
+type SweepStatsReporter interface {
+	SweepStats() SweepStats
+}
+
+// ScannerError is reported through EventError when a scanner fails.
+type ScannerError struct {
+	Scanner string
+	Err     error
+}
+
+func (e *ScannerError) Error() string {
+	return fmt.Sprintf("scanner %s failed: %v", e.Scanner, e.Err)
+}
+
+func (e *ScannerError) Unwrap() error {
+	return e.Err
+}
+
 // ScanStats contains statistics about a completed scan.
 type ScanStats struct {
 	Count    int
@@ -71,6 +105,27 @@ func (s *ScanStats) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// UnmarshalJSON decodes the JSON encoding produced by MarshalJSON.
+func (s *ScanStats) UnmarshalJSON(data []byte) error {
+	var temp struct {
+		Count    int    `json:"count"`
+		Duration string `json:"duration"`
+	}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	s.Count = temp.Count
+	s.Duration = 0
+	if temp.Duration != "" {
+		d, err := time.ParseDuration(temp.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid scan duration %q: %w", temp.Duration, err)
+		}
+		s.Duration = d
+	}
+	return nil
+}
+
 // ScanResults contains the results of a completed scan, including devices and stats.
 type ScanResults struct {
 	Devices []*Device  `json:"devices"`
@@ -102,6 +157,10 @@ type Engine struct {
 	cancel  context.CancelFunc
 	wg      sync.WaitGroup
 	running bool
+	// trigger requests an immediate scan, see TriggerScan
+	trigger chan struct{}
+	// dropped counts the events that did not fit in the events channel
+	dropped atomic.Uint64
 }
 
 // NewEngine creates a new discovery engine with the provided options.
@@ -147,6 +206,7 @@ func NewEngine(opts ...Option) (*Engine, error) {
 
 	e.events = make(chan Event, DefaultEventBuf)
 	e.Events = e.events
+	e.trigger = make(chan struct{}, 1)
 
 	return e, nil
 }
@@ -275,12 +335,77 @@ func (e *Engine) Scan(ctx context.Context) (*ScanResults, error) {
 	return e.performScan(ctx)
 }
 
+// RecordPortScan records a completed port scan of d (see Device.RecordPortScan)
+// and emits an EventPortsChanged event when ports were opened or closed since
+// the previous recorded scan. The event is only emitted while the engine is running.
+//
+// Returns the detected changes, which is nil for the first recorded scan of a device.
+//
+// Example:
+//
+//	if changes := engine.RecordPortScan(device, map[string][]int{"tcp": {22, 80}}); !changes.Empty() {
+//	    fmt.Printf("newly opened: %v\n", changes.Opened["tcp"])
+//	}
+func (e *Engine) RecordPortScan(d *Device, ports map[string][]int) *PortChanges {
+	if d == nil {
+		return nil
+	}
+	changes := d.RecordPortScan(time.Now(), ports)
+	if changes.Empty() {
+		return changes
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.running {
+		e.emit(NewPortsChangedEvent(d, changes))
+	}
+	return changes
+}
+
+// TriggerScan requests a scan outside of the regular interval. When no scan is running
+// the scan starts immediately, otherwise it starts right after the running scan finished;
+// multiple requests in the meantime result in a single scan. The regular schedule continues
+// from the start of the triggered scan.
+//
+// Returns false when the engine is not running, or was started with a scan interval of 0.
+func (e *Engine) TriggerScan() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.running || e.scanInterval <= 0 {
+		return false
+	}
+	select {
+	case e.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// DroppedEvents returns the number of events that were dropped because the Events channel
+// was full, i.e. the consumer did not keep up.
+func (e *Engine) DroppedEvents() uint64 {
+	return e.dropped.Load()
+}
+
+// SweepStats returns the statistics of the sweeper. The second return value is false when
+// the engine has no sweeper, or the sweeper does not implement SweepStatsReporter.
+func (e *Engine) SweepStats() (SweepStats, bool) {
+	reporter, ok := e.sweeper.(SweepStatsReporter)
+	if !ok {
+		return SweepStats{}, false
+	}
+	return reporter.SweepStats(), true
+}
+
 // runScanLoop runs continuous scans at interval.
 //
 // Contract:
 //   - The first scan starts immediately.
 //   - Subsequent scans start on a fixed-rate schedule, i.e. scanInterval is measured from scan start.
 //   - Scans never overlap. If a scan takes longer than scanInterval, the next scan starts immediately.
+//   - TriggerScan starts the next scan early, the schedule is measured from the triggered scan.
 func (e *Engine) runScanLoop(ctx context.Context) {
 	defer e.wg.Done()
 
@@ -304,6 +429,8 @@ func (e *Engine) runScanLoop(ctx context.Context) {
 				t.Stop()
 				return
 			case <-t.C:
+			case <-e.trigger:
+				t.Stop()
 			}
 		}
 
@@ -311,6 +438,12 @@ func (e *Engine) runScanLoop(ctx context.Context) {
 			return
 		}
 
+		// a trigger that arrived before this scan started is served by it
+		select {
+		case <-e.trigger:
+		default:
+		}
+
 		scanStart := time.Now()
 		scanCtx, cancel := context.WithTimeout(ctx, e.scanTimeout)
 		_, err := e.performScan(scanCtx)
@@ -338,7 +471,7 @@ func (e *Engine) performScan(ctx context.Context) (*ScanResults, error) {
 		go func(s Scanner) {
 			defer scannerWg.Done()
 			if err := s.Scan(ctx, scannerOut); err != nil {
-				e.emit(NewErrorEvent(fmt.Errorf("scanner failed: %w", err)))
+				e.emit(NewErrorEvent(&ScannerError{Scanner: s.Name(), Err: err}))
 			}
 		}(scanner)
 	}
@@ -409,6 +542,7 @@ func (e *Engine) emit(event Event) {
 		// Success
 	default:
 		// Channel full
+		e.dropped.Add(1)
 		if event.Type == EventError && event.Error != nil {
 			e.logger.Log(context.Background(), slog.LevelWarn, "event channel full, dropping error")
 		}
@@ -2,6 +2,8 @@ package discovery_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net"
 	"testing"
 	"time"
@@ -141,3 +143,150 @@ func TestEngine_StartStop_ClosesEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestEngine_RecordPortScan_EmitsPortsChanged(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	s := &testkit.FakeScanner{}
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanInterval(time.Hour),
+		discovery.WithScanTimeout(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch := e.Start(ctx)
+
+	d := discovery.NewDevice(net.IPv4(10, 0, 0, 1))
+	require.Nil(t, e.RecordPortScan(d, map[string][]int{"tcp": {22, 80}}))
+	require.True(t, e.RecordPortScan(d, map[string][]int{"tcp": {22, 80}}).Empty())
+	changes := e.RecordPortScan(d, map[string][]int{"tcp": {22, 23}})
+	require.Equal(t, []int{23}, changes.Opened["tcp"])
+	require.Equal(t, []int{80}, changes.Closed["tcp"])
+
+	e.Stop()
+
+	var changed []discovery.Event
+	for ev := range ch {
+		if ev.Type == discovery.EventPortsChanged {
+			changed = append(changed, ev)
+		}
+	}
+	require.Len(t, changed, 1)
+	require.Same(t, d, changed[0].Device)
+	require.Equal(t, changes, changed[0].Changes)
+}
+
+func TestEngine_TriggerScan(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	s := &testkit.FakeScanner{}
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanInterval(time.Hour),
+		discovery.WithScanTimeout(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.False(t, e.TriggerScan(), "trigger should be rejected before start")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch := e.Start(ctx)
+
+	waitCompleted := func() {
+		t.Helper()
+		for {
+			select {
+			case ev := <-ch:
+				if ev.Type == discovery.EventScanCompleted {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for scan to complete")
+			}
+		}
+	}
+
+	waitCompleted()
+	require.True(t, e.TriggerScan())
+	waitCompleted()
+
+	e.Stop()
+	require.False(t, e.TriggerScan(), "trigger should be rejected after stop")
+}
+
+// statsSweeper is a sweeper reporting fixed statistics.
+type statsSweeper struct {
+	stats discovery.SweepStats
+}
+
+func (s *statsSweeper) Start(context.Context) {}
+
+func (s *statsSweeper) SweepStats() discovery.SweepStats { return s.stats }
+
+func TestEngine_Instrumentation(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	failing := &testkit.FakeScanner{NameStr: "mdns", Err: errors.New("no multicast")}
+	sw := &statsSweeper{stats: discovery.SweepStats{Sweeps: 3, LastTargets: 254}}
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(failing),
+		discovery.WithSweeper(sw),
+		discovery.WithScanInterval(0),
+		discovery.WithScanTimeout(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	stats, ok := e.SweepStats()
+	require.True(t, ok)
+	require.Equal(t, sw.stats, stats)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch := e.Start(ctx)
+
+	var scannerErr *discovery.ScannerError
+	for ev := range ch {
+		if ev.Type == discovery.EventError && errors.As(ev.Error, &scannerErr) {
+			break
+		}
+	}
+	e.Stop()
+	require.NotNil(t, scannerErr)
+	require.Equal(t, "mdns", scannerErr.Scanner)
+	require.EqualError(t, scannerErr, "scanner mdns failed: no multicast")
+	require.Zero(t, e.DroppedEvents())
+}
+
+func TestEngine_DroppedEvents(t *testing.T) {
+	devices := make([]*discovery.Device, 0, discovery.DefaultEventBuf+10)
+	for i := 0; i < discovery.DefaultEventBuf+10; i++ {
+		devices = append(devices, discovery.NewDevice(net.IPv4(10, 0, byte(i/256), byte(i%256))))
+	}
+	s := &testkit.FakeScanner{Devices: devices, DeviceBurst: len(devices)}
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(testkit.MustInterfaceInfo(t)),
+		discovery.WithScanners(s),
+	)
+	require.NoError(t, err)
+
+	// nobody reads the events channel during the scan
+	_, err = e.Scan(context.Background())
+	require.NoError(t, err)
+	require.NotZero(t, e.DroppedEvents())
+}
+
+func TestScanStats_JSONRoundTrip(t *testing.T) {
+	in := discovery.ScanStats{Count: 3, Duration: 1500 * time.Millisecond}
+	data, err := json.Marshal(&in)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"count":3,"duration":"1.5s"}`, string(data))
+
+	var out discovery.ScanStats
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, in, out)
+
+	require.Error(t, json.Unmarshal([]byte(`{"count":1,"duration":"soon"}`), &out))
+}
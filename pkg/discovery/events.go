@@ -3,9 +3,10 @@ package discovery
 // Event represents something that happened during device discovery.
 // Events are emitted through the Events channel. Each Event has a Type
 // indicating what happened. Based on the Type, exactly one of Device,
-// Error, or Stats will be non-nil:
+// Error, or Stats will be non-nil (EventPortsChanged also sets Changes):
 //
 //   - EventDeviceDiscovered: Device is non-nil
+//   - EventPortsChanged: Device and Changes are non-nil
 //   - EventScanCompleted: Stats is non-nil
 //   - EventError: Error is non-nil
 //   - EventScanStarted, EventEngineStarted, EventEngineStopped:
@@ -26,6 +27,8 @@ type Event struct {
 	Device *Device    // non-nil when Type == EventDeviceDiscovered
 	Error  error      // non-nil when Type == EventError
 	Stats  *ScanStats // non-nil when Type == EventScanCompleted
+	// Changes is non-nil when Type == EventPortsChanged
+	Changes *PortChanges
 }
 
 // EventType indicates what kind of event this is.
@@ -38,6 +41,7 @@ const (
 	EventError
 	EventEngineStarted
 	EventEngineStopped
+	EventPortsChanged
 )
 
 // NewDeviceEvent creates a device discovery event.
@@ -48,6 +52,15 @@ func NewDeviceEvent(device *Device) Event {
 	}
 }
 
+// NewPortsChangedEvent creates an event for ports that were opened or closed on a device.
+func NewPortsChangedEvent(device *Device, changes *PortChanges) Event {
+	return Event{
+		Type:    EventPortsChanged,
+		Device:  device,
+		Changes: changes,
+	}
+}
+
 // NewScanCompletedEvent creates a scan completion event.
 func NewScanCompletedEvent(stats *ScanStats) Event {
 	return Event{
@@ -0,0 +1,79 @@
+package oui
+
+import "strconv"
+
+// Registry values of entries that are not IEEE assignments, see Entry.Registry.
+const (
+	// RegistryVirtual marks prefixes of well-known virtualization platforms.
+	RegistryVirtual = "Virtual"
+	// RegistryRandomized marks locally administered addresses, e.g. the private
+	// Wi-Fi addresses used by modern phones and laptops.
+	RegistryRandomized = "Randomized"
+)
+
+// RandomizedMACLabel is the organization returned for randomized MAC addresses.
+const RandomizedMACLabel = "Randomized MAC"
+
+// virtualPrefixes maps the prefixes of well-known virtualization platforms to a label.
+// Some of them are IEEE assignments (e.g. VMware), others are locally administered
+// (e.g. QEMU and Docker), but all of them identify a virtual network interface.
+var virtualPrefixes = map[string]string{
+	"000569": "VMware (virtual)",
+	"000C29": "VMware (virtual)",
+	"001C14": "VMware (virtual)",
+	"005056": "VMware (virtual)",
+	"525400": "QEMU/KVM (virtual)",
+	"0242":   "Docker (virtual)",
+	"080027": "VirtualBox (virtual)",
+	"0A0027": "VirtualBox (virtual)",
+	"00155D": "Hyper-V (virtual)",
+	"00163E": "Xen (virtual)",
+	"001C42": "Parallels (virtual)",
+}
+
+// firstOctet returns the first octet of the MAC address.
+func firstOctet(mac string) (byte, bool) {
+	hex := normalizeHex(mac)
+	if len(hex) < 2 {
+		return 0, false
+	}
+	b, err := strconv.ParseUint(hex[:2], 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return byte(b), true
+}
+
+// IsLocallyAdministered reports whether the locally administered bit (the U/L bit,
+// 0x02 of the first octet) of the MAC address is set. Such addresses are not
+// assigned by the IEEE, so the OUI prefix does not identify a manufacturer.
+// Returns false for invalid MAC addresses.
+func IsLocallyAdministered(mac string) bool {
+	b, ok := firstOctet(mac)
+	return ok && b&0x02 != 0
+}
+
+// IsRandomized reports whether the MAC address is most likely randomized: it is a
+// locally administered unicast address that does not belong to a known
+// virtualization platform.
+func IsRandomized(mac string) bool {
+	b, ok := firstOctet(mac)
+	if !ok || b&0x02 == 0 || b&0x01 != 0 {
+		return false
+	}
+	_, virtual := lookupVirtual(normalizeHex(mac))
+	return !virtual
+}
+
+// lookupVirtual returns the virtualization platform entry for the normalized MAC address.
+func lookupVirtual(hex string) (Entry, bool) {
+	for _, n := range []int{6, 4} {
+		if len(hex) < n {
+			continue
+		}
+		if label, ok := virtualPrefixes[hex[:n]]; ok {
+			return Entry{Prefix: hex[:n], Org: label, Registry: RegistryVirtual, Bits: n * 4}, true
+		}
+	}
+	return Entry{}, false
+}
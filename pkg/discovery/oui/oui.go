@@ -2,9 +2,11 @@ package oui
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,132 +15,292 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // see https://pkg.go.dev/embed
-// this will embed the oui.csv file while compiling the binary
+// this will embed the IEEE registry CSV files while compiling the binary
 //
 //go:embed oui.csv
 var embeddedOUIDB []byte
 
+//go:embed mam.csv
+var embeddedMAMDB []byte
+
+//go:embed oui36.csv
+var embeddedOUI36DB []byte
+
+//go:embed iab.csv
+var embeddedIABDB []byte
+
+//go:embed cid.csv
+var embeddedCIDDB []byte
+
+// DefaultMaxAge is the default age after which registry files are refreshed, see WithMaxAge.
+const DefaultMaxAge = 30 * 24 * time.Hour
+
+// Origins of the data of a registry file, see FileStatus.
+const (
+	OriginEmbedded = "embedded"
+	OriginCache    = "cache"
+	OriginDownload = "download"
+	OriginImport   = "import"
+)
+
 const (
-	updateURL       = "https://standards-oui.ieee.org/oui/oui.csv"
-	maxAge          = 30 * 24 * time.Hour
 	clientTimeout   = 30 * time.Second
 	userAgentHeader = "whosthere/1.0 (+https://github.com/ramonvermeulen/whosthere)"
 	acceptHeader    = "text/csv,application/vnd.ms-excel;q=0.9,*/*;q=0.8"
 )
 
+// source is one of the IEEE registry files the Registry is built from.
+type source struct {
+	file     string
+	registry string
+	url      string
+	embedded []byte
+}
+
+// sources returns the IEEE registry files in priority order. When the same prefix
+// is assigned in multiple files, the entry of the first file wins.
+func sources() []source {
+	return []source{
+		{file: "oui.csv", registry: "MA-L", url: "https://standards-oui.ieee.org/oui/oui.csv", embedded: embeddedOUIDB},
+		{file: "mam.csv", registry: "MA-M", url: "https://standards-oui.ieee.org/oui28/mam.csv", embedded: embeddedMAMDB},
+		{file: "oui36.csv", registry: "MA-S", url: "https://standards-oui.ieee.org/oui36/oui36.csv", embedded: embeddedOUI36DB},
+		{file: "iab.csv", registry: "IAB", url: "https://standards-oui.ieee.org/iab/iab.csv", embedded: embeddedIABDB},
+		{file: "cid.csv", registry: "CID", url: "https://standards-oui.ieee.org/cid/cid.csv", embedded: embeddedCIDDB},
+	}
+}
+
+// prefixLengths are the supported assignment lengths in hex characters,
+// longest first: MA-S/IAB (36 bits), MA-M (28 bits) and MA-L/CID (24 bits).
+var prefixLengths = []int{9, 7, 6}
+
+// Entry is a single IEEE assignment.
+type Entry struct {
+	// Prefix is the assigned prefix as uppercase hex, e.g. "286FB9" or "70B3D5F2A".
+	Prefix string
+	// Org is the name of the organization the prefix is assigned to.
+	Org string
+	// Registry is the IEEE registry of the assignment: "MA-L", "MA-M", "MA-S", "IAB" or "CID",
+	// or RegistryOverride / RegistryVirtual / RegistryRandomized for entries that are not IEEE assignments.
+	Registry string
+	// Bits is the length of the prefix in bits: 24, 28 or 36 for IEEE assignments (0 for randomized addresses).
+	Bits int
+}
+
 // Registry provides MAC address OUI (Organizationally Unique Identifier) lookups
-// to resolve manufacturer names from MAC addresses. The first 3 bytes of a MAC
-// address identify the manufacturer.
+// to resolve manufacturer names from MAC addresses. Next to the 24-bit MA-L
+// assignments, the 28-bit MA-M, 36-bit MA-S and IAB, and CID assignments are
+// loaded, and lookups use the longest matching prefix.
 //
-// The registry embeds IEEE OUI data and can optionally cache updates to disk.
-// It automatically refreshes data older than 30 days from the IEEE website.
+// The registry embeds IEEE data and can optionally cache updates to disk.
+// By default it automatically refreshes data older than 30 days from the IEEE
+// website, see WithMaxAge, WithAutoRefresh and WithMirrorURL.
 //
 // Thread-safe for concurrent lookups.
 type Registry struct {
 	mu        sync.RWMutex
-	prefixMap map[string]string
-	loadedAt  time.Time
-	path      string
+	tables    map[string]map[string]Entry
+	prefixMap map[string]Entry
+	loadedAt  map[string]time.Time
+	origins   map[string]string
+	dir       string
 	logger    *slog.Logger
+
+	maxAge      time.Duration
+	autoRefresh bool
+	mirrorURL   string
+
+	overridesPath      string
+	overrides          *overrides
+	overridesModTime   time.Time
+	overridesCheckedAt atomic.Int64 // UnixNano of the last overrides file check
 }
 
 // Option configures a Registry during construction.
 type Option func(*Registry)
 
 // WithCacheDir sets the directory for caching OUI data.
-// The registry saves downloaded IEEE data to oui.csv in this directory,
-// reducing network requests on subsequent runs. If empty, no caching occurs.
+// The registry saves downloaded IEEE data (oui.csv, mam.csv, oui36.csv, iab.csv
+// and cid.csv) in this directory, reducing network requests on subsequent runs.
+// If empty, no caching occurs.
 func WithCacheDir(dir string) Option {
 	return func(r *Registry) {
 		if dir == "" {
 			return
 		}
-		r.path = filepath.Join(dir, "oui.csv")
+		r.dir = dir
+	}
+}
+
+// WithMaxAge sets the age after which registry files are considered stale and refreshed
+// in the background by New. Values <= 0 are ignored.
+//
+// Default: 30 days
+func WithMaxAge(d time.Duration) Option {
+	return func(r *Registry) {
+		if d > 0 {
+			r.maxAge = d
+		}
+	}
+}
+
+// WithAutoRefresh enables or disables the background refresh of stale registry files
+// by New, e.g. for air-gapped networks. Refresh can still be called explicitly.
+//
+// Default: enabled
+func WithAutoRefresh(enabled bool) Option {
+	return func(r *Registry) {
+		r.autoRefresh = enabled
+	}
+}
+
+// WithMirrorURL downloads the registry files from a mirror instead of the IEEE website.
+// The file names are appended to the base URL, e.g. "https://mirror.local/oui" results
+// in "https://mirror.local/oui/oui.csv", "https://mirror.local/oui/mam.csv", etc.
+//
+// Default: the IEEE website
+func WithMirrorURL(baseURL string) Option {
+	return func(r *Registry) {
+		r.mirrorURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
 	}
 }
 
 // New creates an OUI registry with embedded IEEE data.
 // If WithCacheDir is used and cached data exists, it's loaded instead.
-// Automatically triggers a background refresh if data is older than 30 days.
+// Unless disabled with WithAutoRefresh, automatically triggers a background refresh
+// of the files that are older than the max age, or that have no assignments.
 //
 // The registry is immediately usable even if the background refresh fails.
 func New(ctx context.Context, opts ...Option) (*Registry, error) {
-	reg := &Registry{
-		prefixMap: make(map[string]string),
-		logger:    slog.Default(),
-	}
+	reg := newRegistry()
 	for _, opt := range opts {
 		opt(reg)
 	}
 
-	data := embeddedOUIDB
-	if reg.path != "" {
-		b, err := os.ReadFile(reg.path)
-		switch err {
-		case nil:
-			data = b
-			if info, statErr := os.Stat(reg.path); statErr == nil {
-				reg.loadedAt = info.ModTime()
-			}
-			reg.logger.Debug("OUI: loaded CSV from cache", "path", reg.path, "bytes", len(data))
-		default:
-			reg.logger.Debug("OUI: cache not available, using embedded CSV", "path", reg.path, "err", err)
-			if mkErr := os.MkdirAll(filepath.Dir(reg.path), 0o755); mkErr == nil {
-				if writeErr := os.WriteFile(reg.path, data, 0o644); writeErr != nil {
-					reg.logger.Debug("OUI: failed to write embedded oui.csv to cache", "err", writeErr)
-				}
-			}
-			reg.loadedAt = time.Now()
+	var stale []source
+	for _, src := range sources() {
+		data, loadedAt, origin := reg.readSource(src)
+		if err := reg.loadFromBytes(src.file, data, loadedAt, origin); err != nil {
+			reg.logger.Error("OUI: failed to parse CSV", "file", src.file, "err", err)
+			return nil, err
 		}
-	} else {
-		reg.loadedAt = time.Now()
+
+		reg.mu.RLock()
+		entries := len(reg.tables[src.file])
+		reg.mu.RUnlock()
+
+		// a file without assignments is downloaded right away, it is not cached until a
+		// download succeeds so a failed download is retried on the next start
+		age := time.Since(loadedAt)
+		if reg.dir != "" && (age > reg.maxAge || entries == 0) {
+			stale = append(stale, src)
+		}
+		reg.logger.Debug("OUI: loaded registry file", "file", src.file, "entries", entries, "age", age)
 	}
 
-	if err := reg.loadFromBytes(data); err != nil {
-		reg.logger.Error("OUI: failed to parse CSV", "err", err)
-		return nil, err
+	if err := reg.ReloadOverrides(); err != nil {
+		reg.logger.Warn("OUI: failed to load overrides, continuing without overrides", "err", err)
 	}
+	reg.overridesCheckedAt.Store(time.Now().UnixNano())
 
 	reg.mu.RLock()
 	entryCount := len(reg.prefixMap)
-	loadedAt := reg.loadedAt
-	path := reg.path
 	reg.mu.RUnlock()
-	reg.logger.Debug("OUI: registry initialized", "entries", entryCount, "path", path, "loaded_at", loadedAt)
+	reg.logger.Debug("OUI: registry initialized", "entries", entryCount, "dir", reg.dir)
 
-	age := time.Since(loadedAt)
-	// todo(ramon): make this configurable, e.g. the time period as well as whether to trigger a background refresh at all
-	if reg.path != "" && age > maxAge {
-		reg.logger.Info("OUI: data older than maxAge, triggering one-time refresh", "age", age, "max_age", maxAge)
+	switch {
+	case len(stale) > 0 && !reg.autoRefresh:
+		reg.logger.Debug("OUI: auto refresh disabled, skipping refresh of stale data", "files", len(stale), "max_age", reg.maxAge)
+	case len(stale) > 0:
+		reg.logger.Info("OUI: data older than maxAge or empty, triggering one-time refresh", "files", len(stale), "max_age", reg.maxAge)
 		go func() {
-			if err := reg.Refresh(ctx); err != nil {
-				reg.logger.Debug("OUI: initial one-time refresh failed", "err", err)
+			for _, src := range stale {
+				if err := reg.refreshSource(ctx, src); err != nil {
+					reg.logger.Debug("OUI: initial one-time refresh failed", "file", src.file, "err", err)
+				}
 			}
 		}()
-	} else {
-		reg.logger.Debug("OUI: data is fresh enough, skipping initial refresh", "age", age, "max_age", maxAge)
+	default:
+		reg.logger.Debug("OUI: data is fresh enough, skipping initial refresh", "max_age", reg.maxAge)
 	}
 
 	return reg, nil
 }
 
-// Refresh downloads the latest OUI data from the IEEE website and updates the registry.
-// If a cache directory was configured, the downloaded data is saved to disk.
+// newRegistry returns an empty registry with the default settings.
+func newRegistry() *Registry {
+	return &Registry{
+		tables:    make(map[string]map[string]Entry),
+		prefixMap: make(map[string]Entry),
+		loadedAt:  make(map[string]time.Time),
+		origins:   make(map[string]string),
+		logger:    slog.Default(),
+
+		maxAge:      DefaultMaxAge,
+		autoRefresh: true,
+	}
+}
+
+// readSource returns the cached copy of src when available, otherwise the embedded
+// copy (which is then written to the cache unless it has no assignments), together with
+// the time it was loaded and its origin.
+func (reg *Registry) readSource(src source) ([]byte, time.Time, string) {
+	if reg.dir == "" {
+		return src.embedded, time.Now(), OriginEmbedded
+	}
+
+	path := filepath.Join(reg.dir, src.file)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		loadedAt := time.Now()
+		if info, statErr := os.Stat(path); statErr == nil {
+			loadedAt = info.ModTime()
+		}
+		reg.logger.Debug("OUI: loaded CSV from cache", "path", path, "bytes", len(b))
+		return b, loadedAt, OriginCache
+	}
+
+	reg.logger.Debug("OUI: cache not available, using embedded CSV", "path", path, "err", err)
+	if !hasAssignments(src.embedded) {
+		return src.embedded, time.Now(), OriginEmbedded
+	}
+	if mkErr := os.MkdirAll(reg.dir, 0o755); mkErr == nil {
+		if writeErr := os.WriteFile(path, src.embedded, 0o644); writeErr != nil {
+			reg.logger.Debug("OUI: failed to write embedded CSV to cache", "path", path, "err", writeErr)
+		}
+	}
+	return src.embedded, time.Now(), OriginEmbedded
+}
+
+// Refresh downloads the latest data of all IEEE registries (or the mirror configured with
+// WithMirrorURL) and updates the registry.
+// If a cache directory was configured, the downloaded files are saved to disk.
 //
 // Called automatically in the background when data is older than 30 days.
 // You can also call it manually to force an update.
 //
-// Returns an error if the download fails or the data is malformed.
-// The registry continues using existing data if the refresh fails.
+// Returns an error if a download fails or the data is malformed. Files that
+// were refreshed successfully are used, for the others the registry continues
+// using the existing data.
 func (reg *Registry) Refresh(ctx context.Context) error {
+	var errs []error
+	for _, src := range sources() {
+		if err := reg.refreshSource(ctx, src); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (reg *Registry) refreshSource(ctx context.Context, src source) error {
 	ctx, cancel := context.WithTimeout(ctx, clientTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, updateURL, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reg.sourceURL(src), http.NoBody)
 	if err != nil {
 		return err
 	}
@@ -161,44 +323,169 @@ func (reg *Registry) Refresh(ctx context.Context) error {
 		return err
 	}
 
-	m, err := parseCSVBytes(data)
-	if err != nil {
+	if err := reg.loadFromBytes(src.file, data, time.Now(), OriginDownload); err != nil {
 		return err
 	}
 
-	reg.mu.Lock()
-	reg.prefixMap = m
-	reg.loadedAt = time.Now()
-	path := reg.path
-	reg.mu.Unlock()
-
-	if path != "" {
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
-			if err := os.WriteFile(path, data, 0o644); err != nil {
-				reg.logger.Debug("OUI: failed to persist refreshed oui.csv", "err", err)
+	if err := reg.persist(src.file, data); err != nil {
+		reg.logger.Debug("OUI: failed to persist refreshed CSV", "file", src.file, "err", err)
+	}
+	return nil
+}
+
+// sourceURL returns the download URL of src, taking the mirror into account.
+func (reg *Registry) sourceURL(src source) string {
+	if reg.mirrorURL != "" {
+		return reg.mirrorURL + "/" + src.file
+	}
+	return src.url
+}
+
+// persist writes the data of a registry file to the cache dir, if any.
+func (reg *Registry) persist(file string, data []byte) error {
+	if reg.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(reg.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(reg.dir, file), data, 0o644)
+}
+
+// ImportFile loads a registry CSV file from disk, e.g. a manually downloaded copy for
+// air-gapped networks, and saves it to the cache dir so it is used on subsequent runs.
+// The registry the file belongs to is derived from its name (oui.csv, mam.csv, oui36.csv,
+// iab.csv or cid.csv) or otherwise from the Registry column of its first assignment.
+//
+// Returns the name of the registry file the data was imported as.
+func (reg *Registry) ImportFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := parseCSVBytes(data)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no assignments found in %s", path)
+	}
+
+	src, ok := matchSource(filepath.Base(path), entries)
+	if !ok {
+		return "", fmt.Errorf("cannot determine the IEEE registry of %s", path)
+	}
+
+	if err := reg.loadFromBytes(src.file, data, time.Now(), OriginImport); err != nil {
+		return "", err
+	}
+	if err := reg.persist(src.file, data); err != nil {
+		return "", fmt.Errorf("save %s to cache: %w", src.file, err)
+	}
+	return src.file, nil
+}
+
+// matchSource returns the source of a registry file by its name, or by the registry of its entries.
+func matchSource(name string, entries map[string]Entry) (source, bool) {
+	for _, src := range sources() {
+		if strings.EqualFold(name, src.file) {
+			return src, true
+		}
+	}
+	for _, entry := range entries {
+		for _, src := range sources() {
+			if strings.EqualFold(entry.Registry, src.registry) {
+				return src, true
 			}
 		}
+		break
 	}
+	return source{}, false
+}
 
-	return nil
+// FileStatus describes the data loaded from one of the IEEE registry files.
+type FileStatus struct {
+	// File is the name of the registry file, e.g. "oui.csv".
+	File string `json:"file"`
+	// Registry is the IEEE registry of the file: "MA-L", "MA-M", "MA-S", "IAB" or "CID".
+	Registry string `json:"registry"`
+	// Entries is the number of assignments loaded from the file.
+	Entries int `json:"entries"`
+	// Origin is where the data was loaded from: OriginEmbedded, OriginCache, OriginDownload or OriginImport.
+	Origin string `json:"origin"`
+	// UpdatedAt is when the data was downloaded or imported (the modification time for cached files),
+	// zero for embedded data.
+	UpdatedAt time.Time `json:"updatedAt"`
+	// URL is where the file is downloaded from on refresh.
+	URL string `json:"url"`
+}
+
+// Status returns the status of all registry files, in priority order.
+func (reg *Registry) Status() []FileStatus {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	out := make([]FileStatus, 0, len(sources()))
+	for _, src := range sources() {
+		st := FileStatus{
+			File:     src.file,
+			Registry: src.registry,
+			Entries:  len(reg.tables[src.file]),
+			Origin:   reg.origins[src.file],
+			URL:      reg.sourceURL(src),
+		}
+		if st.Origin != OriginEmbedded {
+			st.UpdatedAt = reg.loadedAt[src.file]
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// CacheDir returns the directory the registry files are cached in, empty when not cached.
+func (reg *Registry) CacheDir() string {
+	return reg.dir
 }
 
-func (reg *Registry) loadFromBytes(b []byte) error {
+// loadFromBytes parses the CSV data of a single registry file and replaces its entries.
+func (reg *Registry) loadFromBytes(file string, b []byte, loadedAt time.Time, origin string) error {
 	m, err := parseCSVBytes(b)
 	if err != nil {
 		return err
 	}
+
 	reg.mu.Lock()
 	defer reg.mu.Unlock()
-	reg.prefixMap = m
-	if reg.loadedAt.IsZero() {
-		reg.loadedAt = time.Now()
-	}
+	reg.tables[file] = m
+	reg.loadedAt[file] = loadedAt
+	reg.origins[file] = origin
+	reg.rebuildLocked()
 	return nil
 }
 
-func parseCSVBytes(b []byte) (map[string]string, error) {
-	r := csv.NewReader(bufio.NewReader(strings.NewReader(string(b))))
+// rebuildLocked merges the per file tables into prefixMap, respecting the source priority.
+// Caller must hold reg.mu for writing.
+func (reg *Registry) rebuildLocked() {
+	merged := make(map[string]Entry)
+	for _, src := range sources() {
+		for prefix, entry := range reg.tables[src.file] {
+			if _, exists := merged[prefix]; !exists {
+				merged[prefix] = entry
+			}
+		}
+	}
+	reg.prefixMap = merged
+}
+
+// hasAssignments reports whether a registry CSV has any lines after its header.
+func hasAssignments(b []byte) bool {
+	_, rest, _ := bytes.Cut(b, []byte("\n"))
+	return len(bytes.TrimSpace(rest)) > 0
+}
+
+func parseCSVBytes(b []byte) (map[string]Entry, error) {
+	r := csv.NewReader(bufio.NewReader(bytes.NewReader(b)))
 	r.FieldsPerRecord = -1
 
 	header, err := r.Read()
@@ -210,12 +497,12 @@ func parseCSVBytes(b []byte) (map[string]string, error) {
 	}
 
 	const (
-		macCol = 1
-		orgCol = 2
+		registryCol = 0
+		macCol      = 1
+		orgCol      = 2
 	)
 
-	m := make(map[string]string)
-
+	m := make(map[string]Entry)
 	for {
 		rec, err := r.Read()
 		if err == io.EOF {
@@ -232,48 +519,109 @@ func parseCSVBytes(b []byte) (map[string]string, error) {
 		if macField == "" || org == "" {
 			continue
 		}
-
-		prefix := normalizeMACPrefix(macField)
+		prefix := normalizeAssignment(macField)
 		if prefix == "" {
 			continue
 		}
 		if _, exists := m[prefix]; !exists {
-			m[prefix] = org
+			m[prefix] = Entry{
+				Prefix:   prefix,
+				Org:      org,
+				Registry: strings.TrimSpace(rec[registryCol]),
+				Bits:     len(prefix) * 4,
+			}
 		}
 	}
-
 	return m, nil
 }
 
-func normalizeMACPrefix(s string) string {
+// normalizeHex strips the separators from s and returns it as uppercase hex,
+// or an empty string when s contains non-hex characters.
+func normalizeHex(s string) string {
 	s = strings.ToUpper(s)
 	s = strings.ReplaceAll(s, "-", "")
 	s = strings.ReplaceAll(s, ":", "")
 	s = strings.ReplaceAll(s, ".", "")
-	if len(s) < 6 {
-		return ""
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return ""
+		}
+	}
+	return s
+}
+
+// normalizeAssignment returns the normalized prefix of an IEEE assignment,
+// or an empty string when it does not have a supported length.
+func normalizeAssignment(s string) string {
+	s = normalizeHex(s)
+	for _, n := range prefixLengths {
+		if len(s) == n {
+			return s
+		}
 	}
-	return s[:6]
+	return ""
 }
 
 // Lookup returns the manufacturer name for a MAC address.
 // Accepts various MAC formats: "AA:BB:CC:DD:EE:FF", "AA-BB-CC-DD-EE-FF", "AABBCCDDEEFF".
-// Case-insensitive. Only the first 3 bytes (OUI prefix) are used.
+// Case-insensitive. The longest matching assignment (36, 28 or 24 bits) is used.
+//
+// Well-known virtualization prefixes (VMware, QEMU/KVM, Docker, ...) are labeled
+// as such, and unassigned randomized addresses (see IsRandomized) are labeled
+// with RandomizedMACLabel.
 //
 // Returns the manufacturer name and true if found, empty string and false otherwise.
 func (reg *Registry) Lookup(mac string) (string, bool) {
-	prefix := normalizeMACPrefix(mac)
-	if prefix == "" {
+	entry, ok := reg.LookupEntry(mac)
+	return entry.Org, ok
+}
+
+// LookupEntry is like Lookup, but returns the full matching assignment,
+// including the matched prefix, its length and the IEEE registry it belongs to.
+func (reg *Registry) LookupEntry(mac string) (Entry, bool) {
+	hex := normalizeHex(mac)
+	if len(hex) < 6 {
 		reg.logger.Debug("OUI: lookup skipped, empty/invalid MAC", "mac", mac)
-		return "", false
+		return Entry{}, false
 	}
+
+	reg.reloadOverridesThrottled()
+
 	reg.mu.RLock()
 	defer reg.mu.RUnlock()
-	org, ok := reg.prefixMap[prefix]
+
+	entry, ok := reg.lookupLocked(hex)
 	if !ok {
-		reg.logger.Debug("OUI: no entry for prefix", "mac", mac, "prefix", prefix)
-		return "", false
+		reg.logger.Debug("OUI: no entry for prefix", "mac", mac, "prefix", hex[:6])
+		return Entry{}, false
+	}
+	if name, renamed := reg.overrides.rename(entry.Org); renamed {
+		entry.Org = name
+	}
+	reg.logger.Debug("OUI: lookup hit", "mac", mac, "prefix", entry.Prefix, "registry", entry.Registry, "org", entry.Org)
+	return entry, true
+}
+
+// lookupLocked resolves the normalized MAC address in order of precedence: user overrides,
+// virtualization prefixes, IEEE assignments (longest prefix first) and randomized addresses.
+// Caller must hold reg.mu for reading.
+func (reg *Registry) lookupLocked(hex string) (Entry, bool) {
+	if entry, ok := reg.overrides.lookup(hex); ok {
+		return entry, true
+	}
+	if entry, ok := lookupVirtual(hex); ok {
+		return entry, true
+	}
+	for _, n := range prefixLengths {
+		if len(hex) < n {
+			continue
+		}
+		if entry, ok := reg.prefixMap[hex[:n]]; ok {
+			return entry, true
+		}
+	}
+	if IsRandomized(hex) {
+		return Entry{Org: RandomizedMACLabel, Registry: RegistryRandomized}, true
 	}
-	reg.logger.Debug("OUI: lookup hit", "mac", mac, "prefix", prefix, "org", org)
-	return org, true
+	return Entry{}, false
 }
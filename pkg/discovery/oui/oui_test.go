@@ -1,8 +1,18 @@
 package oui
 
 import (
+	"context"
 	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestParseCSVBytesHeaderAndLookup(t *testing.T) {
@@ -16,8 +26,8 @@ func TestParseCSVBytesHeaderAndLookup(t *testing.T) {
 	if len(m) == 0 {
 		t.Fatalf("expected at least one entry, got 0")
 	}
-	if got, ok := m["286FB9"]; !ok || got != "Test Org" {
-		t.Fatalf("expected prefix 286FB9 -> 'Test Org', got %q, ok=%v", got, ok)
+	if got, ok := m["286FB9"]; !ok || got.Org != "Test Org" || got.Registry != "MA-L" || got.Bits != 24 {
+		t.Fatalf("expected prefix 286FB9 -> 'Test Org' (MA-L, 24 bits), got %+v, ok=%v", got, ok)
 	}
 }
 
@@ -52,3 +62,344 @@ func TestLookup(t *testing.T) {
 		})
 	}
 }
+
+func TestLookupLongestPrefix(t *testing.T) {
+	reg := newRegistry()
+	files := map[string]string{
+		"oui.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-L,70B3D5,IEEE Registration Authority,Somewhere\n",
+		"mam.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-M,70B3D5F,Medium Org,Somewhere\n",
+		"oui36.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-S,70B3D5F2A,Small Org,Somewhere\n",
+		"iab.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"IAB,0050C2001,IAB Org,Somewhere\n",
+	}
+	for file, data := range files {
+		if err := reg.loadFromBytes(file, []byte(data), time.Now(), OriginEmbedded); err != nil {
+			t.Fatalf("loadFromBytes(%s) error: %v", file, err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		mac          string
+		wantOrg      string
+		wantRegistry string
+		wantBits     int
+	}{
+		{"36-bit MA-S", "70:b3:d5:f2:a1:23", "Small Org", "MA-S", 36},
+		{"28-bit MA-M", "70:b3:d5:f9:99:99", "Medium Org", "MA-M", 28},
+		{"24-bit MA-L", "70:b3:d5:00:00:01", "IEEE Registration Authority", "MA-L", 24},
+		{"36-bit IAB", "00-50-C2-00-1F-FF", "IAB Org", "IAB", 36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := reg.LookupEntry(tt.mac)
+			if !ok || got.Org != tt.wantOrg || got.Registry != tt.wantRegistry || got.Bits != tt.wantBits {
+				t.Errorf("LookupEntry(%q) = %+v, %v; want %q (%s, %d bits)",
+					tt.mac, got, ok, tt.wantOrg, tt.wantRegistry, tt.wantBits)
+			}
+		})
+	}
+
+	if _, ok := reg.Lookup("00:50:c2:00:20:00"); ok {
+		t.Errorf("expected no match outside of the IAB assignment")
+	}
+}
+
+func TestNewLoadsCachedFiles(t *testing.T) {
+	dir := t.TempDir()
+	mam := "Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-M,ABCDEF1,Cached Medium Org,Somewhere\n"
+	if err := os.WriteFile(filepath.Join(dir, "mam.csv"), []byte(mam), 0o644); err != nil {
+		t.Fatalf("write cache: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // no background refresh in tests
+
+	reg, err := New(ctx, WithCacheDir(dir))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	if org, ok := reg.Lookup("ab:cd:ef:1f:ff:ff"); !ok || org != "Cached Medium Org" {
+		t.Errorf("expected cached MA-M entry, got %q, %v", org, ok)
+	}
+	if org, ok := reg.Lookup("28:6f:b9:00:11:22"); !ok || org == "" {
+		t.Errorf("expected embedded MA-L entry, got %q, %v", org, ok)
+	}
+	for _, src := range sources() {
+		_, err := os.Stat(filepath.Join(dir, src.file))
+		if cached := err == nil; cached != hasAssignments(src.embedded) && src.file != "mam.csv" {
+			t.Errorf("expected %s to be written to the cache dir only when it has assignments, got %v", src.file, err)
+		}
+	}
+}
+
+func TestIsLocallyAdministeredAndRandomized(t *testing.T) {
+	tests := []struct {
+		mac            string
+		wantLocal      bool
+		wantRandomized bool
+	}{
+		{"28:6f:b9:00:11:22", false, false},
+		{"da:a1:19:00:11:22", true, true},
+		{"3A-B2-C3-D4-E5-F6", true, true},
+		{"52:54:00:12:34:56", true, false},
+		{"02:42:ac:11:00:02", true, false},
+		{"ff:ff:ff:ff:ff:ff", true, false},
+		{"", false, false},
+		{"zz:zz", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mac, func(t *testing.T) {
+			if got := IsLocallyAdministered(tt.mac); got != tt.wantLocal {
+				t.Errorf("IsLocallyAdministered(%q) = %v, want %v", tt.mac, got, tt.wantLocal)
+			}
+			if got := IsRandomized(tt.mac); got != tt.wantRandomized {
+				t.Errorf("IsRandomized(%q) = %v, want %v", tt.mac, got, tt.wantRandomized)
+			}
+		})
+	}
+}
+
+func TestLookupVirtualAndRandomized(t *testing.T) {
+	csvData := []byte("Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-L,005056,\"VMware, Inc.\",Somewhere\n")
+	m, err := parseCSVBytes(csvData)
+	if err != nil {
+		t.Fatalf("parseCSVBytes error: %v", err)
+	}
+	reg := &Registry{prefixMap: m, logger: slog.Default()}
+
+	tests := []struct {
+		name         string
+		mac          string
+		wantOrg      string
+		wantRegistry string
+	}{
+		{"VMware", "00:50:56:aa:bb:cc", "VMware (virtual)", RegistryVirtual},
+		{"QEMU", "52:54:00:12:34:56", "QEMU/KVM (virtual)", RegistryVirtual},
+		{"Docker", "02:42:ac:11:00:02", "Docker (virtual)", RegistryVirtual},
+		{"randomized", "da:a1:19:00:11:22", RandomizedMACLabel, RegistryRandomized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := reg.LookupEntry(tt.mac)
+			if !ok || got.Org != tt.wantOrg || got.Registry != tt.wantRegistry {
+				t.Errorf("LookupEntry(%q) = %+v, %v; want %q (%s)", tt.mac, got, ok, tt.wantOrg, tt.wantRegistry)
+			}
+		})
+	}
+}
+
+func TestNewWithoutAutoRefresh(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := New(context.Background(), WithCacheDir(t.TempDir()), WithMirrorURL(srv.URL), WithAutoRefresh(false))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if requests != 0 {
+		t.Errorf("expected no refresh with auto refresh disabled, got %d requests", requests)
+	}
+}
+
+func TestRefreshFromMirror(t *testing.T) {
+	files := map[string]string{
+		"/mirror/oui.csv": "Registry,Assignment,Organization Name,Organization Address\nMA-L,AABBCC,Mirror Org,Somewhere\n",
+		"/mirror/mam.csv": "Registry,Assignment,Organization Name,Organization Address\nMA-M,AABBCD1,Mirror Medium Org,Somewhere\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	reg, err := New(context.Background(), WithCacheDir(dir), WithMirrorURL(srv.URL+"/mirror/"), WithAutoRefresh(false))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	if err := reg.Refresh(context.Background()); err == nil {
+		t.Errorf("expected an error for the files missing on the mirror")
+	}
+
+	if org, ok := reg.Lookup("aa:bb:cc:00:00:01"); !ok || org != "Mirror Org" {
+		t.Errorf("expected refreshed MA-L entry, got %q, %v", org, ok)
+	}
+	if org, ok := reg.Lookup("aa:bb:cd:10:00:01"); !ok || org != "Mirror Medium Org" {
+		t.Errorf("expected refreshed MA-M entry, got %q, %v", org, ok)
+	}
+
+	status := reg.Status()
+	if status[0].File != "oui.csv" || status[0].Origin != OriginDownload || status[0].Entries != 1 {
+		t.Errorf("unexpected oui.csv status: %+v", status[0])
+	}
+	if status[0].URL != srv.URL+"/mirror/oui.csv" {
+		t.Errorf("expected mirror URL, got %s", status[0].URL)
+	}
+	if status[2].Origin != OriginEmbedded {
+		t.Errorf("expected embedded oui36.csv after failed refresh, got %+v", status[2])
+	}
+
+	cached, err := os.ReadFile(filepath.Join(dir, "mam.csv"))
+	if err != nil || string(cached) != files["/mirror/mam.csv"] {
+		t.Errorf("expected refreshed mam.csv to be cached, got %q, %v", cached, err)
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := New(context.Background(), WithCacheDir(dir), WithAutoRefresh(false))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	download := filepath.Join(t.TempDir(), "download.csv")
+	data := "Registry,Assignment,Organization Name,Organization Address\nMA-S,AABBCCDDE,Imported Org,Somewhere\n"
+	if err := os.WriteFile(download, []byte(data), 0o644); err != nil {
+		t.Fatalf("write import file: %v", err)
+	}
+
+	file, err := reg.ImportFile(download)
+	if err != nil {
+		t.Fatalf("ImportFile error: %v", err)
+	}
+	if file != "oui36.csv" {
+		t.Errorf("expected import as oui36.csv, got %s", file)
+	}
+	if org, ok := reg.Lookup("aa:bb:cc:dd:ef:ff"); !ok || org != "Imported Org" {
+		t.Errorf("expected imported entry, got %q, %v", org, ok)
+	}
+	if cached, err := os.ReadFile(filepath.Join(dir, "oui36.csv")); err != nil || string(cached) != data {
+		t.Errorf("expected imported file to be cached, got %q, %v", cached, err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.csv")
+	if err := os.WriteFile(empty, []byte("Registry,Assignment,Organization Name,Organization Address\n"), 0o644); err != nil {
+		t.Fatalf("write empty file: %v", err)
+	}
+	if _, err := reg.ImportFile(empty); err == nil {
+		t.Errorf("expected error importing a file without assignments")
+	}
+}
+
+func TestNewRefreshesEmptyFiles(t *testing.T) {
+	var mu sync.Mutex
+	requested := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[path.Base(r.URL.Path)]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	count := func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(requested)
+	}
+
+	dir := t.TempDir()
+	var empty []string
+	for _, src := range sources() {
+		if entries, err := parseCSVBytes(src.embedded); err == nil && len(entries) == 0 {
+			empty = append(empty, src.file)
+		}
+	}
+	if len(empty) == 0 {
+		t.Skip("all embedded registries have assignments")
+	}
+
+	if _, err := New(context.Background(), WithCacheDir(dir), WithMirrorURL(srv.URL)); err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(count()) < len(empty) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, file := range empty {
+		if count()[file] != 1 {
+			t.Errorf("expected one download of %s on the first run, got %d", file, count()[file])
+		}
+	}
+
+	// the empty files are not cached, so the failed download is retried on the next run,
+	// also when an older version did cache them
+	for _, file := range empty {
+		if _, err := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(err) {
+			t.Errorf("expected empty %s not to be cached, got %v", file, err)
+		}
+	}
+	header := []byte("Registry,Assignment,Organization Name,Organization Address\n")
+	if err := os.WriteFile(filepath.Join(dir, empty[0]), header, 0o644); err != nil {
+		t.Fatalf("write cached file: %v", err)
+	}
+	if _, err := New(context.Background(), WithCacheDir(dir), WithMirrorURL(srv.URL)); err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for count()[empty[len(empty)-1]] < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, file := range empty {
+		if count()[file] != 2 {
+			t.Errorf("expected %s to be downloaded again, got %d downloads", file, count()[file])
+		}
+	}
+}
+
+// TestEmbeddedLongestPrefix resolves the first MA-M and MA-S assignments of the embedded
+// registries, which must win over the MA-L assignment of the same 24-bit prefix.
+func TestEmbeddedLongestPrefix(t *testing.T) {
+	reg := newRegistry()
+	for _, src := range sources() {
+		if err := reg.loadFromBytes(src.file, src.embedded, time.Now(), OriginEmbedded); err != nil {
+			t.Fatalf("load embedded %s: %v", src.file, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		file     string
+		registry string
+		bits     int
+	}{
+		{file: "mam.csv", registry: "MA-M", bits: 28},
+		{file: "oui36.csv", registry: "MA-S", bits: 36},
+	} {
+		t.Run(tt.registry, func(t *testing.T) {
+			entries := reg.tables[tt.file]
+			if len(entries) == 0 {
+				t.Skipf("embedded %s has no assignments, run make oui-data", tt.file)
+			}
+			var want Entry
+			for _, e := range entries {
+				if want.Prefix == "" || e.Prefix < want.Prefix {
+					want = e
+				}
+			}
+
+			// pad the prefix to a full address
+			hex := (want.Prefix + "000000000000")[:12]
+			mac := strings.Join([]string{hex[0:2], hex[2:4], hex[4:6], hex[6:8], hex[8:10], hex[10:12]}, ":")
+			got, ok := reg.LookupEntry(mac)
+			if !ok || got.Registry != tt.registry || got.Bits != tt.bits || got.Org != want.Org {
+				t.Errorf("LookupEntry(%q) = %+v, %v; want %+v", mac, got, ok, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package oui
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// RegistryOverride marks entries that come from the user overrides file, see WithOverridesFile.
+const RegistryOverride = "Override"
+
+// overridesCheckInterval throttles how often Lookup checks the overrides file for changes.
+const overridesCheckInterval = 2 * time.Second
+
+// overrides holds the user defined prefix mappings and organization renames.
+type overrides struct {
+	prefixes map[string]string
+	renames  map[string]string
+	lengths  []int
+}
+
+// overridesFile is the YAML representation of the overrides file.
+type overridesFile struct {
+	Prefixes map[string]string `yaml:"prefixes"`
+	Rename   map[string]string `yaml:"rename"`
+}
+
+// WithOverridesFile sets a user overrides file with custom prefix to name mappings and
+// organization name rewrites, applied on top of the embedded and cached IEEE data.
+// Files ending in .csv are read as CSV with the columns "type,match,name", where type is
+// either "prefix" or "rename"; all other files are read as YAML (see the example below).
+//
+// Prefixes are 6 to 12 hex characters in any common MAC notation and take precedence
+// over all other data, longest prefix first. Renames match organization names case-insensitively.
+//
+// The file does not need to exist. Changes are picked up automatically during lookups, at
+// most every two seconds, so they apply to the devices found by the next scan.
+//
+// Example YAML:
+//
+//	prefixes:
+//	  "AA:BB:CC": "Lab Switch"
+//	rename:
+//	  "Hon Hai Precision Ind. Co.,Ltd.": "Foxconn"
+func WithOverridesFile(path string) Option {
+	return func(r *Registry) {
+		r.overridesPath = path
+	}
+}
+
+// ReloadOverrides re-reads the overrides file when it changed since it was last loaded.
+// A removed file clears the overrides. On a parse error the previous overrides are kept.
+func (reg *Registry) ReloadOverrides() error {
+	if reg.overridesPath == "" {
+		return nil
+	}
+
+	info, err := os.Stat(reg.overridesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		reg.mu.Lock()
+		reg.overrides = nil
+		reg.overridesModTime = time.Time{}
+		reg.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reg.mu.RLock()
+	unchanged := info.ModTime().Equal(reg.overridesModTime)
+	reg.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	b, err := os.ReadFile(reg.overridesPath)
+	if err != nil {
+		return err
+	}
+	ov, parseErr := parseOverrides(reg.overridesPath, b)
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	// remember the mod time also on errors, so a broken file is only reported once
+	reg.overridesModTime = info.ModTime()
+	if parseErr != nil {
+		return fmt.Errorf("parse OUI overrides %s: %w", reg.overridesPath, parseErr)
+	}
+	reg.overrides = ov
+	reg.logger.Debug("OUI: loaded overrides", "path", reg.overridesPath, "prefixes", len(ov.prefixes), "renames", len(ov.renames))
+	return nil
+}
+
+// reloadOverridesThrottled calls ReloadOverrides at most once per overridesCheckInterval.
+// It is called on every lookup, so it only takes a lock when the file is due for a check.
+func (reg *Registry) reloadOverridesThrottled() {
+	if reg.overridesPath == "" {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	checkedAt := reg.overridesCheckedAt.Load()
+	if now-checkedAt < int64(overridesCheckInterval) {
+		return
+	}
+	// only one of the concurrent lookups checks the file
+	if !reg.overridesCheckedAt.CompareAndSwap(checkedAt, now) {
+		return
+	}
+
+	if err := reg.ReloadOverrides(); err != nil {
+		reg.logger.Warn("OUI: failed to reload overrides, keeping previous overrides", "err", err)
+	}
+}
+
+// lookup returns the override entry with the longest prefix matching the normalized MAC address.
+func (ov *overrides) lookup(hex string) (Entry, bool) {
+	if ov == nil {
+		return Entry{}, false
+	}
+	for _, n := range ov.lengths {
+		if len(hex) < n {
+			continue
+		}
+		if org, ok := ov.prefixes[hex[:n]]; ok {
+			return Entry{Prefix: hex[:n], Org: org, Registry: RegistryOverride, Bits: n * 4}, true
+		}
+	}
+	return Entry{}, false
+}
+
+// rename returns the rewritten organization name, if any.
+func (ov *overrides) rename(org string) (string, bool) {
+	if ov == nil {
+		return "", false
+	}
+	name, ok := ov.renames[strings.ToLower(strings.TrimSpace(org))]
+	return name, ok
+}
+
+func (ov *overrides) addPrefix(prefix, name string) error {
+	hex := normalizeHex(strings.TrimSpace(prefix))
+	if len(hex) < 6 || len(hex) > 12 {
+		return fmt.Errorf("invalid prefix %q, expected 6 to 12 hex characters", prefix)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("empty name for prefix %q", prefix)
+	}
+	ov.prefixes[hex] = name
+	return nil
+}
+
+func (ov *overrides) addRename(match, name string) error {
+	match = strings.ToLower(strings.TrimSpace(match))
+	name = strings.TrimSpace(name)
+	if match == "" || name == "" {
+		return fmt.Errorf("invalid rename %q -> %q", match, name)
+	}
+	ov.renames[match] = name
+	return nil
+}
+
+func parseOverrides(path string, b []byte) (*overrides, error) {
+	ov := &overrides{
+		prefixes: make(map[string]string),
+		renames:  make(map[string]string),
+	}
+
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = ov.parseCSV(b)
+	} else {
+		err = ov.parseYAML(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]struct{})
+	for prefix := range ov.prefixes {
+		if _, ok := seen[len(prefix)]; !ok {
+			seen[len(prefix)] = struct{}{}
+			ov.lengths = append(ov.lengths, len(prefix))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ov.lengths)))
+	return ov, nil
+}
+
+func (ov *overrides) parseYAML(b []byte) error {
+	var f overridesFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	for prefix, name := range f.Prefixes {
+		if err := ov.addPrefix(prefix, name); err != nil {
+			return err
+		}
+	}
+	for match, name := range f.Rename {
+		if err := ov.addRename(match, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ov *overrides) parseCSV(b []byte) error {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) != 3 {
+			return fmt.Errorf("line %d: expected 3 columns (type,match,name), got %d", line, len(rec))
+		}
+
+		switch kind := strings.ToLower(strings.TrimSpace(rec[0])); kind {
+		case "type":
+			// header
+		case "prefix":
+			err = ov.addPrefix(rec[1], rec[2])
+		case "rename":
+			err = ov.addRename(rec[1], rec[2])
+		default:
+			err = fmt.Errorf("unknown type %q, expected prefix or rename", kind)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
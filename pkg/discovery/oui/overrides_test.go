@@ -0,0 +1,137 @@
+package oui
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRegistry(t *testing.T, overridesPath string) *Registry {
+	t.Helper()
+	m, err := parseCSVBytes([]byte("Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-L,286FB9,\"Hon Hai Precision Ind. Co.,Ltd.\",Somewhere\n" +
+		"MA-L,AABBCC,IEEE Org,Somewhere\n"))
+	if err != nil {
+		t.Fatalf("parseCSVBytes error: %v", err)
+	}
+	return &Registry{prefixMap: m, logger: slog.Default(), overridesPath: overridesPath}
+}
+
+func writeOverrides(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write overrides: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes overrides: %v", err)
+	}
+}
+
+func TestOverridesYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	writeOverrides(t, path, `
+prefixes:
+  "AA:BB:CC": "Lab Switch"
+  "AA-BB-CC-DD": "Lab Board"
+rename:
+  "hon hai precision ind. co.,ltd.": "Foxconn"
+`, time.Unix(100, 0))
+
+	reg := newTestRegistry(t, path)
+	if err := reg.ReloadOverrides(); err != nil {
+		t.Fatalf("ReloadOverrides error: %v", err)
+	}
+
+	tests := []struct {
+		mac          string
+		wantOrg      string
+		wantRegistry string
+		wantBits     int
+	}{
+		{"aa:bb:cc:dd:00:01", "Lab Board", RegistryOverride, 32},
+		{"aa:bb:cc:00:00:01", "Lab Switch", RegistryOverride, 24},
+		{"28:6f:b9:00:11:22", "Foxconn", "MA-L", 24},
+	}
+	for _, tt := range tests {
+		got, ok := reg.LookupEntry(tt.mac)
+		if !ok || got.Org != tt.wantOrg || got.Registry != tt.wantRegistry || got.Bits != tt.wantBits {
+			t.Errorf("LookupEntry(%q) = %+v, %v; want %q (%s, %d bits)", tt.mac, got, ok, tt.wantOrg, tt.wantRegistry, tt.wantBits)
+		}
+	}
+}
+
+func TestOverridesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.csv")
+	writeOverrides(t, path, "type,match,name\n"+
+		"# in-house boards\n"+
+		"prefix,70:B3:D5:F2:A,In-house Board\n"+
+		"rename,\"Hon Hai Precision Ind. Co.,Ltd.\",Foxconn\n", time.Unix(100, 0))
+
+	reg := newTestRegistry(t, path)
+	if err := reg.ReloadOverrides(); err != nil {
+		t.Fatalf("ReloadOverrides error: %v", err)
+	}
+
+	if org, ok := reg.Lookup("70:b3:d5:f2:a1:23"); !ok || org != "In-house Board" {
+		t.Errorf("expected override, got %q, %v", org, ok)
+	}
+	if org, ok := reg.Lookup("28:6f:b9:00:11:22"); !ok || org != "Foxconn" {
+		t.Errorf("expected rename, got %q, %v", org, ok)
+	}
+}
+
+func TestOverridesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{"short prefix", "o.yaml", "prefixes:\n  \"AA:BB\": \"x\"\n"},
+		{"non hex prefix", "o.yaml", "prefixes:\n  \"ZZ:BB:CC\": \"x\"\n"},
+		{"unknown csv type", "o.csv", "vendor,AABBCC,x\n"},
+		{"csv columns", "o.csv", "prefix,AABBCC\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseOverrides(tt.file, []byte(tt.data)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestOverridesHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	reg := newTestRegistry(t, path)
+
+	if org, _ := reg.Lookup("aa:bb:cc:00:00:01"); org != "IEEE Org" {
+		t.Fatalf("expected IEEE data without overrides file, got %q", org)
+	}
+
+	writeOverrides(t, path, "prefixes:\n  \"AABBCC\": \"Lab Switch\"\n", time.Unix(100, 0))
+	reg.overridesCheckedAt.Store(0)
+	if org, _ := reg.Lookup("aa:bb:cc:00:00:01"); org != "Lab Switch" {
+		t.Fatalf("expected new overrides to be picked up, got %q", org)
+	}
+
+	writeOverrides(t, path, "prefixes: [broken", time.Unix(200, 0))
+	reg.overridesCheckedAt.Store(0)
+	if org, _ := reg.Lookup("aa:bb:cc:00:00:01"); org != "Lab Switch" {
+		t.Fatalf("expected previous overrides to be kept on parse error, got %q", org)
+	}
+
+	writeOverrides(t, path, "prefixes:\n  \"AABBCC\": \"Renamed Switch\"\n", time.Unix(300, 0))
+	if org, _ := reg.Lookup("aa:bb:cc:00:00:01"); org != "Lab Switch" {
+		t.Fatalf("expected reload to be throttled, got %q", org)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove overrides: %v", err)
+	}
+	reg.overridesCheckedAt.Store(0)
+	if org, _ := reg.Lookup("aa:bb:cc:00:00:01"); org != "IEEE Org" {
+		t.Fatalf("expected overrides to be cleared after removal, got %q", org)
+	}
+}
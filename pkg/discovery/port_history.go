@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxPortScanHistory is the number of port scan results kept per device.
+// Older results are discarded when a new scan is recorded.
+const MaxPortScanHistory = 10
+
+// PortScanResult is a single completed port scan of a device.
+type PortScanResult struct {
+	Time      time.Time        `json:"time"`
+	OpenPorts map[string][]int `json:"openPorts"`
+}
+
+// PortChanges describes the difference between two consecutive port scans
+// of a device, organized by protocol (e.g. "tcp").
+type PortChanges struct {
+	Opened map[string][]int `json:"opened"`
+	Closed map[string][]int `json:"closed"`
+}
+
+// Empty reports whether no ports were opened or closed.
+func (c *PortChanges) Empty() bool {
+	return c == nil || (len(c.Opened) == 0 && len(c.Closed) == 0)
+}
+
+// DiffPorts compares two open ports maps and returns the ports that are open
+// in cur but not in prev (Opened) and the ports that are open in prev but not
+// in cur (Closed). The resulting port lists are sorted.
+func DiffPorts(prev, cur map[string][]int) *PortChanges {
+	return &PortChanges{
+		Opened: subtractPorts(cur, prev),
+		Closed: subtractPorts(prev, cur),
+	}
+}
+
+// subtractPorts returns the ports in a that are not in b, per protocol.
+func subtractPorts(a, b map[string][]int) map[string][]int {
+	out := make(map[string][]int)
+	for protocol, ports := range a {
+		seen := make(map[int]struct{}, len(b[protocol]))
+		for _, p := range b[protocol] {
+			seen[p] = struct{}{}
+		}
+		for _, p := range ports {
+			if _, ok := seen[p]; !ok {
+				out[protocol] = append(out[protocol], p)
+				seen[p] = struct{}{}
+			}
+		}
+		sort.Ints(out[protocol])
+	}
+	return out
+}
+
+func copyPorts(ports map[string][]int) map[string][]int {
+	m := make(map[string][]int, len(ports))
+	for k, v := range ports {
+		m[k] = append([]int(nil), v...)
+	}
+	return m
+}
+
+func copyPortScanHistory(history []PortScanResult) []PortScanResult {
+	if history == nil {
+		return nil
+	}
+	out := make([]PortScanResult, len(history))
+	for i, r := range history {
+		out[i] = PortScanResult{Time: r.Time, OpenPorts: copyPorts(r.OpenPorts)}
+	}
+	return out
+}
@@ -2,8 +2,12 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,9 +19,11 @@ type Dialer interface {
 
 // PortScanner performs TCP port scanning on network devices.
 type PortScanner struct {
-	workers int
-	dialer  Dialer
-	iface   *InterfaceInfo
+	workers         int
+	hostConcurrency int
+	limiter         *rateLimiter
+	dialer          Dialer
+	iface           *InterfaceInfo
 }
 
 // NewPortScanner creates a PortScanner with the specified number of concurrent workers.
@@ -27,13 +33,20 @@ type PortScanner struct {
 // Example:
 //
 //	iface, _ := discovery.NewInterfaceInfo("en0")
-//	scanner := discovery.NewPortScanner(20, iface)
-func NewPortScanner(workers int, iface *InterfaceInfo) *PortScanner {
-	return &PortScanner{
+//	scanner := discovery.NewPortScanner(20, iface,
+//	    discovery.WithHostConcurrency(5),
+//	    discovery.WithRateLimit(500),
+//	)
+func NewPortScanner(workers int, iface *InterfaceInfo, opts ...PortScannerOption) *PortScanner {
+	ps := &PortScanner{
 		workers: workers,
 		dialer:  &netDialer{iface: iface},
 		iface:   iface,
 	}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	return ps
 }
 
 // netDialer implements Dialer using net.Dialer.
@@ -92,6 +105,86 @@ func (ps *PortScanner) Stream(ctx context.Context, ip string, ports []int, timeo
 	return ctx.Err()
 }
 
+// ScanHosts scans the same TCP ports on multiple hosts using a single shared worker pool
+// and calls the callback for each open port found. Work is interleaved across hosts so
+// that no single host monopolizes the workers, and at most the configured host
+// concurrency (see WithHostConcurrency) connection attempts run against one host at a time.
+//
+// Cancel the context to abort the scan; ScanHosts returns the context error in that case.
+// The callback is invoked from multiple goroutines - ensure it's thread-safe.
+//
+// Example:
+//
+//	var mu sync.Mutex
+//	open := make(map[string][]int)
+//	err := scanner.ScanHosts(ctx, []string{"192.168.1.10", "192.168.1.20"}, []int{22, 80, 443}, time.Second,
+//	    func(ip string, port int) {
+//	        mu.Lock()
+//	        defer mu.Unlock()
+//	        open[ip] = append(open[ip], port)
+//	    })
+func (ps *PortScanner) ScanHosts(ctx context.Context, hosts []string, ports []int, timeout time.Duration, callback func(ip string, port int)) error {
+	hosts = uniqueStrings(hosts)
+	if len(hosts) == 0 || len(ports) == 0 {
+		return nil
+	}
+
+	workers := ps.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	perHost := ps.hostConcurrency
+	if perHost <= 0 || perHost > workers {
+		perHost = workers
+	}
+
+	hostSlots := make(map[string]chan struct{}, len(hosts))
+	for _, host := range hosts {
+		hostSlots[host] = make(chan struct{}, perHost)
+	}
+
+	type target struct {
+		ip   string
+		port int
+	}
+	targets := make(chan target, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				slot := hostSlots[t.ip]
+				select {
+				case slot <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+				if ps.isPortOpen(ctx, t.ip, t.port, timeout) {
+					callback(t.ip, t.port)
+				}
+				<-slot
+			}
+		}()
+	}
+
+feed:
+	for _, port := range ports {
+		for _, host := range hosts {
+			select {
+			case targets <- target{ip: host, port: port}:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(targets)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
 // streamWorker performs the actual port scanning for streaming.
 func (ps *PortScanner) streamWorker(ctx context.Context, ip string, ports <-chan int, callback func(int), timeout time.Duration) {
 	for {
@@ -111,6 +204,9 @@ func (ps *PortScanner) streamWorker(ctx context.Context, ip string, ports <-chan
 
 // isPortOpen checks if a TCP port is open using context-aware dialing.
 func (ps *PortScanner) isPortOpen(ctx context.Context, ip string, port int, timeout time.Duration) bool {
+	if err := ps.limiter.wait(ctx); err != nil {
+		return false
+	}
 	dialCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	conn, err := ps.dialer.DialContext(dialCtx, "tcp", fmt.Sprintf("%s:%d", ip, port))
@@ -120,3 +216,112 @@ func (ps *PortScanner) isPortOpen(ctx context.Context, ip string, port int, time
 	err = conn.Close()
 	return err == nil
 }
+
+// rateLimiter spaces out connection attempts so that at most one happens per interval.
+// A nil rateLimiter never blocks.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+// wait blocks until the next slot is available or the context is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(slot)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ParsePortSpec parses a port specification such as "22,80,8000-8100" into a sorted
+// list of unique ports. Ranges are inclusive. Whitespace around entries is ignored.
+func ParsePortSpec(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, errors.New("empty port specification")
+	}
+
+	seen := make(map[int]struct{})
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		for p := start; p <= end; p++ {
+			seen[p] = struct{}{}
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, errors.New("empty port specification")
+	}
+	ports := make([]int, 0, len(seen))
+	for p := range seen {
+		ports = append(ports, p)
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+func uniqueStrings(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if _, ok := seen[s]; ok || s == "" {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
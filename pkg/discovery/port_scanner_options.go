@@ -0,0 +1,28 @@
+package discovery
+
+// PortScannerOption configures a PortScanner during construction with NewPortScanner.
+type PortScannerOption func(*PortScanner)
+
+// WithHostConcurrency caps the number of concurrent connection attempts against
+// a single host when scanning multiple hosts with ScanHosts. This prevents the
+// shared worker pool from hammering one device while others are idle.
+// Values <= 0 (or larger than the worker count) disable the per-host cap.
+//
+// Default: no per-host cap (bounded only by the worker count)
+func WithHostConcurrency(n int) PortScannerOption {
+	return func(ps *PortScanner) {
+		ps.hostConcurrency = n
+	}
+}
+
+// WithRateLimit limits the total number of connection attempts per second made by
+// the PortScanner. The limit is global: it is shared by all workers and by all
+// concurrent Stream and ScanHosts calls on the same PortScanner.
+// Values <= 0 disable rate limiting.
+//
+// Default: unlimited
+func WithRateLimit(perSecond int) PortScannerOption {
+	return func(ps *PortScanner) {
+		ps.limiter = newRateLimiter(perSecond)
+	}
+}
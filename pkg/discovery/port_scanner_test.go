@@ -75,3 +75,103 @@ func TestPortScanner_Stream_EmptyPorts(t *testing.T) {
 	require.NoError(t, err)
 	require.Empty(t, openPorts)
 }
+
+// concurrencyDialer tracks the maximum number of in-flight dials per host.
+type concurrencyDialer struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	maxSeen  map[string]int
+	delay    time.Duration
+}
+
+func (c *concurrencyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(address)
+	c.mu.Lock()
+	c.inFlight[host]++
+	if c.inFlight[host] > c.maxSeen[host] {
+		c.maxSeen[host] = c.inFlight[host]
+	}
+	c.mu.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	c.inFlight[host]--
+	c.mu.Unlock()
+	return &mockConn{}, nil
+}
+
+func TestPortScanner_ScanHosts(t *testing.T) {
+	mock := &mockDialer{
+		openPorts: map[string]bool{
+			"10.0.0.1:22":  true,
+			"10.0.0.2:80":  true,
+			"10.0.0.2:443": true,
+		},
+	}
+	ps := &PortScanner{workers: 4, dialer: mock}
+
+	var mu sync.Mutex
+	open := make(map[string][]int)
+	err := ps.ScanHosts(context.Background(), []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"}, []int{22, 80, 443}, 100*time.Millisecond, func(ip string, port int) {
+		mu.Lock()
+		defer mu.Unlock()
+		open[ip] = append(open[ip], port)
+	})
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []int{22}, open["10.0.0.1"])
+	require.ElementsMatch(t, []int{80, 443}, open["10.0.0.2"])
+}
+
+func TestPortScanner_ScanHosts_HostConcurrency(t *testing.T) {
+	dialer := &concurrencyDialer{
+		inFlight: make(map[string]int),
+		maxSeen:  make(map[string]int),
+		delay:    5 * time.Millisecond,
+	}
+	ps := &PortScanner{workers: 16, hostConcurrency: 2, dialer: dialer}
+
+	ports := make([]int, 40)
+	for i := range ports {
+		ports[i] = i + 1
+	}
+	err := ps.ScanHosts(context.Background(), []string{"10.0.0.1", "10.0.0.2"}, ports, time.Second, func(string, int) {})
+	require.NoError(t, err)
+
+	for host, peak := range dialer.maxSeen {
+		require.LessOrEqual(t, peak, 2, "host %s exceeded per-host concurrency", host)
+	}
+}
+
+func TestPortScanner_ScanHosts_Canceled(t *testing.T) {
+	ps := &PortScanner{workers: 2, dialer: &mockDialer{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ps.ScanHosts(ctx, []string{"10.0.0.1"}, []int{22, 80}, 100*time.Millisecond, func(string, int) {
+		t.Fatal("callback should not be called after cancellation")
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRateLimiter_SpacesAttempts(t *testing.T) {
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, l.wait(context.Background()))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestParsePortSpec(t *testing.T) {
+	ports, err := ParsePortSpec("443, 22,80-82,22")
+	require.NoError(t, err)
+	require.Equal(t, []int{22, 80, 81, 82, 443}, ports)
+
+	for _, spec := range []string{"", "abc", "0", "70000", "90-80"} {
+		_, err := ParsePortSpec(spec)
+		require.Error(t, err, "spec %q", spec)
+	}
+}
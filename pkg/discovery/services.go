@@ -0,0 +1,52 @@
+package discovery
+
+import "strings"
+
+// wellKnownServices maps well-known ports to their IANA service names, as also used by nmap.
+var wellKnownServices = map[string]map[int]string{
+	"tcp": {
+		21:    "ftp",
+		22:    "ssh",
+		23:    "telnet",
+		25:    "smtp",
+		53:    "domain",
+		80:    "http",
+		110:   "pop3",
+		135:   "msrpc",
+		139:   "netbios-ssn",
+		143:   "imap",
+		389:   "ldap",
+		443:   "https",
+		445:   "microsoft-ds",
+		548:   "afp",
+		554:   "rtsp",
+		631:   "ipp",
+		993:   "imaps",
+		995:   "pop3s",
+		1433:  "ms-sql-s",
+		1521:  "oracle",
+		1883:  "mqtt",
+		3306:  "mysql",
+		3389:  "ms-wbt-server",
+		5000:  "upnp",
+		5432:  "postgresql",
+		5900:  "vnc",
+		6379:  "redis",
+		8080:  "http-proxy",
+		8443:  "https-alt",
+		8883:  "secure-mqtt",
+		9000:  "cslistener",
+		9090:  "zeus-admin",
+		9100:  "jetdirect",
+		9200:  "wap-wsp",
+		9300:  "vrace",
+		10000: "snet-sensor-mgmt",
+		27017: "mongod",
+	},
+}
+
+// ServiceName returns the well-known service name of a port, e.g. "ssh" for 22/tcp,
+// or an empty string when the port is unknown.
+func ServiceName(proto string, port int) string {
+	return wellKnownServices[strings.ToLower(proto)][port]
+}
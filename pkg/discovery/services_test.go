@@ -0,0 +1,21 @@
+package discovery
+
+import "testing"
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		proto string
+		port  int
+		want  string
+	}{
+		{"tcp", 22, "ssh"},
+		{"TCP", 443, "https"},
+		{"tcp", 12345, ""},
+		{"udp", 22, ""},
+	}
+	for _, tt := range tests {
+		if got := ServiceName(tt.proto, tt.port); got != tt.want {
+			t.Errorf("ServiceName(%q, %d) = %q, want %q", tt.proto, tt.port, got, tt.want)
+		}
+	}
+}
@@ -23,7 +23,10 @@ var (
 	tcpTriggerPorts = []int{80, 443}
 )
 
-var _ discovery2.Sweeper = (*Sweeper)(nil)
+var (
+	_ discovery2.Sweeper            = (*Sweeper)(nil)
+	_ discovery2.SweepStatsReporter = (*Sweeper)(nil)
+)
 
 // Sweeper populates the system ARP cache by triggering network traffic.
 // Since whosthere runs without elevated privileges, it cannot send ARP requests directly.
@@ -40,6 +43,9 @@ type Sweeper struct {
 	interval time.Duration
 	timeout  time.Duration
 	logger   discovery2.Logger
+
+	mu    sync.Mutex
+	stats discovery2.SweepStats
 }
 
 // New creates a Sweeper with the specified options.
@@ -127,11 +133,35 @@ func (s *Sweeper) runSweep(ctx context.Context, subnet *net.IPNet, localIP net.I
 	}
 
 	s.logger.Log(ctx, slog.LevelDebug, "Triggering ARP requests for subnet", "subnet", subnet.Mask.String())
-	s.triggerSubnetSweep(ctx, ips)
+	start := time.Now()
+	completed := s.triggerSubnetSweep(ctx, ips)
+	s.recordSweep(len(ips), time.Since(start), completed)
 	s.logger.Log(ctx, slog.LevelDebug, "ARP triggering completed", "subnet", subnet.String())
 }
 
-func (s *Sweeper) triggerSubnetSweep(ctx context.Context, ips []net.IP) {
+// SweepStats returns the statistics of the sweeps performed so far.
+func (s *Sweeper) SweepStats() discovery2.SweepStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *Sweeper) recordSweep(targets int, duration time.Duration, completed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if completed {
+		s.stats.Sweeps++
+	} else {
+		s.stats.Interrupted++
+	}
+	s.stats.LastTargets = targets
+	s.stats.LastDuration = duration
+	s.stats.LastSweep = time.Now()
+}
+
+// triggerSubnetSweep triggers ARP for all IPs and returns false when interrupted by ctx.
+func (s *Sweeper) triggerSubnetSweep(ctx context.Context, ips []net.IP) bool {
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, maxConcurrentTriggers)
 	total := len(ips)
@@ -142,7 +172,7 @@ func (s *Sweeper) triggerSubnetSweep(ctx context.Context, ips []net.IP) {
 		select {
 		case <-ctx.Done():
 			s.logger.Log(ctx, slog.LevelWarn, "ARP sweep interrupted by context cancellation, this can indicate you have a short scan duration configured", "triggered", triggered, "total", total, "remaining", total-triggered)
-			return
+			return false
 		default:
 		}
 
@@ -158,6 +188,7 @@ func (s *Sweeper) triggerSubnetSweep(ctx context.Context, ips []net.IP) {
 	}
 
 	wg.Wait()
+	return true
 }
 
 func sendARPTarget(ip net.IP) {
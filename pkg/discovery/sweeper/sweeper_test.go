@@ -1,8 +1,10 @@
 package sweeper
 
 import (
+	"context"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/stretchr/testify/require"
@@ -49,3 +51,22 @@ func TestSweeper_GenerateSubnetIPs_LimitsLargeSubnetTo16(t *testing.T) {
 	require.Equal(t, "10.0.0.0", ips[0].String())
 	require.Equal(t, "10.0.255.255", ips[len(ips)-1].String())
 }
+
+func TestSweeper_SweepStats(t *testing.T) {
+	s := &Sweeper{logger: &discovery.NoOpLogger{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, s.triggerSubnetSweep(ctx, []net.IP{net.IPv4(192, 0, 2, 1)}))
+	require.True(t, s.triggerSubnetSweep(context.Background(), nil))
+
+	s.recordSweep(254, time.Second, true)
+	s.recordSweep(254, 2*time.Second, false)
+
+	stats := s.SweepStats()
+	require.Equal(t, 1, stats.Sweeps)
+	require.Equal(t, 1, stats.Interrupted)
+	require.Equal(t, 254, stats.LastTargets)
+	require.Equal(t, 2*time.Second, stats.LastDuration)
+	require.False(t, stats.LastSweep.IsZero())
+}